@@ -1,14 +1,22 @@
 package ai
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"html"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
 
 	"github.com/coding-hui/common/util/slices"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/callbacks"
 	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+	"k8s.io/klog/v2"
 
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/options"
@@ -17,15 +25,36 @@ import (
 
 const (
 	noExec = "[noexec]"
+
+	// InterruptedMarker ends the stored answers of the completions that were
+	// interrupted, telling the partial answers from the complete ones.
+	InterruptedMarker = "[interrupted]"
+
+	// maxToolIterations bounds the tool call round trips of a single completion.
+	maxToolIterations = 10
 )
 
+// Engine sends completions to the configured model. It is safe for
+// concurrent use, every streaming completion gets a Stream of its own.
 type Engine struct {
-	mode    EngineMode
-	running bool
-	channel chan StreamCompletionOutput
+	mode atomic.Int32
 
 	convoStore convo.Store
 	model      Model
+	tools      *tools.Registry
+
+	// callbacks is the chain of callback handlers, nil without handlers
+	handlers  []callbacks.Handler
+	callbacks callbacks.Handler
+
+	// role and its system messages, leading every request
+	role         string
+	roleMessages []llms.ChatMessage
+
+	// fallback chain of the configured model, see candidate
+	fallbackMu   sync.Mutex
+	chain        []candidate
+	modelFactory func(options.Model, options.API) (Model, error)
 
 	Config *options.Config
 }
@@ -35,40 +64,32 @@ func New(ops ...Option) (*Engine, error) {
 }
 
 func (e *Engine) SetMode(m EngineMode) {
-	e.mode = m
+	e.mode.Store(int32(m))
 }
 
 func (e *Engine) GetMode() EngineMode {
-	return e.mode
-}
-
-func (e *Engine) GetChannel() chan StreamCompletionOutput {
-	return e.channel
+	return EngineMode(e.mode.Load())
 }
 
 func (e *Engine) GetConvoStore() convo.Store {
 	return e.convoStore
 }
 
-func (e *Engine) Interrupt() {
-	e.channel <- StreamCompletionOutput{
-		Content:    "[Interrupt]",
-		Last:       true,
-		Interrupt:  true,
-		Executable: false,
-	}
-
-	e.running = false
+// Role returns the name of the role the engine answers in, empty if none.
+func (e *Engine) Role() string {
+	return e.role
 }
 
-func (e *Engine) CreateCompletion(ctx context.Context, messages []llms.ChatMessage) (*CompletionOutput, error) {
-	e.running = true
+func (e *Engine) CreateCompletion(ctx context.Context, messages []llms.ChatMessage, opts ...CompletionOption) (*CompletionOutput, error) {
+	o := newCompletionOptions(opts...)
 
-	if err := e.setupChatContext(ctx, &messages); err != nil {
+	messages = e.withRole(messages)
+	warnings, err := e.setupChatContext(ctx, &messages)
+	if err != nil {
 		return nil, err
 	}
 
-	rsp, err := e.model.GenerateContent(ctx, slices.Map(messages, convert), e.callOptions()...)
+	rsp, model, err := e.generateFormatted(ctx, slices.Map(messages, convert), o.format)
 	if err != nil {
 		return nil, errbook.Wrap("Failed to create completion.", err)
 	}
@@ -78,49 +99,80 @@ func (e *Engine) CreateCompletion(ctx context.Context, messages []llms.ChatMessa
 
 	e.appendAssistantMessage(content)
 
-	e.running = false
-
 	return &CompletionOutput{
 		Command:     "",
 		Explanation: content,
 		Executable:  false,
+		Model:       model,
+		Warnings:    warnings,
 		Usage:       rsp.Usage,
 	}, nil
 }
 
-func (e *Engine) CreateStreamCompletion(ctx context.Context, messages []llms.ChatMessage) (*StreamCompletionOutput, error) {
-	e.running = true
+// CreateStreamCompletion starts a streaming completion and returns its
+// stream once the request is prepared. Closing the stream or canceling ctx
+// aborts the request to the API, the partial answer is then stored marked
+// with InterruptedMarker.
+func (e *Engine) CreateStreamCompletion(ctx context.Context, messages []llms.ChatMessage, opts ...CompletionOption) (*Stream, error) {
+	o := newCompletionOptions(opts...)
 
-	streamingFunc := func(ctx context.Context, chunk []byte) error {
-		e.channel <- StreamCompletionOutput{
-			Content: string(chunk),
-			Last:    false,
-		}
-		return nil
-	}
-
-	if err := e.setupChatContext(ctx, &messages); err != nil {
+	messages = e.withRole(messages)
+	input := messages
+	warnings, err := e.setupChatContext(ctx, &messages)
+	if err != nil {
 		return nil, err
 	}
 
-	for _, v := range messages {
-		err := e.convoStore.AddMessage(ctx, e.Config.CacheWriteToID, v)
-		if err != nil {
-			errbook.HandleError(errbook.Wrap("Failed to add user chat input message to convo", err))
+	e.storeInput(ctx, input)
+
+	stream := newStream(ctx)
+	go func() {
+		stream.finish(e.streamCompletion(stream, messages, o, warnings))
+	}()
+
+	return stream, nil
+}
+
+func (e *Engine) streamCompletion(
+	stream *Stream,
+	messages []llms.ChatMessage,
+	o completionOptions,
+	warnings []string,
+) (*StreamCompletionOutput, error) {
+	ctx := stream.ctx
+
+	// the chunks delivered so far, kept when the completion is interrupted
+	var partial strings.Builder
+	streamingFunc := func(_ context.Context, chunk []byte) error {
+		if err := stream.send(StreamCompletionOutput{
+			Content: string(chunk),
+			Last:    false,
+		}); err != nil {
+			return err
 		}
+		partial.Write(chunk)
+		return nil
 	}
 
 	messageParts := slices.Map(messages, convert)
-	rsp, err := e.model.GenerateContent(ctx, messageParts, e.callOptions(streamingFunc)...)
+	rsp, model, err := e.generateFormatted(ctx, messageParts, o.format, streamingFunc)
 	if err != nil {
-		e.running = false
+		if ctx.Err() != nil {
+			return e.interrupted(partial.String(), model, warnings), errbook.Wrap("The completion was interrupted.", ctx.Err())
+		}
 		return nil, errbook.Wrap("Failed to create stream completion.", err)
 	}
 
 	executable := false
 	output := rsp.Choices[0].Content
 
-	if e.mode == ExecEngineMode {
+	// JSON answers are validated before they are shown, see generateFormatted
+	var content string
+	if o.format == FormatJSON {
+		content = output
+	}
+
+	if e.GetMode() == ExecEngineMode {
 		if !strings.HasPrefix(output, noExec) && !strings.Contains(output, "\n") {
 			executable = true
 		}
@@ -128,34 +180,149 @@ func (e *Engine) CreateStreamCompletion(ctx context.Context, messages []llms.Cha
 
 	output = html.UnescapeString(output)
 
-	e.channel <- StreamCompletionOutput{
-		Content:    "",
+	// stored before the last output, readers save the conversation on it
+	e.appendAssistantMessage(output)
+
+	_ = stream.send(StreamCompletionOutput{
+		Content:    content,
 		Last:       true,
 		Executable: executable,
+		Model:      model,
+		Warnings:   warnings,
 		Usage:      rsp.Usage,
-	}
-	e.running = false
-
-	e.appendAssistantMessage(output)
+	})
 
 	return &StreamCompletionOutput{
 		Content:    output,
 		Last:       true,
 		Executable: executable,
+		Model:      model,
+		Warnings:   warnings,
 		Usage:      rsp.Usage,
 	}, nil
 }
 
+// interrupted stores the partial answer of an interrupted completion, marked
+// as interrupted so the conversation shows the question was not fully
+// answered, and returns it as the last output of the given model.
+func (e *Engine) interrupted(partial, model string, warnings []string) *StreamCompletionOutput {
+	partial = html.UnescapeString(partial)
+	stored := InterruptedMarker
+	if strings.TrimSpace(partial) != "" {
+		stored = strings.TrimRightFunc(partial, unicode.IsSpace) + "\n\n" + InterruptedMarker
+	}
+	e.appendAssistantMessage(stored)
+
+	return &StreamCompletionOutput{
+		Content:   partial,
+		Last:      true,
+		Interrupt: true,
+		Model:     model,
+		Warnings:  warnings,
+	}
+}
+
+// generateContent calls the model and resolves the tool calls it asks for,
+// feeding the results back until the model returns a final answer.
+// It also returns the name of the model that answered, or that was asked
+// last when it fails, which differs from the configured one when the request
+// failed over to a fallback model.
+func (e *Engine) generateContent(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	streamingFunc ...func(ctx context.Context, chunk []byte) error,
+) (*llms.ContentResponse, string, error) {
+	var streamed atomic.Bool
+	if len(streamingFunc) > 0 && streamingFunc[0] != nil {
+		stream := streamingFunc[0]
+		fn := func(ctx context.Context, chunk []byte) error {
+			if len(chunk) > 0 {
+				streamed.Store(true)
+			}
+			if e.callbacks != nil {
+				e.callbacks.HandleStreamingFunc(ctx, chunk)
+			}
+			return stream(ctx, chunk)
+		}
+		if e.tools.Len() > 0 {
+			fn = skipToolCallChunks(fn)
+		}
+		streamingFunc = []func(ctx context.Context, chunk []byte) error{fn}
+	}
+
+	opts := e.callOptions(streamingFunc...)
+	if e.tools.Len() > 0 {
+		opts = append(opts, llms.WithTools(e.tools.Definitions()))
+	}
+
+	var (
+		usage  llms.Usage
+		answer int
+	)
+	for i := 0; ; i++ {
+		streamed.Store(false)
+		rsp, idx, err := e.generateWithFallback(ctx, answer, messages, opts, &streamed)
+		answer = idx
+		if err != nil {
+			return nil, e.candidateName(answer), err
+		}
+		if len(rsp.Choices) == 0 {
+			return nil, e.candidateName(answer), errbook.New("The model returned an empty response.")
+		}
+		if i == 0 {
+			usage = rsp.Usage
+		} else {
+			usage = addUsage(usage, rsp.Usage)
+		}
+
+		choice := rsp.Choices[0]
+		if len(choice.ToolCalls) == 0 || e.tools.Len() == 0 {
+			rsp.Usage = usage
+			return rsp, e.candidateName(answer), nil
+		}
+		if i >= maxToolIterations {
+			return nil, e.candidateName(answer), errbook.New("The model did not produce an answer after %d tool calls.", maxToolIterations)
+		}
+
+		parts := make([]llms.ContentPart, 0, len(choice.ToolCalls)+1)
+		if choice.Content != "" {
+			parts = append(parts, llms.TextPart(choice.Content))
+		}
+		for _, call := range choice.ToolCalls {
+			parts = append(parts, call)
+		}
+		messages = append(messages, llms.MessageContent{Role: llms.ChatMessageTypeAI, Parts: parts})
+		for _, call := range choice.ToolCalls {
+			if call.FunctionCall != nil {
+				klog.V(1).Infof("calling tool %s with %s", call.FunctionCall.Name, call.FunctionCall.Arguments)
+				if e.callbacks != nil {
+					e.callbacks.HandleToolStart(ctx, call.FunctionCall.Name+" "+call.FunctionCall.Arguments)
+				}
+			}
+			result := e.tools.Execute(ctx, call)
+			if e.callbacks != nil {
+				for _, part := range result.Parts {
+					if response, ok := part.(llms.ToolCallResponse); ok {
+						e.callbacks.HandleToolEnd(ctx, response.Content)
+					}
+				}
+			}
+			messages = append(messages, result)
+		}
+	}
+}
+
 func (e *Engine) callOptions(streamingFunc ...func(ctx context.Context, chunk []byte) error) []llms.CallOption {
 	var opts []llms.CallOption
 	if e.Config.MaxTokens > 0 {
 		opts = append(opts, llms.WithMaxTokens(e.Config.MaxTokens))
 	}
+	if len(e.Config.Stop) > 0 {
+		opts = append(opts, llms.WithStopWords(e.Config.Stop))
+	}
 	if len(streamingFunc) > 0 && streamingFunc[0] != nil {
 		opts = append(opts, llms.WithStreamingFunc(streamingFunc[0]))
 	}
-	opts = append(opts, llms.WithModel(e.Config.CurrentModel.Name))
-	opts = append(opts, llms.WithMaxLength(e.Config.CurrentModel.MaxChars))
 	opts = append(opts, llms.WithTemperature(e.Config.Temperature))
 	opts = append(opts, llms.WithTopP(e.Config.TopP))
 	opts = append(opts, llms.WithTopK(e.Config.TopK))
@@ -164,25 +331,99 @@ func (e *Engine) callOptions(streamingFunc ...func(ctx context.Context, chunk []
 	return opts
 }
 
-func (e *Engine) setupChatContext(ctx context.Context, messages *[]llms.ChatMessage) error {
+// setupChatContext puts the history of the continued conversation in front
+// of the new messages and fits both into the input budget of the model.
+// It returns warnings about the parts of the input that were cut.
+func (e *Engine) setupChatContext(ctx context.Context, messages *[]llms.ChatMessage) ([]string, error) {
 	store := e.convoStore
 	if store == nil {
-		return errbook.New("no chat convo store found")
+		return nil, errbook.New("no chat convo store found")
 	}
 
+	var (
+		history  []llms.ChatMessage
+		warnings []string
+	)
 	if !e.Config.NoCache && e.Config.CacheReadFromID != "" {
-		history, err := store.Messages(ctx, e.Config.CacheReadFromID)
+		stored, err := store.Messages(ctx, e.Config.CacheReadFromID)
+		if err == nil {
+			if warning, compacted := e.autoCompact(ctx, stored); compacted {
+				warnings = append(warnings, warning)
+				stored, err = store.Messages(ctx, e.Config.CacheReadFromID)
+			} else if warning != "" {
+				warnings = append(warnings, warning)
+			}
+		}
 		if err != nil {
-			return errbook.Wrap(fmt.Sprintf(
+			return nil, errbook.Wrap(fmt.Sprintf(
 				"There was a problem reading the cache. Use %s / %s to disable it.",
 				console.StderrStyles().InlineCode.Render("--no-cache"),
 				console.StderrStyles().InlineCode.Render("NO_CACHE"),
 			), err)
 		}
-		*messages = append(*messages, history...)
+		history = withoutRepeatedSystemPrompts(stored, *messages)
+	}
+
+	// the system prompt of the request leads the conversation
+	input := *messages
+	prompt := 0
+	for prompt < len(input) && input[prompt].GetType() == llms.ChatMessageTypeSystem {
+		prompt++
+	}
+	history = append(input[:prompt:prompt], history...)
+
+	var cut []string
+	*messages, cut = fitToBudget(history, input[prompt:], e.InputBudget())
+	return append(warnings, cut...), nil
+}
+
+// withRole puts the system messages of the role in front of the messages.
+func (e *Engine) withRole(messages []llms.ChatMessage) []llms.ChatMessage {
+	if len(e.roleMessages) == 0 {
+		return messages
+	}
+	return append(append([]llms.ChatMessage(nil), e.roleMessages...), messages...)
+}
+
+// storeInput adds the new messages to the written conversation. A
+// conversation continued under another title starts with a copy of the
+// history it continues.
+func (e *Engine) storeInput(ctx context.Context, messages []llms.ChatMessage) {
+	readID, writeID := e.Config.CacheReadFromID, e.Config.CacheWriteToID
+	if !e.Config.NoCache && readID != "" && readID != writeID {
+		history, err := e.convoStore.Messages(ctx, readID)
+		if err != nil {
+			errbook.HandleError(errbook.Wrap("Failed to copy the continued conversation", err))
+		}
+		messages = append(append([]llms.ChatMessage(nil), history...), messages...)
+	}
+
+	for _, v := range messages {
+		err := e.convoStore.AddMessage(ctx, writeID, v)
+		if err != nil {
+			errbook.HandleError(errbook.Wrap("Failed to add user chat input message to convo", err))
+		}
 	}
+}
 
-	return nil
+// withoutRepeatedSystemPrompts drops the system messages of the history the
+// new messages send again, every request of a command repeats its prompt.
+func withoutRepeatedSystemPrompts(history, messages []llms.ChatMessage) []llms.ChatMessage {
+	prompts := make(map[string]bool)
+	for _, msg := range messages {
+		if msg.GetType() == llms.ChatMessageTypeSystem {
+			prompts[msg.GetContent()] = true
+		}
+	}
+
+	result := make([]llms.ChatMessage, 0, len(history))
+	for _, msg := range history {
+		if msg.GetType() == llms.ChatMessageTypeSystem && prompts[msg.GetContent()] {
+			continue
+		}
+		result = append(result, msg)
+	}
+	return result
 }
 
 func (e *Engine) appendAssistantMessage(content string) {
@@ -193,7 +434,57 @@ func (e *Engine) appendAssistantMessage(content string) {
 	}
 }
 
+// skipToolCallChunks keeps the JSON encoded tool call deltas emitted by the
+// provider out of the user visible stream.
+func skipToolCallChunks(fn func(ctx context.Context, chunk []byte) error) func(ctx context.Context, chunk []byte) error {
+	return func(ctx context.Context, chunk []byte) error {
+		if isToolCallChunk(chunk) {
+			return nil
+		}
+		return fn(ctx, chunk)
+	}
+}
+
+func isToolCallChunk(chunk []byte) bool {
+	trimmed := bytes.TrimSpace(chunk)
+	if !bytes.HasPrefix(trimmed, []byte("[{")) {
+		return false
+	}
+	var calls []map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &calls); err != nil || len(calls) == 0 {
+		return false
+	}
+	for _, call := range calls {
+		if _, ok := call["function"]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func addUsage(total, u llms.Usage) llms.Usage {
+	total.PromptTokens += u.PromptTokens
+	total.CompletionTokens += u.CompletionTokens
+	total.TotalTokens += u.TotalTokens
+	total.TotalTime += u.TotalTime
+	if total.FirstTokenTime == 0 {
+		total.FirstTokenTime = u.FirstTokenTime
+	}
+	if total.TotalTime > 0 {
+		total.AverageTokensPerSecond = float64(total.CompletionTokens) / total.TotalTime.Seconds()
+	}
+	return total
+}
+
 func convert(msg llms.ChatMessage) llms.MessageContent {
+	if m, ok := msg.(ImageChatMessage); ok {
+		parts := []llms.ContentPart{llms.TextPart(m.Content)}
+		for _, image := range m.Images {
+			// a data URL, the part all the providers take
+			parts = append(parts, llms.ImageURLContent{URL: image.String()})
+		}
+		return llms.MessageContent{Role: m.GetType(), Parts: parts}
+	}
 	return llms.MessageContent{
 		Role:  msg.GetType(),
 		Parts: []llms.ContentPart{llms.TextPart(msg.GetContent())},
@@ -1,12 +1,23 @@
 package ai
 
 import (
+	"path/filepath"
+
+	"github.com/charmbracelet/x/exp/ordered"
 	"github.com/volcengine/volcengine-go-sdk/service/arkruntime"
+	"k8s.io/klog/v2"
 
+	"github.com/coding-hui/ai-terminal/internal/ai/anthropic"
+	"github.com/coding-hui/ai-terminal/internal/ai/cassette"
+	"github.com/coding-hui/ai-terminal/internal/ai/gemini"
+	"github.com/coding-hui/ai-terminal/internal/ai/ollama"
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/options"
 
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/callbacks"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
 	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms/openai"
 	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms/volcengine"
 )
@@ -15,7 +26,7 @@ type Option func(*Engine)
 
 func WithMode(mode EngineMode) Option {
 	return func(e *Engine) {
-		e.mode = mode
+		e.SetMode(mode)
 	}
 }
 
@@ -31,10 +42,38 @@ func WithStore(store convo.Store) Option {
 	}
 }
 
+// WithTools enables function calling with the tools of the given registry.
+func WithTools(registry *tools.Registry) Option {
+	return func(e *Engine) {
+		e.tools = registry
+	}
+}
+
+// WithModel uses the given model instead of creating one from the configured API.
+func WithModel(model Model) Option {
+	return func(e *Engine) {
+		e.model = model
+	}
+}
+
+// WithRole leads every request with the system messages of the given role.
+func WithRole(role string) Option {
+	return func(e *Engine) {
+		e.role = role
+	}
+}
+
+// WithCallbacks adds handlers to the callback chain of the engine, they are
+// called for every request to a model and every tool call.
+func WithCallbacks(handlers ...callbacks.Handler) Option {
+	return func(e *Engine) {
+		e.handlers = append(e.handlers, handlers...)
+	}
+}
+
 func applyOptions(engineOpts ...Option) (engine *Engine, err error) {
 	engine = &Engine{
-		channel: make(chan StreamCompletionOutput),
-		running: false,
+		modelFactory: newModel,
 	}
 
 	for _, option := range engineOpts {
@@ -63,29 +102,120 @@ func applyOptions(engineOpts ...Option) (engine *Engine, err error) {
 		return nil, err
 	}
 
-	mod, api := cfg.CurrentModel, cfg.CurrentAPI
-	switch api.Name {
+	if engine.role != "" {
+		messages, err := LoadRole(cfg, engine.role)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			engine.roleMessages = append(engine.roleMessages, llms.SystemChatMessage{Content: msg})
+		}
+	}
+
+	if cfg.TraceFile != "" {
+		engine.handlers = append(engine.handlers, NewTraceHandler(cfg.TraceFile))
+	}
+	if klog.V(2).Enabled() {
+		engine.handlers = append(engine.handlers, LogHandler{})
+	}
+	if len(engine.handlers) > 0 {
+		engine.callbacks = callbacks.CombiningHandler{Callbacks: engine.handlers}
+	}
+
+	if cfg.Cassette.Mode != "" {
+		engine.modelFactory = withCassette(cfg, engine.modelFactory)
+	}
+
+	if engine.model != nil {
+		return engine, nil
+	}
+
+	engine.model, err = engine.modelFactory(cfg.CurrentModel, cfg.CurrentAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+// newModel creates the client of the provider serving the given API.
+// The provider is picked by the API type, falling back to the API name.
+func newModel(mod options.Model, api options.API) (Model, error) {
+	client, err := newHTTPClient(api)
+	if err != nil {
+		return nil, errbook.Wrap("Could not set up the HTTP client of the API "+api.Name, err)
+	}
+
+	switch providerType(api) {
 	case ModelTypeARK:
-		engine.model, err = volcengine.NewClientWithApiKey(
-			cfg.CurrentAPI.APIKey,
+		// WithTimeout sets the timeout of the HTTP client, it must come after it
+		return volcengine.NewClientWithApiKey(
+			api.APIKey,
+			arkruntime.WithHTTPClient(client),
 			arkruntime.WithBaseUrl(api.BaseURL),
 			arkruntime.WithRegion(api.Region),
 			arkruntime.WithTimeout(api.Timeout),
 			arkruntime.WithRetryTimes(api.RetryTimes),
 		)
-		if err != nil {
-			return nil, err
-		}
+	case ModelTypeAnthropic:
+		return anthropic.New(
+			anthropic.WithModel(mod.Name),
+			anthropic.WithBaseURL(api.BaseURL),
+			anthropic.WithToken(api.APIKey),
+			anthropic.WithAPIVersion(api.Version),
+			anthropic.WithHTTPClient(client),
+		)
+	case ModelTypeOllama:
+		return ollama.New(
+			ollama.WithModel(mod.Name),
+			ollama.WithBaseURL(api.BaseURL),
+			ollama.WithNumCtx(mod.NumCtx),
+			ollama.WithKeepAlive(api.KeepAlive),
+			ollama.WithHTTPClient(client),
+		)
+	case ModelTypeGoogle, ModelTypeGemini:
+		return gemini.New(
+			gemini.WithModel(mod.Name),
+			gemini.WithBaseURL(api.BaseURL),
+			gemini.WithToken(api.APIKey),
+			gemini.WithHTTPClient(client),
+		)
+	case ModelTypeAzure, ModelTypeAzureAD:
+		return newAzureModel(mod, api, client)
 	default:
-		engine.model, err = openai.New(
+		client.Transport = imagePartsTransport{base: client.Transport}
+		return openai.New(
 			openai.WithModel(mod.Name),
 			openai.WithBaseURL(api.BaseURL),
 			openai.WithToken(api.APIKey),
+			openai.WithHTTPClient(client),
 		)
-		if err != nil {
-			return nil, err
+	}
+}
+
+// withCassette records the completions of the models created by the factory
+// to cassette files, or replays them without creating the models at all.
+func withCassette(cfg *options.Config, factory func(options.Model, options.API) (Model, error)) func(options.Model, options.API) (Model, error) {
+	dir := cfg.Cassette.Dir
+	if dir == "" {
+		dir = filepath.Join(cfg.DataStore.CachePath, "cassettes")
+	}
+
+	return func(mod options.Model, api options.API) (Model, error) {
+		opts := []cassette.Option{cassette.WithDir(dir), cassette.WithMode(cfg.Cassette.Mode)}
+		if !cfg.Cassette.Replaying() {
+			model, err := factory(mod, api)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, cassette.WithModel(model))
 		}
+		return cassette.New(opts...)
 	}
+}
 
-	return engine, nil
+// providerType returns the provider serving the API, the API type falling
+// back to the API name.
+func providerType(api options.API) string {
+	return ordered.First(api.Type, api.Name)
 }
@@ -0,0 +1,241 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
+	"github.com/coding-hui/ai-terminal/internal/convo/sqlite3"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// fakeModel replays the scripted responses and records the requests it receives.
+type fakeModel struct {
+	mu        sync.Mutex
+	responses []*llms.ContentResponse
+	requests  [][]llms.MessageContent
+	options   []llms.CallOptions
+}
+
+func (m *fakeModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m.requests = append(m.requests, append([]llms.MessageContent(nil), messages...))
+	m.options = append(m.options, o)
+
+	rsp := m.responses[0]
+	m.responses = m.responses[1:]
+	if o.StreamingFunc != nil {
+		for _, call := range rsp.Choices[0].ToolCalls {
+			if err := o.StreamingFunc(ctx, []byte(`[{"id":"`+call.ID+`","type":"function","function":{"name":"`+call.FunctionCall.Name+`"}}]`)); err != nil {
+				return nil, err
+			}
+		}
+		if content := rsp.Choices[0].Content; content != "" {
+			if err := o.StreamingFunc(ctx, []byte(content)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rsp, nil
+}
+
+type echoTool struct{}
+
+func (echoTool) Name() string               { return "echo" }
+func (echoTool) Description() string        { return "Echo the arguments." }
+func (echoTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (echoTool) Call(_ context.Context, arguments string) (string, error) {
+	return "echo " + arguments, nil
+}
+
+func newTestEngine(t *testing.T, model Model, ops ...Option) *Engine {
+	t.Helper()
+
+	cfg := &options.Config{
+		Model:   "fake",
+		API:     "fake",
+		NoCache: true,
+		APIs:    options.APIs{{Name: "fake", APIKey: "key"}},
+		Models:  map[string]options.Model{"fake": {Name: "fake", API: "fake"}},
+		Roles:   options.Roles{"shell": {"you are a shell expert"}},
+	}
+	store := sqlite3.NewSqliteStore(sqlite3.WithDataPath(t.TempDir()))
+
+	engine, err := New(append([]Option{WithConfig(cfg), WithStore(store), WithModel(model)}, ops...)...)
+	require.NoError(t, err)
+	return engine
+}
+
+func textResponse(content string, usage llms.Usage) *llms.ContentResponse {
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{{Content: content}},
+		Usage:   usage,
+	}
+}
+
+func toolCallResponse(id, name, arguments string, usage llms.Usage) *llms.ContentResponse {
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{{
+			ToolCalls: []llms.ToolCall{{
+				ID:           id,
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: name, Arguments: arguments},
+			}},
+		}},
+		Usage: usage,
+	}
+}
+
+func TestEngineTools(t *testing.T) {
+	t.Run("without tools", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("hello", llms.Usage{})}}
+		engine := newTestEngine(t, model)
+
+		out, err := engine.CreateCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", out.Explanation)
+		require.Len(t, model.options, 1)
+		assert.Empty(t, model.options[0].Tools)
+	})
+
+	t.Run("resolves tool calls", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{
+			toolCallResponse("call-1", "echo", `{"a":1}`, llms.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}),
+			textResponse("done", llms.Usage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23}),
+		}}
+		engine := newTestEngine(t, model, WithTools(tools.NewRegistry(echoTool{})))
+
+		out, err := engine.CreateCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		assert.Equal(t, "done", out.Explanation)
+		assert.Equal(t, 35, out.Usage.TotalTokens)
+
+		require.Len(t, model.requests, 2)
+		require.Len(t, model.options[0].Tools, 1)
+		assert.Equal(t, "echo", model.options[0].Tools[0].Function.Name)
+
+		second := model.requests[1]
+		require.Len(t, second, 3)
+		assert.Equal(t, llms.ChatMessageTypeAI, second[1].Role)
+		assert.Equal(t, llms.ChatMessageTypeTool, second[2].Role)
+		assert.Equal(t, llms.ToolCallResponse{
+			ToolCallID: "call-1",
+			Name:       "echo",
+			Content:    `echo {"a":1}`,
+		}, second[2].Parts[0])
+	})
+
+	t.Run("hides tool call chunks from the stream", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{
+			toolCallResponse("call-1", "echo", `{}`, llms.Usage{}),
+			textResponse("answer", llms.Usage{}),
+		}}
+		engine := newTestEngine(t, model, WithTools(tools.NewRegistry(echoTool{})))
+
+		stream, err := engine.CreateStreamCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+
+		var chunks []string
+		for out := range stream.Outputs() {
+			if !out.IsLast() {
+				chunks = append(chunks, out.GetContent())
+			}
+		}
+		out, err := stream.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, "answer", out.GetContent())
+		assert.Equal(t, []string{"answer"}, chunks)
+	})
+
+	t.Run("stops after too many tool calls", func(t *testing.T) {
+		var responses []*llms.ContentResponse
+		for i := 0; i <= maxToolIterations; i++ {
+			responses = append(responses, toolCallResponse("call", "echo", `{}`, llms.Usage{}))
+		}
+		engine := newTestEngine(t, &fakeModel{responses: responses}, WithTools(tools.NewRegistry(echoTool{})))
+
+		_, err := engine.CreateCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestIsToolCallChunk(t *testing.T) {
+	assert.True(t, isToolCallChunk([]byte(`[{"id":"1","type":"function","function":{"name":"grep","arguments":""}}]`)))
+	assert.True(t, isToolCallChunk([]byte(`[{"index":0,"function":{"arguments":"{\"pa"}}]`)))
+	assert.False(t, isToolCallChunk([]byte(`[{"name":"not a tool call"}]`)))
+	assert.False(t, isToolCallChunk([]byte("plain text")))
+	assert.False(t, isToolCallChunk(nil))
+}
+
+func TestEngineCassette(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	question := []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{"Hello", " world"} {
+			_, _ = fmt.Fprintf(w, `data: {"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":%q}}]}`+"\n\n", chunk)
+		}
+		_, _ = fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`+"\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	newEngine := func(mode string, api options.API) *Engine {
+		cfg := &options.Config{
+			Model:    "gpt",
+			API:      "openai",
+			NoCache:  true,
+			APIs:     options.APIs{api},
+			Models:   map[string]options.Model{"gpt": {Name: "gpt", API: "openai"}},
+			Cassette: options.Cassette{Mode: mode, Dir: dir},
+		}
+		engine, err := New(WithConfig(cfg), WithStore(sqlite3.NewSqliteStore(sqlite3.WithDataPath(t.TempDir()))))
+		require.NoError(t, err)
+		return engine
+	}
+	stream := func(engine *Engine) ([]string, *StreamCompletionOutput) {
+		s, err := engine.CreateStreamCompletion(ctx, question)
+		require.NoError(t, err)
+		var chunks []string
+		for out := range s.Outputs() {
+			if !out.IsLast() {
+				chunks = append(chunks, out.GetContent())
+			}
+		}
+		out, err := s.Wait()
+		require.NoError(t, err)
+		return chunks, out
+	}
+
+	recorded, want := stream(newEngine("record", options.API{Name: "openai", BaseURL: server.URL, APIKey: "key"}))
+	assert.Equal(t, []string{"Hello", " world"}, recorded)
+
+	// replaying needs neither the server nor a key
+	server.Close()
+	replayed, got := stream(newEngine("replay", options.API{Name: "openai", BaseURL: server.URL, APIKeyEnv: "AI_TERMINAL_TEST_MISSING_KEY"}))
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, want.GetContent(), got.GetContent())
+	assert.Equal(t, want.Usage, got.Usage)
+	assert.Equal(t, 5, got.Usage.TotalTokens)
+	assert.EqualValues(t, 1, requests.Load())
+}
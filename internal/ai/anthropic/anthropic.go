@@ -0,0 +1,435 @@
+// Package anthropic implements an ai.Model backed by the native Anthropic
+// Messages API.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+var (
+	ErrEmptyResponse = errors.New("anthropic: no response")
+	ErrMissingToken  = errors.New("anthropic: missing the API key")
+	ErrMissingModel  = errors.New("anthropic: model needs to be provided")
+)
+
+// Error is returned when the API answers with an error status or streams an
+// error event.
+type Error struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("anthropic: %s (status %d): %s", e.Type, e.StatusCode, e.Message)
+}
+
+// Model talks to the Anthropic Messages API.
+type Model struct {
+	opts clientOptions
+}
+
+// New creates an Anthropic model client.
+func New(opts ...Option) (*Model, error) {
+	o := clientOptions{
+		baseURL:    DefaultBaseURL,
+		apiVersion: DefaultAPIVersion,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.token == "" {
+		return nil, ErrMissingToken
+	}
+	if o.baseURL == "" {
+		o.baseURL = DefaultBaseURL
+	}
+	if o.apiVersion == "" {
+		o.apiVersion = DefaultAPIVersion
+	}
+	if o.httpClient == nil {
+		o.httpClient = http.DefaultClient
+	}
+	o.baseURL = strings.TrimSuffix(o.baseURL, "/")
+	return &Model{opts: o}, nil
+}
+
+// GenerateContent implements the ai.Model interface.
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	startTime := time.Now()
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	req, err := m.buildRequest(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.opts.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", m.opts.token)
+	httpReq.Header.Set("anthropic-version", m.opts.apiVersion)
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := m.opts.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, decodeError(resp)
+	}
+
+	if req.Stream {
+		return parseStream(ctx, resp.Body, startTime, opts.StreamingFunc)
+	}
+
+	var msg messageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("anthropic: failed to decode response: %w", err)
+	}
+	return msg.toContentResponse(startTime, 0), nil
+}
+
+func (m *Model) buildRequest(messages []llms.MessageContent, opts llms.CallOptions) (*messageRequest, error) {
+	req := &messageRequest{
+		Model:         opts.Model,
+		MaxTokens:     opts.MaxTokens,
+		StopSequences: opts.StopWords,
+		TopK:          opts.TopK,
+		Stream:        opts.StreamingFunc != nil,
+	}
+	if req.Model == "" {
+		req.Model = m.opts.model
+	}
+	if req.Model == "" {
+		return nil, ErrMissingModel
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = DefaultMaxTokens
+	}
+	// Recent models reject requests that set both temperature and top_p.
+	// A zero temperature is not sent, the model uses its default.
+	if opts.Temperature > 0 {
+		req.Temperature = &opts.Temperature
+	} else if opts.TopP > 0 {
+		req.TopP = &opts.TopP
+	}
+
+	for _, tool := range opts.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		req.Tools = append(req.Tools, toolDefinition{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	var system []string
+	for _, mc := range messages {
+		switch mc.Role {
+		case llms.ChatMessageTypeSystem:
+			for _, part := range mc.Parts {
+				if text, ok := part.(llms.TextContent); ok {
+					system = append(system, text.Text)
+				}
+			}
+			continue
+		}
+
+		role := "user"
+		if mc.Role == llms.ChatMessageTypeAI {
+			role = "assistant"
+		}
+		blocks, err := contentBlocks(mc)
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// The API expects alternating roles, merge consecutive turns of the same role.
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			continue
+		}
+		req.Messages = append(req.Messages, message{Role: role, Content: blocks})
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	return req, nil
+}
+
+func contentBlocks(mc llms.MessageContent) ([]contentBlock, error) {
+	blocks := make([]contentBlock, 0, len(mc.Parts))
+	for _, part := range mc.Parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			if p.Text == "" {
+				continue
+			}
+			blocks = append(blocks, contentBlock{Type: "text", Text: p.Text})
+		case llms.ToolCall:
+			if p.FunctionCall == nil {
+				continue
+			}
+			input := json.RawMessage(p.FunctionCall.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, contentBlock{Type: "tool_use", ID: p.ID, Name: p.FunctionCall.Name, Input: input})
+		case llms.ToolCallResponse:
+			blocks = append(blocks, contentBlock{Type: "tool_result", ToolUseID: p.ToolCallID, Content: p.Content})
+		case llms.BinaryContent:
+			blocks = append(blocks, contentBlock{Type: "image", Source: &imageSource{
+				Type:      "base64",
+				MediaType: p.MIMEType,
+				Data:      base64.StdEncoding.EncodeToString(p.Data),
+			}})
+		case llms.ImageURLContent:
+			source := &imageSource{Type: "url", URL: p.URL}
+			if mediaType, data, ok := strings.Cut(strings.TrimPrefix(p.URL, "data:"), ";base64,"); ok && strings.HasPrefix(p.URL, "data:") {
+				source = &imageSource{Type: "base64", MediaType: mediaType, Data: data}
+			}
+			blocks = append(blocks, contentBlock{Type: "image", Source: source})
+		default:
+			return nil, fmt.Errorf("anthropic: unsupported content part %T", part)
+		}
+	}
+	return blocks, nil
+}
+
+// parseStream consumes the server-sent events of a streaming response.
+func parseStream(
+	ctx context.Context,
+	body io.Reader,
+	startTime time.Time,
+	streamingFunc func(ctx context.Context, chunk []byte) error,
+) (*llms.ContentResponse, error) {
+	var (
+		msg            messageResponse
+		firstTokenTime time.Duration
+		data           bytes.Buffer
+		event          string
+	)
+
+	handle := func(event string, payload []byte) error {
+		var ev streamEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return fmt.Errorf("anthropic: failed to decode %s event: %w", event, err)
+		}
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				msg.ID, msg.Model, msg.Usage = ev.Message.ID, ev.Message.Model, ev.Message.Usage
+			}
+		case "content_block_start":
+			if ev.ContentBlock != nil {
+				block := *ev.ContentBlock
+				if block.Type == "tool_use" {
+					block.Input = nil
+				}
+				msg.Content = append(msg.Content, block)
+			}
+		case "content_block_delta":
+			if ev.Delta == nil || len(msg.Content) == 0 {
+				return nil
+			}
+			block := &msg.Content[len(msg.Content)-1]
+			switch ev.Delta.Type {
+			case "text_delta":
+				if firstTokenTime == 0 {
+					firstTokenTime = time.Since(startTime)
+				}
+				block.Text += ev.Delta.Text
+				if streamingFunc != nil {
+					return streamingFunc(ctx, []byte(ev.Delta.Text))
+				}
+			case "thinking_delta":
+				block.Thinking += ev.Delta.Thinking
+			case "input_json_delta":
+				block.Input = append(block.Input, ev.Delta.PartialJSON...)
+			}
+		case "message_delta":
+			if ev.Delta != nil && ev.Delta.StopReason != "" {
+				msg.StopReason = ev.Delta.StopReason
+			}
+			if ev.Usage != nil {
+				msg.Usage.OutputTokens = ev.Usage.OutputTokens
+			}
+		case "error":
+			if ev.Error != nil {
+				return &Error{StatusCode: statusFromType(ev.Error.Type), Type: ev.Error.Type, Message: ev.Error.Message}
+			}
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				if err := handle(event, data.Bytes()); err != nil {
+					return nil, err
+				}
+			}
+			data.Reset()
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if data.Len() > 0 {
+		if err := handle(event, data.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if msg.ID == "" && len(msg.Content) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	return msg.toContentResponse(startTime, firstTokenTime), nil
+}
+
+func (r *messageResponse) toContentResponse(startTime time.Time, firstTokenTime time.Duration) *llms.ContentResponse {
+	choice := &llms.ContentChoice{
+		StopReason: stopReason(r.StopReason),
+		GenerationInfo: map[string]any{
+			"id":          r.ID,
+			"model":       r.Model,
+			"stop_reason": r.StopReason,
+		},
+	}
+	for _, block := range r.Content {
+		switch block.Type {
+		case "text":
+			choice.Content += block.Text
+		case "thinking":
+			choice.ReasoningContent += block.Thinking
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			choice.ToolCalls = append(choice.ToolCalls, llms.ToolCall{
+				ID:           block.ID,
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: block.Name, Arguments: arguments},
+			})
+		}
+	}
+	if len(choice.ToolCalls) > 0 {
+		choice.FuncCall = choice.ToolCalls[0].FunctionCall
+	}
+
+	totalTime := time.Since(startTime)
+	if firstTokenTime == 0 {
+		firstTokenTime = totalTime
+	}
+	usage := llms.Usage{
+		FirstTokenTime:   firstTokenTime,
+		TotalTime:        totalTime,
+		PromptTokens:     r.Usage.InputTokens + r.Usage.CacheCreationInputTokens + r.Usage.CacheReadInputTokens,
+		CompletionTokens: r.Usage.OutputTokens,
+		PromptTokensDetails: llms.PromptTokensDetail{
+			CachedTokens: r.Usage.CacheReadInputTokens,
+		},
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	if totalTime > 0 {
+		usage.AverageTokensPerSecond = float64(usage.CompletionTokens) / totalTime.Seconds()
+	}
+
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{choice},
+		Usage:   usage,
+	}
+}
+
+// stopReason maps the Anthropic stop reasons onto the OpenAI style values
+// used by the other providers.
+func stopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
+
+func statusFromType(errType string) int {
+	switch errType {
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	case "not_found_error":
+		return http.StatusNotFound
+	case "request_too_large":
+		return http.StatusRequestEntityTooLarge
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &Error{StatusCode: resp.StatusCode, Type: http.StatusText(resp.StatusCode), Message: strings.TrimSpace(string(body))}
+
+	var payload errorResponse
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error.Message != "" {
+		apiErr.Type = payload.Error.Type
+		apiErr.Message = payload.Error.Message
+	}
+	return apiErr
+}
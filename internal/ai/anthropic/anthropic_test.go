@@ -0,0 +1,222 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, req messageRequest)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, DefaultAPIVersion, r.Header.Get("anthropic-version"))
+
+		var req messageRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		handler(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestModel(t *testing.T, srv *httptest.Server) *Model {
+	t.Helper()
+	m, err := New(WithToken("test-key"), WithBaseURL(srv.URL+"/v1"), WithModel("claude-test"))
+	require.NoError(t, err)
+	return m
+}
+
+func TestGenerateContent(t *testing.T) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "be brief"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "hello"),
+		llms.TextParts(llms.ChatMessageTypeAI, "hi"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "how are you?"),
+	}
+
+	t.Run("request mapping", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req messageRequest) {
+			assert.Equal(t, "claude-test", req.Model)
+			assert.Equal(t, "be brief", req.System)
+			assert.Equal(t, DefaultMaxTokens, req.MaxTokens)
+			assert.Equal(t, []string{"STOP"}, req.StopSequences)
+			require.Len(t, req.Messages, 3)
+			assert.Equal(t, "user", req.Messages[0].Role)
+			assert.Equal(t, "assistant", req.Messages[1].Role)
+			assert.Equal(t, "how are you?", req.Messages[2].Content[0].Text)
+			assert.False(t, req.Stream)
+
+			_, _ = fmt.Fprint(w, `{
+				"id": "msg_1", "model": "claude-test", "stop_reason": "end_turn",
+				"content": [{"type": "text", "text": "fine"}],
+				"usage": {"input_tokens": 12, "output_tokens": 3, "cache_read_input_tokens": 4}
+			}`)
+		})
+
+		rsp, err := newTestModel(t, srv).GenerateContent(context.Background(), messages, llms.WithStopWords([]string{"STOP"}))
+		require.NoError(t, err)
+		require.Len(t, rsp.Choices, 1)
+		assert.Equal(t, "fine", rsp.Choices[0].Content)
+		assert.Equal(t, "stop", rsp.Choices[0].StopReason)
+		assert.Equal(t, 16, rsp.Usage.PromptTokens)
+		assert.Equal(t, 3, rsp.Usage.CompletionTokens)
+		assert.Equal(t, 19, rsp.Usage.TotalTokens)
+		assert.Equal(t, 4, rsp.Usage.PromptTokensDetails.CachedTokens)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req messageRequest) {
+			assert.True(t, req.Stream)
+			w.Header().Set("Content-Type", "text/event-stream")
+			events := []string{
+				`event: message_start
+data: {"type":"message_start","message":{"id":"msg_2","model":"claude-test","content":[],"usage":{"input_tokens":20,"output_tokens":1}}}`,
+				`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`event: ping
+data: {"type":"ping"}`,
+				`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+				`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+				`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":7}}`,
+				`event: message_stop
+data: {"type":"message_stop"}`,
+			}
+			for _, ev := range events {
+				_, _ = fmt.Fprint(w, ev+"\n\n")
+			}
+		})
+
+		var chunks []string
+		rsp, err := newTestModel(t, srv).GenerateContent(context.Background(), messages,
+			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+				chunks = append(chunks, string(chunk))
+				return nil
+			}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hello", " world"}, chunks)
+		assert.Equal(t, "Hello world", rsp.Choices[0].Content)
+		assert.Equal(t, "length", rsp.Choices[0].StopReason)
+		assert.Equal(t, 20, rsp.Usage.PromptTokens)
+		assert.Equal(t, 7, rsp.Usage.CompletionTokens)
+	})
+
+	t.Run("streamed tool use", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req messageRequest) {
+			require.Len(t, req.Tools, 1)
+			assert.Equal(t, "read_file", req.Tools[0].Name)
+			events := []string{
+				`data: {"type":"message_start","message":{"id":"msg_3","usage":{"input_tokens":5}}}`,
+				`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}`,
+				`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+				`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"go.mod\"}"}}`,
+				`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+			}
+			for _, ev := range events {
+				_, _ = fmt.Fprint(w, ev+"\n\n")
+			}
+		})
+
+		rsp, err := newTestModel(t, srv).GenerateContent(context.Background(), messages,
+			llms.WithTools([]llms.Tool{{Type: "function", Function: &llms.FunctionDefinition{Name: "read_file"}}}),
+			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error { return nil }))
+		require.NoError(t, err)
+		assert.Equal(t, "tool_calls", rsp.Choices[0].StopReason)
+		require.Len(t, rsp.Choices[0].ToolCalls, 1)
+		assert.Equal(t, "toolu_1", rsp.Choices[0].ToolCalls[0].ID)
+		assert.Equal(t, `{"path":"go.mod"}`, rsp.Choices[0].ToolCalls[0].FunctionCall.Arguments)
+	})
+
+	t.Run("tool results", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req messageRequest) {
+			require.Len(t, req.Messages, 3)
+			assert.Equal(t, "tool_use", req.Messages[1].Content[0].Type)
+			assert.JSONEq(t, `{"path":"go.mod"}`, string(req.Messages[1].Content[0].Input))
+			assert.Equal(t, "user", req.Messages[2].Role)
+			assert.Equal(t, "tool_result", req.Messages[2].Content[0].Type)
+			assert.Equal(t, "toolu_1", req.Messages[2].Content[0].ToolUseID)
+			_, _ = fmt.Fprint(w, `{"id":"msg_4","content":[{"type":"text","text":"done"}],"stop_reason":"end_turn"}`)
+		})
+
+		rsp, err := newTestModel(t, srv).GenerateContent(context.Background(), []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeHuman, "read go.mod"),
+			{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{llms.ToolCall{
+				ID: "toolu_1", Type: "function", FunctionCall: &llms.FunctionCall{Name: "read_file", Arguments: `{"path":"go.mod"}`},
+			}}},
+			{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{
+				ToolCallID: "toolu_1", Name: "read_file", Content: "module x",
+			}}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "done", rsp.Choices[0].Content)
+	})
+
+	t.Run("images", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req messageRequest) {
+			require.Len(t, req.Messages, 1)
+			blocks := req.Messages[0].Content
+			require.Len(t, blocks, 3)
+			assert.Equal(t, "image", blocks[1].Type)
+			assert.Equal(t, &imageSource{Type: "base64", MediaType: "image/png", Data: "iVBORw=="}, blocks[1].Source)
+			assert.Equal(t, &imageSource{Type: "url", URL: "https://example.com/a.png"}, blocks[2].Source)
+			_, _ = fmt.Fprint(w, `{"id":"msg_5","content":[{"type":"text","text":"a cat"}],"stop_reason":"end_turn"}`)
+		})
+
+		rsp, err := newTestModel(t, srv).GenerateContent(context.Background(), []llms.MessageContent{{
+			Role: llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{
+				llms.TextPart("what is this?"),
+				llms.ImageURLPart("data:image/png;base64,iVBORw=="),
+				llms.ImageURLPart("https://example.com/a.png"),
+			},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, "a cat", rsp.Choices[0].Content)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+		}))
+		defer srv.Close()
+
+		_, err := newTestModel(t, srv).GenerateContent(context.Background(), messages)
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+		assert.Equal(t, "rate_limit_error", apiErr.Type)
+		assert.Equal(t, "slow down", apiErr.Message)
+	})
+
+	t.Run("stream error event", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req messageRequest) {
+			_, _ = fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+		})
+
+		_, err := newTestModel(t, srv).GenerateContent(context.Background(), messages,
+			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error { return nil }))
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 529, apiErr.StatusCode)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		_, err := New(WithModel("claude-test"))
+		assert.ErrorIs(t, err, ErrMissingToken)
+	})
+}
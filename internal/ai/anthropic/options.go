@@ -0,0 +1,61 @@
+package anthropic
+
+import (
+	"net/http"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the Anthropic API.
+	DefaultBaseURL = "https://api.anthropic.com/v1"
+	// DefaultAPIVersion is the value sent in the anthropic-version header.
+	DefaultAPIVersion = "2023-06-01"
+	// DefaultMaxTokens is used when the caller does not limit the response,
+	// the Messages API requires max_tokens on every request.
+	DefaultMaxTokens = 4096
+)
+
+type clientOptions struct {
+	token      string
+	model      string
+	baseURL    string
+	apiVersion string
+	httpClient *http.Client
+}
+
+// Option is a functional option for the Anthropic client.
+type Option func(*clientOptions)
+
+// WithToken passes the Anthropic API key to the client.
+func WithToken(token string) Option {
+	return func(opts *clientOptions) {
+		opts.token = token
+	}
+}
+
+// WithModel sets the default model, used when the call does not name one.
+func WithModel(model string) Option {
+	return func(opts *clientOptions) {
+		opts.model = model
+	}
+}
+
+// WithBaseURL overrides the base URL of the API, e.g. for a proxy.
+func WithBaseURL(baseURL string) Option {
+	return func(opts *clientOptions) {
+		opts.baseURL = baseURL
+	}
+}
+
+// WithAPIVersion overrides the anthropic-version header.
+func WithAPIVersion(version string) Option {
+	return func(opts *clientOptions) {
+		opts.apiVersion = version
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to talk to the API.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *clientOptions) {
+		opts.httpClient = client
+	}
+}
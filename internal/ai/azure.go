@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/charmbracelet/x/exp/ordered"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms/openai"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// azureModel sends the requests to a model to its Azure OpenAI deployment.
+// The OpenAI client routes a request by the model it names, so the model is
+// replaced by the deployment.
+type azureModel struct {
+	model      Model
+	deployment string
+}
+
+// newAzureModel returns a model served by an Azure OpenAI resource. The
+// resource is reached at <base-url>/openai/deployments/<deployment>, the
+// deployment defaulting to the name of the model, with the api-version query
+// parameter of the API. Keys are sent in the api-key header, Entra ID tokens
+// of the azure-ad type as bearer tokens.
+func newAzureModel(mod options.Model, api options.API, client *http.Client) (Model, error) {
+	apiType := openai.APITypeAzure
+	if providerType(api) == ModelTypeAzureAD {
+		apiType = openai.APITypeAzureAD
+	}
+
+	deployment := ordered.First(mod.Deployment, mod.Name)
+	client.Transport = imagePartsTransport{base: client.Transport}
+	model, err := openai.New(
+		openai.WithModel(deployment),
+		openai.WithBaseURL(api.BaseURL),
+		openai.WithToken(api.APIKey),
+		openai.WithAPIType(apiType),
+		// a version is always set, or the client strips the dots off the
+		// deployment names
+		openai.WithAPIVersion(ordered.First(api.Version, openai.DefaultAPIVersion)),
+		openai.WithHTTPClient(client),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return azureModel{model: model, deployment: deployment}, nil
+}
+
+func (m azureModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	return m.model.GenerateContent(ctx, messages, append(opts, llms.WithModel(m.deployment))...)
+}
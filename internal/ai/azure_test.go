@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func TestAzureModel(t *testing.T) {
+	type request struct {
+		path, version, key, auth string
+	}
+
+	serve := func(t *testing.T) (*httptest.Server, *request) {
+		got := &request{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got.path = r.URL.Path
+			got.version = r.URL.Query().Get("api-version")
+			got.key = r.Header.Get("api-key")
+			got.auth = r.Header.Get("Authorization")
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"pong"},"finish_reason":"stop"}]}`)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, got
+	}
+
+	generate := func(t *testing.T, mod options.Model, api options.API) {
+		model, err := newModel(mod, api)
+		require.NoError(t, err)
+
+		// the engine names the model of the settings in the requests
+		rsp, err := model.GenerateContent(context.Background(),
+			[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "ping")},
+			llms.WithModel(mod.Name))
+		require.NoError(t, err)
+		assert.Equal(t, "pong", rsp.Choices[0].Content)
+	}
+
+	t.Run("api key", func(t *testing.T) {
+		srv, got := serve(t)
+		generate(t,
+			options.Model{Name: "gpt-4o", Deployment: "team-gpt4o"},
+			options.API{Name: "work", Type: ModelTypeAzure, BaseURL: srv.URL, APIKey: "key", Version: "2024-06-01"})
+
+		assert.Equal(t, "/openai/deployments/team-gpt4o/chat/completions", got.path)
+		assert.Equal(t, "2024-06-01", got.version)
+		assert.Equal(t, "key", got.key)
+		assert.Empty(t, got.auth)
+	})
+
+	t.Run("deployment named after the model", func(t *testing.T) {
+		srv, got := serve(t)
+		generate(t,
+			options.Model{Name: "gpt-4.1-mini"},
+			options.API{Name: "azure", BaseURL: srv.URL, APIKey: "key"})
+
+		assert.Equal(t, "/openai/deployments/gpt-4.1-mini/chat/completions", got.path)
+		assert.NotEmpty(t, got.version)
+	})
+
+	t.Run("entra id token", func(t *testing.T) {
+		srv, got := serve(t)
+		generate(t,
+			options.Model{Name: "gpt-4o"},
+			options.API{Name: "work", Type: ModelTypeAzureAD, BaseURL: srv.URL, APIKey: "token", Version: "2024-06-01"})
+
+		assert.Equal(t, "/openai/deployments/gpt-4o/chat/completions", got.path)
+		assert.Equal(t, "Bearer token", got.auth)
+		assert.Empty(t, got.key)
+	})
+}
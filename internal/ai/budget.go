@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/exp/ordered"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+const truncatedMarker = "\n\n[... truncated %d characters ...]\n\n"
+
+// InputBudget returns the number of characters the configured model accepts
+// as input, zero if the input is not limited.
+func (e *Engine) InputBudget() int {
+	if e.Config.NoLimit {
+		return 0
+	}
+	return ordered.First(e.Config.CurrentModel.MaxChars, e.Config.MaxInputChars)
+}
+
+// fitToBudget fits the conversation history and the new messages into budget
+// characters. System messages are always kept. The oldest turns of the
+// history are dropped first, then the longest new messages are cut in the
+// middle. It returns the messages to send and warnings about what was cut.
+func fitToBudget(history, messages []llms.ChatMessage, budget int) ([]llms.ChatMessage, []string) {
+	if budget <= 0 {
+		return append(history, messages...), nil
+	}
+
+	var warnings []string
+	size := MessagesSize(history) + MessagesSize(messages)
+
+	dropped := 0
+	for size > budget {
+		start, end := oldestTurn(history)
+		if start < 0 {
+			break
+		}
+		size -= MessagesSize(history[start:end])
+		dropped += end - start
+		history = append(history[:start:start], history[end:]...)
+	}
+	if dropped > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"Dropped the %d oldest messages of the conversation to fit the input limit of %d characters.", dropped, budget))
+	}
+
+	if size > budget {
+		messages = append([]llms.ChatMessage(nil), messages...)
+		for size > budget {
+			i := longestMessage(messages)
+			if i < 0 {
+				break
+			}
+			content := messages[i].GetContent()
+			length := utf8.RuneCountInString(content)
+			marker := utf8.RuneCountInString(fmt.Sprintf(truncatedMarker, length))
+			keep := max(length-(size-budget)-marker, 0)
+			truncated := truncateMiddle(content, keep)
+			if shrunk := length - utf8.RuneCountInString(truncated); shrunk > 0 {
+				messages[i] = withContent(messages[i], truncated)
+				size -= shrunk
+				warnings = append(warnings, fmt.Sprintf(
+					"Truncated a %s message by %d characters to fit the input limit of %d characters.",
+					messages[i].GetType(), length-keep, budget))
+			} else {
+				// only system messages and markers are left
+				break
+			}
+		}
+	}
+
+	return append(history, messages...), warnings
+}
+
+// oldestTurn returns the bounds of the oldest turn of the history that can
+// be dropped: a human message and the answers up to the next human message.
+func oldestTurn(history []llms.ChatMessage) (int, int) {
+	start := -1
+	for i, msg := range history {
+		if msg.GetType() != llms.ChatMessageTypeSystem {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+
+	end := start + 1
+	for end < len(history) {
+		switch history[end].GetType() {
+		case llms.ChatMessageTypeHuman, llms.ChatMessageTypeSystem:
+			return start, end
+		}
+		end++
+	}
+	return start, end
+}
+
+// longestMessage returns the index of the longest message that may be cut,
+// or -1 if there is none. System messages are never cut.
+func longestMessage(messages []llms.ChatMessage) int {
+	longest, size := -1, 0
+	for i, msg := range messages {
+		if msg.GetType() == llms.ChatMessageTypeSystem {
+			continue
+		}
+		if n := utf8.RuneCountInString(msg.GetContent()); n > size {
+			longest, size = i, n
+		}
+	}
+	return longest
+}
+
+func truncateMiddle(content string, keep int) string {
+	runes := []rune(content)
+	if keep >= len(runes) {
+		return content
+	}
+	head := keep / 2
+	tail := keep - head
+	return string(runes[:head]) + fmt.Sprintf(truncatedMarker, len(runes)-keep) + string(runes[len(runes)-tail:])
+}
+
+func withContent(msg llms.ChatMessage, content string) llms.ChatMessage {
+	if m, ok := msg.(ImageChatMessage); ok {
+		return ImageChatMessage{Content: content, Images: m.Images}
+	}
+	switch msg.GetType() {
+	case llms.ChatMessageTypeHuman:
+		return llms.HumanChatMessage{Content: content}
+	case llms.ChatMessageTypeAI:
+		return llms.AIChatMessage{Content: content}
+	case llms.ChatMessageTypeSystem:
+		return llms.SystemChatMessage{Content: content}
+	default:
+		return llms.GenericChatMessage{Role: string(msg.GetType()), Content: content}
+	}
+}
+
+// MessagesSize returns the number of characters of the messages, the unit of
+// the input budget.
+func MessagesSize(messages []llms.ChatMessage) int {
+	size := 0
+	for _, msg := range messages {
+		size += utf8.RuneCountInString(msg.GetContent())
+	}
+	return size
+}
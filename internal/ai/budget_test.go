@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+func TestFitToBudget(t *testing.T) {
+	system := llms.SystemChatMessage{Content: "You are a helpful assistant."}
+	history := []llms.ChatMessage{
+		system,
+		llms.HumanChatMessage{Content: strings.Repeat("a", 100)},
+		llms.AIChatMessage{Content: strings.Repeat("b", 100)},
+		llms.HumanChatMessage{Content: strings.Repeat("c", 100)},
+		llms.AIChatMessage{Content: strings.Repeat("d", 100)},
+	}
+	question := llms.HumanChatMessage{Content: "and now?"}
+
+	t.Run("keeps everything without a budget", func(t *testing.T) {
+		messages, warnings := fitToBudget(history, []llms.ChatMessage{question}, 0)
+		assert.Len(t, messages, 6)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("keeps everything that fits", func(t *testing.T) {
+		messages, warnings := fitToBudget(history, []llms.ChatMessage{question}, 1000)
+		assert.Len(t, messages, 6)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("drops the oldest turns", func(t *testing.T) {
+		messages, warnings := fitToBudget(history, []llms.ChatMessage{question}, 300)
+		assert.Equal(t, []llms.ChatMessage{system, history[3], history[4], question}, messages)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "Dropped the 2 oldest messages")
+	})
+
+	t.Run("does not modify the history", func(t *testing.T) {
+		before := append([]llms.ChatMessage(nil), history...)
+		_, _ = fitToBudget(history, []llms.ChatMessage{question}, 50)
+		assert.Equal(t, before, history)
+	})
+
+	t.Run("truncates oversize input", func(t *testing.T) {
+		input := []llms.ChatMessage{llms.HumanChatMessage{Content: "head " + strings.Repeat("x", 1000) + " tail"}}
+		messages, warnings := fitToBudget(history, input, 200)
+		require.Len(t, messages, 2)
+		assert.Equal(t, system, messages[0])
+		assert.LessOrEqual(t, MessagesSize(messages), 200)
+
+		content := messages[1].GetContent()
+		assert.Equal(t, llms.ChatMessageTypeHuman, messages[1].GetType())
+		assert.True(t, strings.HasPrefix(content, "head "))
+		assert.True(t, strings.HasSuffix(content, " tail"))
+		assert.Contains(t, content, "truncated")
+		assert.Len(t, warnings, 2)
+	})
+
+	t.Run("never cuts the system prompt", func(t *testing.T) {
+		messages, _ := fitToBudget(nil, []llms.ChatMessage{system, question}, 10)
+		assert.Equal(t, system, messages[0])
+	})
+}
+
+func TestSetupChatContext(t *testing.T) {
+	ctx := context.Background()
+	model := &fakeModel{responses: []*llms.ContentResponse{
+		textResponse("first answer", llms.Usage{}),
+		textResponse("second answer", llms.Usage{}),
+	}}
+	engine := newTestEngine(t, model)
+	engine.Config.NoCache = false
+	engine.Config.CacheWriteToID = strings.Repeat("a", 40)
+
+	system := llms.SystemChatMessage{Content: "system prompt"}
+	complete := func(content string) {
+		stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{system, llms.HumanChatMessage{Content: content}})
+		require.NoError(t, err)
+		_, err = stream.Wait()
+		require.NoError(t, err)
+	}
+	complete("first")
+	engine.Config.CacheReadFromID = engine.Config.CacheWriteToID
+	complete("second")
+
+	request := model.requests[1]
+	texts := make([]string, 0, len(request))
+	for _, msg := range request {
+		texts = append(texts, msg.Parts[0].(llms.TextContent).Text)
+	}
+	assert.Equal(t, []string{"system prompt", "first", "first answer", "second"}, texts)
+
+	stored, err := engine.GetConvoStore().Messages(ctx, engine.Config.CacheWriteToID)
+	require.NoError(t, err)
+	assert.Len(t, stored, 6)
+}
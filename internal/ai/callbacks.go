@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"time"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+// Call is a request of the engine to a model. The engine puts it in the
+// context passed to the callback handlers, see CallFromContext.
+type Call struct {
+	Model    string
+	API      string
+	Command  string
+	Messages []llms.MessageContent
+	Options  llms.CallOptions
+	Start    time.Time
+}
+
+type callKey struct{}
+
+// CallFromContext returns the request to a model the callback was called for.
+func CallFromContext(ctx context.Context) (*Call, bool) {
+	call, ok := ctx.Value(callKey{}).(*Call)
+	return call, ok
+}
+
+// generate sends the request to the model of the candidate, telling the
+// callback handlers about it.
+func (e *Engine) generate(ctx context.Context, c candidate, messages []llms.MessageContent, opts []llms.CallOption) (*llms.ContentResponse, error) {
+	if e.callbacks == nil {
+		return c.model.GenerateContent(ctx, messages, opts...)
+	}
+
+	call := &Call{
+		Model:    c.config.Name,
+		API:      c.api,
+		Command:  e.Config.Command,
+		Messages: messages,
+		Start:    time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&call.Options)
+	}
+	ctx = context.WithValue(ctx, callKey{}, call)
+
+	e.callbacks.HandleLLMGenerateContentStart(ctx, messages)
+	rsp, err := c.model.GenerateContent(ctx, messages, opts...)
+	if err != nil {
+		e.callbacks.HandleLLMError(ctx, err)
+		return nil, err
+	}
+	e.callbacks.HandleLLMGenerateContentEnd(ctx, rsp)
+
+	return rsp, nil
+}
@@ -0,0 +1,204 @@
+// Package cassette records the requests sent to a model together with their
+// answers in cassette files, and replays them by the hash of the request.
+// Replaying cassettes runs the commands end to end without a live model.
+package cassette
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+)
+
+// ErrNotRecorded is returned when a replayed request has no cassette.
+var ErrNotRecorded = errors.New("the request is not recorded")
+
+// Model is the model the requests are recorded from.
+type Model interface {
+	GenerateContent(context.Context, []llms.MessageContent, ...llms.CallOption) (*llms.ContentResponse, error)
+}
+
+// Request is a request sent to the model. The streaming function is left
+// out, a request is replayed whether it is streamed or not.
+type Request struct {
+	Messages []llms.MessageContent `json:"messages"`
+	Options  llms.CallOptions      `json:"options"`
+}
+
+// Key returns the hash the cassette of the request is looked up by.
+func (r Request) Key() (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Cassette is a recorded request with the chunks it was streamed in and
+// the complete answer, including the usage.
+type Cassette struct {
+	Request  Request               `json:"request"`
+	Chunks   []string              `json:"chunks,omitempty"`
+	Response *llms.ContentResponse `json:"response"`
+}
+
+// Recorder records the requests to its model or replays them.
+type Recorder struct {
+	opts cassetteOptions
+}
+
+var _ Model = (*Recorder)(nil)
+
+// New returns a recorder. Recording needs the model to send the requests
+// to, replaying never calls it.
+func New(opts ...Option) (*Recorder, error) {
+	var o cassetteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.dir == "" {
+		return nil, errbook.New("The cassette directory is not set.")
+	}
+	switch o.mode {
+	case ModeReplay:
+	case ModeRecord:
+		if o.model == nil {
+			return nil, errbook.New("A model is needed to record cassettes.")
+		}
+	default:
+		return nil, errbook.New("Invalid cassette mode %q, cassettes can be recorded (%s) or replayed (%s).", o.mode, ModeRecord, ModeReplay)
+	}
+	return &Recorder{opts: o}, nil
+}
+
+// GenerateContent records the request and its answer, or answers it from
+// its cassette, streaming the recorded chunks.
+func (r *Recorder) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var o llms.CallOptions
+	for _, opt := range options {
+		opt(&o)
+	}
+	req := Request{Messages: messages, Options: o}
+	key, err := req.Key()
+	if err != nil {
+		return nil, errbook.Wrap("Could not hash the request.", err)
+	}
+
+	if r.opts.mode == ModeReplay {
+		return r.replay(ctx, key, o.StreamingFunc)
+	}
+	return r.record(ctx, key, req, options, o.StreamingFunc)
+}
+
+func (r *Recorder) record(
+	ctx context.Context,
+	key string,
+	req Request,
+	options []llms.CallOption,
+	streamingFunc func(context.Context, []byte) error,
+) (*llms.ContentResponse, error) {
+	c := Cassette{Request: req}
+	if streamingFunc != nil {
+		options = append(append([]llms.CallOption(nil), options...), llms.WithStreamingFunc(
+			func(ctx context.Context, chunk []byte) error {
+				c.Chunks = append(c.Chunks, string(chunk))
+				return streamingFunc(ctx, chunk)
+			},
+		))
+	}
+
+	rsp, err := r.opts.model.GenerateContent(ctx, req.Messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	c.Response = rsp
+
+	if err := r.save(key, c); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func (r *Recorder) replay(ctx context.Context, key string, streamingFunc func(context.Context, []byte) error) (*llms.ContentResponse, error) {
+	c, err := r.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if streamingFunc != nil {
+		chunks := c.Chunks
+		if len(chunks) == 0 && len(c.Response.Choices) > 0 {
+			chunks = []string{c.Response.Choices[0].Content}
+		}
+		for _, chunk := range chunks {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := streamingFunc(ctx, []byte(chunk)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return c.Response, ctx.Err()
+}
+
+// Path returns the cassette file of the request with the given key.
+func (r *Recorder) Path(key string) string {
+	return filepath.Join(r.opts.dir, key+".json")
+}
+
+func (r *Recorder) load(key string) (Cassette, error) {
+	var c Cassette
+	data, err := os.ReadFile(r.Path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, errbook.Wrap("No cassette for the request "+key+" in "+r.opts.dir+", record it first.", ErrNotRecorded)
+	}
+	if err != nil {
+		return c, errbook.Wrap("Could not read the cassette "+r.Path(key), err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, errbook.Wrap("Could not parse the cassette "+r.Path(key), err)
+	}
+	if c.Response == nil {
+		return c, errbook.New("The cassette %s has no response.", r.Path(key))
+	}
+	return c, nil
+}
+
+// save writes the cassette through a temporary file, so a replay never
+// reads a partial one.
+func (r *Recorder) save(key string, c Cassette) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errbook.Wrap("Could not encode the cassette.", err)
+	}
+	if err := os.MkdirAll(r.opts.dir, 0o755); err != nil { //nolint:mnd
+		return errbook.Wrap("Could not create the cassette directory.", err)
+	}
+
+	tmp, err := os.CreateTemp(r.opts.dir, key+".*.tmp")
+	if err != nil {
+		return errbook.Wrap("Could not write the cassette.", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return errbook.Wrap("Could not write the cassette.", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errbook.Wrap("Could not write the cassette.", err)
+	}
+	if err := os.Rename(tmp.Name(), r.Path(key)); err != nil {
+		return errbook.Wrap("Could not write the cassette.", err)
+	}
+	return nil
+}
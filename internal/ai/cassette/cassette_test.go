@@ -0,0 +1,131 @@
+package cassette
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+// chunkModel streams its answer in the given chunks.
+type chunkModel struct {
+	chunks []string
+	calls  int
+}
+
+func (m *chunkModel) GenerateContent(ctx context.Context, _ []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var content string
+	for _, chunk := range m.chunks {
+		if o.StreamingFunc != nil {
+			if err := o.StreamingFunc(ctx, []byte(chunk)); err != nil {
+				return nil, err
+			}
+		}
+		content += chunk
+	}
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{{Content: content, StopReason: "stop"}},
+		Usage:   llms.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+	}, nil
+}
+
+func question(text string) []llms.MessageContent {
+	return []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, text)}
+}
+
+func collect(chunks *[]string) llms.CallOption {
+	return llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		*chunks = append(*chunks, string(chunk))
+		return nil
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replays recorded streams", func(t *testing.T) {
+		dir := t.TempDir()
+		model := &chunkModel{chunks: []string{"Hello", ", ", "world"}}
+		recorder, err := New(WithDir(dir), WithMode(ModeRecord), WithModel(model))
+		require.NoError(t, err)
+
+		var recorded []string
+		want, err := recorder.GenerateContent(ctx, question("hi"), llms.WithModel("gpt"), collect(&recorded))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hello", ", ", "world"}, recorded)
+
+		player, err := New(WithDir(dir), WithMode(ModeReplay))
+		require.NoError(t, err)
+		var replayed []string
+		got, err := player.GenerateContent(ctx, question("hi"), llms.WithModel("gpt"), collect(&replayed))
+		require.NoError(t, err)
+		assert.Equal(t, recorded, replayed)
+		assert.Equal(t, want, got)
+		assert.Equal(t, 5, got.Usage.TotalTokens)
+		assert.Equal(t, 1, model.calls)
+	})
+
+	t.Run("streams answers recorded without streaming", func(t *testing.T) {
+		dir := t.TempDir()
+		recorder, err := New(WithDir(dir), WithMode(ModeRecord), WithModel(&chunkModel{chunks: []string{"a", "b"}}))
+		require.NoError(t, err)
+		_, err = recorder.GenerateContent(ctx, question("hi"))
+		require.NoError(t, err)
+
+		player, err := New(WithDir(dir), WithMode(ModeReplay))
+		require.NoError(t, err)
+		var replayed []string
+		_, err = player.GenerateContent(ctx, question("hi"), collect(&replayed))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ab"}, replayed)
+	})
+
+	t.Run("requests are keyed by messages and options", func(t *testing.T) {
+		dir := t.TempDir()
+		recorder, err := New(WithDir(dir), WithMode(ModeRecord), WithModel(&chunkModel{chunks: []string{"a"}}))
+		require.NoError(t, err)
+		_, err = recorder.GenerateContent(ctx, question("hi"), llms.WithModel("gpt"))
+		require.NoError(t, err)
+
+		player, err := New(WithDir(dir), WithMode(ModeReplay))
+		require.NoError(t, err)
+		_, err = player.GenerateContent(ctx, question("hello"), llms.WithModel("gpt"))
+		assert.ErrorIs(t, err, ErrNotRecorded)
+		_, err = player.GenerateContent(ctx, question("hi"), llms.WithModel("other"))
+		assert.ErrorIs(t, err, ErrNotRecorded)
+		_, err = player.GenerateContent(ctx, question("hi"), llms.WithModel("gpt"), llms.WithTemperature(0.5))
+		assert.ErrorIs(t, err, ErrNotRecorded)
+	})
+
+	t.Run("stops replaying on canceled requests", func(t *testing.T) {
+		dir := t.TempDir()
+		recorder, err := New(WithDir(dir), WithMode(ModeRecord), WithModel(&chunkModel{chunks: []string{"a"}}))
+		require.NoError(t, err)
+		_, err = recorder.GenerateContent(ctx, question("hi"))
+		require.NoError(t, err)
+
+		player, err := New(WithDir(dir), WithMode(ModeReplay))
+		require.NoError(t, err)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = player.GenerateContent(canceled, question("hi"))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		_, err := New(WithMode(ModeReplay))
+		assert.Error(t, err)
+		_, err = New(WithDir(t.TempDir()), WithMode("rewind"))
+		assert.Error(t, err)
+		_, err = New(WithDir(t.TempDir()), WithMode(ModeRecord))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,39 @@
+package cassette
+
+const (
+	// ModeRecord sends the requests to the model and records the answers.
+	ModeRecord = "record"
+	// ModeReplay answers the requests from the recorded cassettes, the
+	// model is never called.
+	ModeReplay = "replay"
+)
+
+type cassetteOptions struct {
+	dir   string
+	mode  string
+	model Model
+}
+
+// Option is a functional option for the cassette model.
+type Option func(*cassetteOptions)
+
+// WithDir sets the directory the cassette files are kept in.
+func WithDir(dir string) Option {
+	return func(opts *cassetteOptions) {
+		opts.dir = dir
+	}
+}
+
+// WithMode sets whether the requests are recorded or replayed.
+func WithMode(mode string) Option {
+	return func(opts *cassetteOptions) {
+		opts.mode = mode
+	}
+}
+
+// WithModel sets the model the requests are recorded from.
+func WithModel(model Model) Option {
+	return func(opts *cassetteOptions) {
+		opts.model = model
+	}
+}
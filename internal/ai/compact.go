@@ -0,0 +1,180 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/x/exp/ordered"
+	"github.com/coding-hui/common/util/slices"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+)
+
+const (
+	// compactKeepTurns is the number of recent turns compaction keeps verbatim.
+	compactKeepTurns = 2
+
+	summaryPrefix = "Summary of the earlier conversation:\n\n"
+
+	summarizePrompt = `You compact long conversations between a user and an AI assistant.
+Summarize the conversation you are given so that the assistant can continue it without the original messages.
+Keep the goals of the user, the decisions made, file names, code identifiers, commands and open questions.
+Leave out greetings and anything that was superseded later. Answer with the summary only.`
+)
+
+// CompactResult describes a compacted conversation.
+type CompactResult struct {
+	// Summarized is the number of messages replaced by the summary.
+	Summarized int
+	// Kept is the number of recent messages kept verbatim.
+	Kept int
+	// ArchiveID is the conversation the original transcript is archived in.
+	ArchiveID string
+}
+
+// Compact summarizes the older turns of the conversation into a single
+// system message, keeping the most recent turns. The original transcript is
+// saved as a new conversation before the compacted history replaces it.
+// A conversation too short to compact is left alone, Summarized is zero.
+func (e *Engine) Compact(ctx context.Context, convoID string) (*CompactResult, error) {
+	if convoID == "" {
+		return nil, errbook.New("There is no conversation to compact yet.")
+	}
+
+	store := e.convoStore
+	messages, err := store.Messages(ctx, convoID)
+	if err != nil {
+		return nil, errbook.Wrap("Failed to read the conversation.", err)
+	}
+
+	older, recent := splitRecentTurns(messages, compactKeepTurns)
+	prompts, turns := splitSystemMessages(older)
+	if len(turns) == 0 {
+		return &CompactResult{Kept: len(messages)}, nil
+	}
+
+	summary, err := e.summarize(ctx, turns)
+	if err != nil {
+		return nil, errbook.Wrap("Failed to summarize the conversation.", err)
+	}
+
+	archiveID := convo.NewConversationID()
+	if err := store.SetMessages(ctx, archiveID, append([]llms.ChatMessage(nil), messages...)); err != nil {
+		return nil, errbook.Wrap("Failed to archive the conversation.", err)
+	}
+	title, role := convoID[:convo.Sha1short], e.role
+	if found, err := store.GetConversation(ctx, convoID); err == nil {
+		title, role = found.Title, ordered.First(found.Role, role)
+	}
+	if err := store.SaveConversation(ctx, archiveID, title+" (archived)", e.Config.CurrentModel.Name, role); err != nil {
+		return nil, errbook.Wrap("Failed to archive the conversation.", err)
+	}
+
+	compacted := make([]llms.ChatMessage, 0, len(prompts)+1+len(recent))
+	compacted = append(compacted, prompts...)
+	compacted = append(compacted, llms.SystemChatMessage{Content: summaryPrefix + summary})
+	compacted = append(compacted, recent...)
+	if err := store.SetMessages(ctx, convoID, compacted); err != nil {
+		return nil, errbook.Wrap("Failed to save the compacted conversation.", err)
+	}
+
+	return &CompactResult{
+		Summarized: len(turns),
+		Kept:       len(recent),
+		ArchiveID:  archiveID,
+	}, nil
+}
+
+// compactThreshold returns the size in characters above which a continued
+// conversation is compacted, zero if it never is.
+func (e *Engine) compactThreshold() int {
+	switch t := e.Config.CompactThreshold; {
+	case t < 0:
+		return 0
+	case t > 0:
+		return t
+	default:
+		return e.InputBudget() * 3 / 4
+	}
+}
+
+// autoCompact compacts the continued conversation once its stored messages
+// exceed the threshold. It returns a warning telling what happened.
+func (e *Engine) autoCompact(ctx context.Context, history []llms.ChatMessage) (string, bool) {
+	readID := e.Config.CacheReadFromID
+	threshold := e.compactThreshold()
+	if threshold <= 0 || readID != e.Config.CacheWriteToID || MessagesSize(history) <= threshold {
+		return "", false
+	}
+
+	result, err := e.Compact(ctx, readID)
+	if err != nil {
+		return fmt.Sprintf("Could not compact the conversation: %v", err), false
+	}
+	if result.Summarized == 0 {
+		return "", false
+	}
+	return fmt.Sprintf(
+		"Compacted the conversation by summarizing %d older messages, the original is archived as %s.",
+		result.Summarized, result.ArchiveID[:convo.Sha1short],
+	), true
+}
+
+func (e *Engine) summarize(ctx context.Context, turns []llms.ChatMessage) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range turns {
+		_, _ = fmt.Fprintf(&transcript, "%s: %s\n\n", msg.GetType(), msg.GetContent())
+	}
+
+	messages, _ := fitToBudget(nil, []llms.ChatMessage{
+		llms.SystemChatMessage{Content: summarizePrompt},
+		llms.HumanChatMessage{Content: transcript.String()},
+	}, e.InputBudget())
+
+	var streamed atomic.Bool
+	rsp, _, err := e.generateWithFallback(ctx, 0, slices.Map(messages, convert), e.callOptions(), &streamed)
+	if err != nil {
+		return "", err
+	}
+	if len(rsp.Choices) == 0 || strings.TrimSpace(rsp.Choices[0].Content) == "" {
+		return "", errbook.New("The model returned an empty summary.")
+	}
+	return strings.TrimSpace(rsp.Choices[0].Content), nil
+}
+
+// splitRecentTurns splits the messages before the last keep turns, a turn
+// starting with a human message.
+func splitRecentTurns(messages []llms.ChatMessage, keep int) ([]llms.ChatMessage, []llms.ChatMessage) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].GetType() != llms.ChatMessageTypeHuman {
+			continue
+		}
+		keep--
+		if keep == 0 {
+			return messages[:i], messages[i:]
+		}
+	}
+	return nil, messages
+}
+
+// splitSystemMessages separates the distinct system messages from the turns.
+// Summaries of earlier compactions count as turns, to be summarized again.
+func splitSystemMessages(messages []llms.ChatMessage) ([]llms.ChatMessage, []llms.ChatMessage) {
+	var prompts, turns []llms.ChatMessage
+	seen := make(map[string]bool)
+	for _, msg := range messages {
+		if msg.GetType() != llms.ChatMessageTypeSystem || strings.HasPrefix(msg.GetContent(), summaryPrefix) {
+			turns = append(turns, msg)
+			continue
+		}
+		if !seen[msg.GetContent()] {
+			seen[msg.GetContent()] = true
+			prompts = append(prompts, msg)
+		}
+	}
+	return prompts, turns
+}
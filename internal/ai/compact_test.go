@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+func storeTurns(t *testing.T, engine *Engine, convoID string, turns int) []llms.ChatMessage {
+	t.Helper()
+	var messages []llms.ChatMessage
+	for i := 0; i < turns; i++ {
+		messages = append(messages,
+			llms.SystemChatMessage{Content: "system prompt"},
+			llms.HumanChatMessage{Content: "question " + strings.Repeat("q", 50)},
+			llms.AIChatMessage{Content: "answer " + strings.Repeat("a", 50)},
+		)
+	}
+	require.NoError(t, engine.GetConvoStore().SetMessages(context.Background(), convoID, messages))
+	return messages
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	convoID := strings.Repeat("c", 40)
+
+	t.Run("summarizes older turns", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("the user asked questions", llms.Usage{})}}
+		engine := newTestEngine(t, model)
+		original := storeTurns(t, engine, convoID, 3)
+
+		result, err := engine.Compact(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Summarized)
+		assert.Equal(t, 5, result.Kept)
+
+		store := engine.GetConvoStore()
+		compacted, err := store.Messages(ctx, convoID)
+		require.NoError(t, err)
+		require.Len(t, compacted, 7)
+		assert.Equal(t, llms.SystemChatMessage{Content: "system prompt"}, compacted[0])
+		assert.Equal(t, llms.SystemChatMessage{Content: summaryPrefix + "the user asked questions"}, compacted[1])
+		assert.Equal(t, original[4:], compacted[2:])
+
+		archived, err := store.Messages(ctx, result.ArchiveID)
+		require.NoError(t, err)
+		assert.Equal(t, original, archived)
+		found, err := store.GetConversation(ctx, result.ArchiveID)
+		require.NoError(t, err)
+		assert.Contains(t, found.Title, "(archived)")
+
+		request := model.requests[0]
+		require.Len(t, request, 2)
+		assert.Equal(t, summarizePrompt, textOf(request[0]))
+		assert.Contains(t, textOf(request[1]), "question")
+		assert.Empty(t, model.options[0].Tools)
+	})
+
+	t.Run("leaves short conversations alone", func(t *testing.T) {
+		model := &fakeModel{}
+		engine := newTestEngine(t, model)
+		storeTurns(t, engine, convoID, 2)
+
+		result, err := engine.Compact(ctx, convoID)
+		require.NoError(t, err)
+		assert.Zero(t, result.Summarized)
+		assert.Empty(t, model.requests)
+	})
+
+	t.Run("requires a conversation", func(t *testing.T) {
+		_, err := newTestEngine(t, &fakeModel{}).Compact(ctx, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("compacts continued conversations above the threshold", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{
+			textResponse("summary", llms.Usage{}),
+			textResponse("answer", llms.Usage{}),
+		}}
+		engine := newTestEngine(t, model)
+		engine.Config.NoCache = false
+		engine.Config.CompactThreshold = 200
+		engine.Config.CacheReadFromID = convoID
+		engine.Config.CacheWriteToID = convoID
+		storeTurns(t, engine, convoID, 4)
+
+		out, err := engine.CreateCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "next"}})
+		require.NoError(t, err)
+		require.Len(t, out.Warnings, 1)
+		assert.Contains(t, out.Warnings[0], "Compacted the conversation")
+
+		request := model.requests[1]
+		assert.Contains(t, textOf(request[1]), summaryPrefix)
+	})
+}
+
+func TestSplitRecentTurns(t *testing.T) {
+	messages := []llms.ChatMessage{
+		llms.HumanChatMessage{Content: "1"},
+		llms.AIChatMessage{Content: "1"},
+		llms.HumanChatMessage{Content: "2"},
+		llms.AIChatMessage{Content: "2"},
+	}
+
+	older, recent := splitRecentTurns(messages, 1)
+	assert.Equal(t, messages[:2], older)
+	assert.Equal(t, messages[2:], recent)
+
+	older, recent = splitRecentTurns(messages, 3)
+	assert.Empty(t, older)
+	assert.Equal(t, messages, recent)
+}
+
+func textOf(msg llms.MessageContent) string {
+	return msg.Parts[0].(llms.TextContent).Text
+}
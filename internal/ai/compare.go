@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+// Answer is the answer of one of the compared engines.
+type Answer struct {
+	// Model is the model asked, Output.Model the one that answered, which
+	// differs when the request failed over to a fallback model.
+	Model   string
+	Output  *StreamCompletionOutput
+	Latency time.Duration
+	Err     error
+}
+
+// Compare sends the same messages to every engine concurrently and returns
+// the answers in the order of the engines. A failing engine does not stop
+// the others, its error is kept in its answer.
+func Compare(ctx context.Context, engines []*Engine, messages []llms.ChatMessage, opts ...CompletionOption) []Answer {
+	answers := make([]Answer, len(engines))
+
+	var wg sync.WaitGroup
+	for i, engine := range engines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answers[i] = engine.answer(ctx, slices.Clone(messages), opts...)
+		}()
+	}
+	wg.Wait()
+
+	return answers
+}
+
+// answer runs a streaming completion to its end, the input and the answer
+// are stored in the written conversation like for any other completion.
+func (e *Engine) answer(ctx context.Context, messages []llms.ChatMessage, opts ...CompletionOption) Answer {
+	answer := Answer{Model: e.Config.Model}
+
+	start := time.Now()
+	stream, err := e.CreateStreamCompletion(ctx, messages, opts...)
+	if err == nil {
+		answer.Output, err = stream.Wait()
+	}
+	answer.Latency = time.Since(start)
+	answer.Err = err
+
+	return answer
+}
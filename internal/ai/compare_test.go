@@ -0,0 +1,54 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+func TestCompare(t *testing.T) {
+	usage := llms.Usage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6}
+	first := newTestEngine(t, &fakeModel{responses: []*llms.ContentResponse{textResponse("four", usage)}})
+	second := newTestEngine(t, &failingModel{err: errors.New("model unavailable")})
+	third := newTestEngine(t, echoModel{})
+
+	answers := Compare(context.Background(), []*Engine{first, second, third}, []llms.ChatMessage{
+		llms.HumanChatMessage{Content: "two plus two"},
+	})
+	require.Len(t, answers, 3)
+
+	require.NoError(t, answers[0].Err)
+	assert.Equal(t, "fake", answers[0].Model)
+	assert.Equal(t, "four", answers[0].Output.Content)
+	assert.Equal(t, "fake", answers[0].Output.Model)
+	assert.Equal(t, usage, answers[0].Output.Usage)
+	assert.Positive(t, answers[0].Latency)
+
+	assert.ErrorContains(t, answers[1].Err, "model unavailable")
+	assert.Nil(t, answers[1].Output)
+
+	require.NoError(t, answers[2].Err)
+	assert.Equal(t, "two plus two", answers[2].Output.Content)
+
+	t.Run("answers are stored in the written conversations", func(t *testing.T) {
+		engine := newTestEngine(t, echoModel{})
+		engine.Config.NoCache = false
+		engine.Config.CacheWriteToID = "compared"
+
+		answers := Compare(context.Background(), []*Engine{engine}, []llms.ChatMessage{
+			llms.HumanChatMessage{Content: "hello"},
+		})
+		require.NoError(t, answers[0].Err)
+
+		messages, err := engine.GetConvoStore().Messages(context.Background(), "compared")
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, llms.ChatMessageTypeHuman, messages[0].GetType())
+		assert.Equal(t, "hello", messages[1].GetContent())
+	})
+}
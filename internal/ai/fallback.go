@@ -0,0 +1,207 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	sdk "github.com/sashabaranov/go-openai"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/anthropic"
+	"github.com/coding-hui/ai-terminal/internal/ai/gemini"
+	"github.com/coding-hui/ai-terminal/internal/ai/ollama"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// candidate is a model the engine can send a request to, the configured
+// model followed by its chain of fallbacks.
+type candidate struct {
+	config options.Model
+	api    string
+	model  Model
+}
+
+// candidate returns the i-th model of the fallback chain, creating its
+// client on first use. It returns false when the chain is exhausted.
+func (e *Engine) candidate(i int) (candidate, bool) {
+	e.fallbackMu.Lock()
+	defer e.fallbackMu.Unlock()
+
+	if len(e.chain) == 0 {
+		e.chain = []candidate{{
+			config: e.Config.CurrentModel,
+			api:    e.Config.CurrentAPI.Name,
+			model:  e.withRetry(e.model, e.Config.CurrentAPI),
+		}}
+	}
+
+	for len(e.chain) <= i {
+		last := e.chain[len(e.chain)-1].config
+		next, ok := e.nextFallback(last)
+		if !ok {
+			return candidate{}, false
+		}
+		e.chain = append(e.chain, next)
+	}
+
+	return e.chain[i], true
+}
+
+// candidateName returns the name of the i-th model of the fallback chain.
+func (e *Engine) candidateName(i int) string {
+	c, _ := e.candidate(i)
+	return c.config.Name
+}
+
+func (e *Engine) nextFallback(current options.Model) (candidate, bool) {
+	seen := make(map[string]bool, len(e.chain))
+	for _, c := range e.chain {
+		seen[c.config.Name] = true
+	}
+
+	for name := current.Fallback; name != ""; {
+		mod, ok := e.Config.Models[name]
+		if !ok {
+			klog.Warningf("fallback model %s is not in the settings file", name)
+			return candidate{}, false
+		}
+		if seen[mod.Name] {
+			return candidate{}, false
+		}
+		seen[mod.Name] = true
+
+		api, err := e.Config.GetAPI(mod.API)
+		if err == nil {
+			var model Model
+			model, err = e.modelFactory(mod, api)
+			if err == nil {
+				return candidate{config: mod, api: api.Name, model: e.withRetry(model, api)}, true
+			}
+		}
+		// skip fallbacks that cannot be set up, e.g. because of a missing key
+		klog.Warningf("skipping fallback model %s: %v", mod.Name, err)
+		name = mod.Fallback
+	}
+
+	return candidate{}, false
+}
+
+// generateWithFallback sends the request to the configured model, failing
+// over to the fallback chain starting at index start on provider errors.
+// It returns the response together with the index of the answering model.
+func (e *Engine) generateWithFallback(
+	ctx context.Context,
+	start int,
+	messages []llms.MessageContent,
+	opts []llms.CallOption,
+	streamed *atomic.Bool,
+) (*llms.ContentResponse, int, error) {
+	var lastErr error
+	images := hasImageParts(messages)
+	for i := start; ; i++ {
+		c, ok := e.candidate(i)
+		if !ok {
+			return nil, i - 1, lastErr
+		}
+
+		if images && !c.config.Vision {
+			// the selected model must take the images, fallbacks are skipped
+			lastErr = errNoVision(c.config.Name)
+			if i == 0 || c.config.Fallback == "" {
+				return nil, i, lastErr
+			}
+			continue
+		}
+
+		callOpts := append(append([]llms.CallOption(nil), opts...),
+			llms.WithModel(c.config.Name),
+			llms.WithMaxLength(c.config.MaxChars),
+		)
+		if images {
+			callOpts = append(callOpts, llms.WithMultiContent(true))
+		}
+		rsp, err := e.generate(ctx, c, messages, callOpts)
+		if err == nil {
+			e.recordUsage(ctx, c, rsp.Usage)
+			return rsp, i, nil
+		}
+		lastErr = err
+
+		// once chunks reached the user another model would repeat them
+		if ctx.Err() != nil || streamed.Load() || !shouldFailover(err) {
+			return nil, i, err
+		}
+		if c.config.Fallback == "" {
+			return nil, i, err
+		}
+		klog.V(1).Infof("%s failed, falling back to %s: %v", c.config.Name, c.config.Fallback, err)
+	}
+}
+
+// shouldFailover reports whether a request that failed with err may succeed
+// on another model: server errors, rate limits and exceeded context windows.
+func shouldFailover(err error) bool {
+	if isContextLengthExceeded(err) {
+		return true
+	}
+	status := statusCode(err)
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func statusCode(err error) int {
+	var (
+		openaiAPIErr     *sdk.APIError
+		openaiRequestErr *sdk.RequestError
+		arkAPIErr        *arkmodel.APIError
+		arkRequestErr    *arkmodel.RequestError
+		anthropicErr     *anthropic.Error
+		ollamaErr        *ollama.Error
+		geminiErr        *gemini.Error
+	)
+	switch {
+	case errors.As(err, &openaiAPIErr):
+		return openaiAPIErr.HTTPStatusCode
+	case errors.As(err, &openaiRequestErr):
+		return openaiRequestErr.HTTPStatusCode
+	case errors.As(err, &arkAPIErr):
+		return arkAPIErr.HTTPStatusCode
+	case errors.As(err, &arkRequestErr):
+		return arkRequestErr.HTTPStatusCode
+	case errors.As(err, &anthropicErr):
+		return anthropicErr.StatusCode
+	case errors.As(err, &ollamaErr):
+		return ollamaErr.StatusCode
+	case errors.As(err, &geminiErr):
+		return geminiErr.StatusCode
+	}
+	return 0
+}
+
+func isContextLengthExceeded(err error) bool {
+	var openaiAPIErr *sdk.APIError
+	if errors.As(err, &openaiAPIErr) {
+		if code, ok := openaiAPIErr.Code.(string); ok && code == "context_length_exceeded" {
+			return true
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"context_length_exceeded",
+		"maximum context length",
+		"context window",
+		"prompt is too long",
+		"too many tokens",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
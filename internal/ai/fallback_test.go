@@ -0,0 +1,183 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	sdk "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/anthropic"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// failingModel fails every request with err, after streaming chunk if set.
+type failingModel struct {
+	err   error
+	chunk string
+	calls atomic.Int32
+}
+
+func (m *failingModel) GenerateContent(ctx context.Context, _ []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls.Add(1)
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if m.chunk != "" && o.StreamingFunc != nil {
+		_ = o.StreamingFunc(ctx, []byte(m.chunk))
+	}
+	return nil, m.err
+}
+
+// newFallbackEngine is a test engine whose models fall back on each other,
+// primary → secondary → tertiary → primary.
+func newFallbackEngine(t *testing.T, models map[string]Model) *Engine {
+	t.Helper()
+	return newTestEngine(t, models["primary"], withFallbackModels(models))
+}
+
+// withFallbackModels configures the fallback chain of the models, which are
+// created from the given ones.
+func withFallbackModels(models map[string]Model) Option {
+	return func(e *Engine) {
+		e.Config.Model = "primary"
+		e.Config.API = "one"
+		e.Config.APIs = options.APIs{
+			{Name: "one", APIKey: "key"},
+			{Name: "two", APIKey: "key"},
+		}
+		e.Config.Models = map[string]options.Model{
+			"primary":   {Name: "primary", API: "one", Fallback: "secondary"},
+			"secondary": {Name: "secondary", API: "two", Fallback: "tertiary"},
+			"tertiary":  {Name: "tertiary", API: "one", Fallback: "primary"},
+		}
+		e.modelFactory = func(mod options.Model, _ options.API) (Model, error) {
+			m, ok := models[mod.Name]
+			if !ok {
+				return nil, fmt.Errorf("no model %s", mod.Name)
+			}
+			return m, nil
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+	input := []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}}
+	serverErr := &sdk.APIError{HTTPStatusCode: http.StatusBadGateway, Message: "bad gateway"}
+
+	t.Run("answers with the configured model", func(t *testing.T) {
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary": &fakeModel{responses: []*llms.ContentResponse{textResponse("from primary", llms.Usage{})}},
+		})
+
+		out, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, "from primary", out.Explanation)
+		assert.Equal(t, "primary", out.Model)
+	})
+
+	t.Run("chains fallbacks", func(t *testing.T) {
+		primary := &failingModel{err: serverErr}
+		secondary := &failingModel{err: &anthropic.Error{StatusCode: http.StatusTooManyRequests}}
+		tertiary := &fakeModel{responses: []*llms.ContentResponse{textResponse("from tertiary", llms.Usage{})}}
+		engine := newFallbackEngine(t, map[string]Model{"primary": primary, "secondary": secondary, "tertiary": tertiary})
+
+		out, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, "from tertiary", out.Explanation)
+		assert.Equal(t, "tertiary", out.Model)
+		assert.Equal(t, int32(1), primary.calls.Load())
+		assert.Equal(t, int32(1), secondary.calls.Load())
+		require.Len(t, tertiary.options, 1)
+		assert.Equal(t, "tertiary", tertiary.options[0].Model)
+	})
+
+	t.Run("stops when the chain loops back", func(t *testing.T) {
+		primary := &failingModel{err: serverErr}
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary":   primary,
+			"secondary": &failingModel{err: serverErr},
+			"tertiary":  &failingModel{err: serverErr},
+		})
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.Error(t, err)
+		assert.Equal(t, int32(1), primary.calls.Load())
+	})
+
+	t.Run("does not fail over on client errors", func(t *testing.T) {
+		secondary := &fakeModel{responses: []*llms.ContentResponse{textResponse("unused", llms.Usage{})}}
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary":   &failingModel{err: &sdk.APIError{HTTPStatusCode: http.StatusUnauthorized, Message: "bad key"}},
+			"secondary": secondary,
+		})
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.Error(t, err)
+		assert.Empty(t, secondary.requests)
+	})
+
+	t.Run("fails over when the context is exceeded", func(t *testing.T) {
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary": &failingModel{err: &sdk.APIError{
+				HTTPStatusCode: http.StatusBadRequest,
+				Code:           "context_length_exceeded",
+				Message:        "This model's maximum context length is 8192 tokens.",
+			}},
+			"secondary": &fakeModel{responses: []*llms.ContentResponse{textResponse("bigger window", llms.Usage{})}},
+		})
+
+		out, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, "secondary", out.Model)
+	})
+
+	t.Run("does not fail over after streaming started", func(t *testing.T) {
+		secondary := &fakeModel{responses: []*llms.ContentResponse{textResponse("unused", llms.Usage{})}}
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary":   &failingModel{err: serverErr, chunk: "partial"},
+			"secondary": secondary,
+		})
+
+		stream, err := engine.CreateStreamCompletion(ctx, input)
+		require.NoError(t, err)
+		_, err = stream.Wait()
+		require.Error(t, err)
+		assert.Empty(t, secondary.requests)
+	})
+
+	t.Run("reports the fallback model when interrupted", func(t *testing.T) {
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary":   &failingModel{err: serverErr},
+			"secondary": &blockingModel{canceled: make(chan struct{})},
+		})
+
+		stream, err := engine.CreateStreamCompletion(ctx, input)
+		require.NoError(t, err)
+		<-stream.Outputs()
+		stream.Close()
+
+		out, err := stream.Wait()
+		assert.ErrorIs(t, err, context.Canceled)
+		require.NotNil(t, out)
+		assert.True(t, out.IsInterrupt())
+		assert.Equal(t, "secondary", out.GetModel())
+	})
+}
+
+func TestShouldFailover(t *testing.T) {
+	assert.True(t, shouldFailover(&sdk.RequestError{HTTPStatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")}))
+	assert.True(t, shouldFailover(fmt.Errorf("wrapped: %w", &anthropic.Error{StatusCode: 529})))
+	assert.True(t, shouldFailover(errors.New("prompt is too long: 210000 tokens > 200000 maximum")))
+	assert.False(t, shouldFailover(&sdk.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "invalid"}))
+	assert.False(t, shouldFailover(errors.New("connection refused")))
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// CompletionOption configures a single completion request.
+type CompletionOption func(*completionOptions)
+
+type completionOptions struct {
+	format string
+}
+
+// WithFormat asks for the response in the given format, appending its
+// format text to the request. JSON responses are validated and the model
+// is asked once more if it did not answer with valid JSON.
+func WithFormat(format string) CompletionOption {
+	return func(o *completionOptions) {
+		o.format = format
+	}
+}
+
+func newCompletionOptions(opts ...CompletionOption) completionOptions {
+	var o completionOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// withFormatText appends the format text of the requested format to the
+// messages sent to the model, leaving the stored conversation untouched.
+func (e *Engine) withFormatText(messages []llms.MessageContent, format string) []llms.MessageContent {
+	text := e.Config.FormatText[format]
+	if format == "" || text == "" {
+		return messages
+	}
+	return append(messages, llms.TextParts(llms.ChatMessageTypeHuman, text))
+}
+
+// generateFormatted generates the answer in the requested format. JSON
+// answers are not streamed since they can only be checked once complete.
+func (e *Engine) generateFormatted(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	format string,
+	streamingFunc ...func(ctx context.Context, chunk []byte) error,
+) (*llms.ContentResponse, string, error) {
+	messages = e.withFormatText(messages, format)
+	if format != FormatJSON {
+		return e.generateContent(ctx, messages, streamingFunc...)
+	}
+
+	rsp, model, err := e.generateContent(ctx, messages)
+	if err != nil {
+		return nil, model, err
+	}
+
+	content, err := validateJSON(rsp.Choices[0].Content)
+	if err == nil {
+		rsp.Choices[0].Content = content
+		return rsp, model, nil
+	}
+	klog.V(1).Infof("%s answered with invalid JSON, asking again: %v", model, err)
+
+	messages = append(messages,
+		llms.TextParts(llms.ChatMessageTypeAI, rsp.Choices[0].Content),
+		llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprintf(
+			"Your response is not valid JSON (%v). Reply again with only the JSON, without any other text or enclosing backticks.", err,
+		)),
+	)
+	usage := rsp.Usage
+	rsp, model, err = e.generateContent(ctx, messages)
+	if err != nil {
+		return nil, model, err
+	}
+	rsp.Usage = addUsage(usage, rsp.Usage)
+
+	content, err = validateJSON(rsp.Choices[0].Content)
+	if err != nil {
+		return nil, model, errbook.Wrap("The model did not answer with valid JSON.", err)
+	}
+	rsp.Choices[0].Content = content
+	return rsp, model, nil
+}
+
+// validateJSON checks that content is a single JSON value, tolerating the
+// code fences models like to wrap it in. It returns the bare JSON.
+func validateJSON(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimPrefix(content, "json")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+		content = strings.TrimSpace(content)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(content))
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	if dec.More() {
+		return "", errbook.New("unexpected content after the JSON value")
+	}
+	return content, nil
+}
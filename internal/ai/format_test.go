@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func newFormatEngine(t *testing.T, model *fakeModel) *Engine {
+	t.Helper()
+	engine := newTestEngine(t, model)
+	engine.Config.FormatText = options.FormatText{
+		FormatMarkdown: "Answer in markdown.",
+		FormatJSON:     "Answer in json.",
+	}
+	return engine
+}
+
+func TestEngineFormat(t *testing.T) {
+	ctx := context.Background()
+	input := []llms.ChatMessage{llms.HumanChatMessage{Content: "list planets"}}
+
+	t.Run("passes stop sequences", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("mercury", llms.Usage{})}}
+		engine := newFormatEngine(t, model)
+		engine.Config.Stop = []string{"END"}
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"END"}, model.options[0].StopWords)
+	})
+
+	t.Run("appends the format text", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("# Planets", llms.Usage{})}}
+		engine := newFormatEngine(t, model)
+
+		out, err := engine.CreateCompletion(ctx, input, WithFormat(FormatMarkdown))
+		require.NoError(t, err)
+		assert.Equal(t, "# Planets", out.Explanation)
+
+		request := model.requests[0]
+		require.Len(t, request, 2)
+		assert.Equal(t, llms.TextParts(llms.ChatMessageTypeHuman, "Answer in markdown."), request[1])
+	})
+
+	t.Run("returns valid json without fences", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("```json\n[\"mercury\"]\n```", llms.Usage{})}}
+		engine := newFormatEngine(t, model)
+
+		out, err := engine.CreateCompletion(ctx, input, WithFormat(FormatJSON))
+		require.NoError(t, err)
+		assert.Equal(t, `["mercury"]`, out.Explanation)
+		assert.Len(t, model.requests, 1)
+	})
+
+	t.Run("asks again once on invalid json", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{
+			textResponse("Sure! Here you go: [\"mercury\"", llms.Usage{TotalTokens: 5}),
+			textResponse(`["mercury"]`, llms.Usage{TotalTokens: 7}),
+		}}
+		engine := newFormatEngine(t, model)
+
+		out, err := engine.CreateCompletion(ctx, input, WithFormat(FormatJSON))
+		require.NoError(t, err)
+		assert.Equal(t, `["mercury"]`, out.Explanation)
+		assert.Equal(t, 12, out.Usage.TotalTokens)
+
+		require.Len(t, model.requests, 2)
+		retry := model.requests[1]
+		require.Len(t, retry, 4)
+		assert.Equal(t, llms.ChatMessageTypeAI, retry[2].Role)
+		assert.Equal(t, llms.ChatMessageTypeHuman, retry[3].Role)
+	})
+
+	t.Run("fails after the second invalid answer", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{
+			textResponse("not json", llms.Usage{}),
+			textResponse("still not json", llms.Usage{}),
+		}}
+		engine := newFormatEngine(t, model)
+
+		_, err := engine.CreateCompletion(ctx, input, WithFormat(FormatJSON))
+		assert.Error(t, err)
+	})
+
+	t.Run("streams validated json at once", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse(`{"planet":"mercury"}`, llms.Usage{})}}
+		engine := newFormatEngine(t, model)
+
+		stream, err := engine.CreateStreamCompletion(ctx, input, WithFormat(FormatJSON))
+		require.NoError(t, err)
+
+		var outputs []StreamCompletionOutput
+		for out := range stream.Outputs() {
+			outputs = append(outputs, out)
+		}
+		_, err = stream.Wait()
+		require.NoError(t, err)
+		require.Len(t, outputs, 1)
+		assert.Equal(t, `{"planet":"mercury"}`, outputs[0].GetContent())
+		assert.Nil(t, model.options[0].StreamingFunc)
+	})
+}
+
+func TestValidateJSON(t *testing.T) {
+	for _, content := range []string{`{"a":1}`, " [1, 2] \n", "```json\n{\"a\":1}\n```", "```\n\"text\"\n```"} {
+		_, err := validateJSON(content)
+		assert.NoError(t, err, content)
+	}
+	for _, content := range []string{"", "{", `{"a":1} trailing`, "plain text"} {
+		_, err := validateJSON(content)
+		assert.Error(t, err, content)
+	}
+}
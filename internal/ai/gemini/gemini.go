@@ -0,0 +1,466 @@
+// Package gemini implements an ai.Model backed by the Google Gemini API.
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+var (
+	ErrEmptyResponse = errors.New("gemini: no response")
+	ErrMissingToken  = errors.New("gemini: missing the API key")
+	ErrMissingModel  = errors.New("gemini: model needs to be provided")
+)
+
+// Error is returned when the API answers with an error status or streams an
+// error.
+type Error struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gemini: %s (status %d): %s", e.Status, e.StatusCode, e.Message)
+}
+
+// Model talks to the Gemini API.
+type Model struct {
+	opts clientOptions
+}
+
+// New creates a Gemini model client.
+func New(opts ...Option) (*Model, error) {
+	o := clientOptions{
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.token == "" {
+		return nil, ErrMissingToken
+	}
+	if o.baseURL == "" {
+		o.baseURL = DefaultBaseURL
+	}
+	if o.httpClient == nil {
+		o.httpClient = http.DefaultClient
+	}
+	o.baseURL = strings.TrimSuffix(o.baseURL, "/")
+	return &Model{opts: o}, nil
+}
+
+// GenerateContent implements the ai.Model interface.
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	startTime := time.Now()
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	model := opts.Model
+	if model == "" {
+		model = m.opts.model
+	}
+	if model == "" {
+		return nil, ErrMissingModel
+	}
+
+	req, err := buildRequest(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := opts.StreamingFunc != nil
+	method := ":generateContent"
+	if stream {
+		method = ":streamGenerateContent?alt=sse"
+	}
+	url := m.opts.baseURL + "/models/" + strings.TrimPrefix(model, "models/") + method
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", m.opts.token)
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := m.opts.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, decodeError(resp)
+	}
+
+	var acc accumulator
+	if stream {
+		if err := acc.readStream(ctx, resp.Body, startTime, opts.StreamingFunc); err != nil {
+			return nil, err
+		}
+	} else {
+		var chunk generateResponse
+		if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+			return nil, fmt.Errorf("gemini: failed to decode response: %w", err)
+		}
+		if err := acc.add(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if !acc.received {
+		return nil, ErrEmptyResponse
+	}
+
+	return acc.toContentResponse(model, startTime), nil
+}
+
+// buildRequest maps the messages onto the Gemini turns: the system messages
+// are the system instruction, the replies of the model are model turns and
+// everything else, tool results included, are user turns.
+func buildRequest(messages []llms.MessageContent, opts llms.CallOptions) (*generateRequest, error) {
+	req := &generateRequest{GenerationConfig: &generationConfig{
+		StopSequences:   opts.StopWords,
+		MaxOutputTokens: opts.MaxTokens,
+		TopK:            opts.TopK,
+		CandidateCount:  opts.CandidateCount,
+	}}
+	// a zero temperature is not sent, the model uses its default
+	if opts.Temperature > 0 {
+		req.GenerationConfig.Temperature = &opts.Temperature
+	}
+	if opts.TopP > 0 {
+		req.GenerationConfig.TopP = &opts.TopP
+	}
+
+	var declarations []functionDeclaration
+	for _, t := range opts.Tools {
+		if t.Function == nil {
+			continue
+		}
+		declarations = append(declarations, functionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  t.Function.Parameters,
+		})
+	}
+	if len(declarations) > 0 {
+		req.Tools = []tool{{FunctionDeclarations: declarations}}
+	}
+
+	// tool results name the tool, older ones may only name the call
+	toolNames := map[string]string{}
+	var system []part
+	for _, mc := range messages {
+		if mc.Role == llms.ChatMessageTypeSystem {
+			for _, p := range mc.Parts {
+				if text, ok := p.(llms.TextContent); ok && text.Text != "" {
+					system = append(system, part{Text: text.Text})
+				}
+			}
+			continue
+		}
+
+		role := "user"
+		if mc.Role == llms.ChatMessageTypeAI {
+			role = "model"
+		}
+		parts, err := contentParts(mc, toolNames)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		// The API expects alternating roles, merge consecutive turns of the same role.
+		if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == role {
+			req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, parts...)
+			continue
+		}
+		req.Contents = append(req.Contents, content{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &content{Parts: system}
+	}
+
+	return req, nil
+}
+
+func contentParts(mc llms.MessageContent, toolNames map[string]string) ([]part, error) {
+	parts := make([]part, 0, len(mc.Parts))
+	for _, p := range mc.Parts {
+		switch p := p.(type) {
+		case llms.TextContent:
+			if p.Text == "" {
+				continue
+			}
+			parts = append(parts, part{Text: p.Text})
+		case llms.ToolCall:
+			if p.FunctionCall == nil {
+				continue
+			}
+			toolNames[p.ID] = p.FunctionCall.Name
+			args := json.RawMessage(p.FunctionCall.Arguments)
+			if !isObject(args) {
+				args = json.RawMessage("{}")
+			}
+			parts = append(parts, part{FunctionCall: &functionCall{Name: p.FunctionCall.Name, Args: args}})
+		case llms.ToolCallResponse:
+			name := p.Name
+			if name == "" {
+				name = toolNames[p.ToolCallID]
+			}
+			// the response of a function is an object
+			response := json.RawMessage(p.Content)
+			if !isObject(response) {
+				wrapped, err := json.Marshal(map[string]string{"content": p.Content})
+				if err != nil {
+					return nil, err
+				}
+				response = wrapped
+			}
+			parts = append(parts, part{FunctionResponse: &functionResponse{Name: name, Response: response}})
+		case llms.BinaryContent:
+			parts = append(parts, part{InlineData: &blob{
+				MIMEType: p.MIMEType,
+				Data:     base64.StdEncoding.EncodeToString(p.Data),
+			}})
+		case llms.ImageURLContent:
+			if mimeType, data, ok := strings.Cut(strings.TrimPrefix(p.URL, "data:"), ";base64,"); ok && strings.HasPrefix(p.URL, "data:") {
+				parts = append(parts, part{InlineData: &blob{MIMEType: mimeType, Data: data}})
+				continue
+			}
+			parts = append(parts, part{FileData: &fileData{FileURI: p.URL}})
+		default:
+			return nil, fmt.Errorf("gemini: unsupported content part %T", p)
+		}
+	}
+	return parts, nil
+}
+
+func isObject(raw json.RawMessage) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal(raw, &object) == nil && object != nil
+}
+
+// accumulator merges the chunks of a response.
+type accumulator struct {
+	received       bool
+	text           strings.Builder
+	thoughts       strings.Builder
+	toolCalls      []llms.ToolCall
+	finishReason   string
+	usage          usageMetadata
+	modelVersion   string
+	responseID     string
+	firstTokenTime time.Duration
+}
+
+func (a *accumulator) add(chunk generateResponse) error {
+	if chunk.Error != nil {
+		return &Error{StatusCode: chunk.Error.Code, Status: chunk.Error.Status, Message: chunk.Error.Message}
+	}
+	if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" && len(chunk.Candidates) == 0 {
+		return &Error{StatusCode: http.StatusBadRequest, Status: chunk.PromptFeedback.BlockReason, Message: "the prompt was blocked"}
+	}
+
+	a.received = true
+	if chunk.UsageMetadata != nil {
+		// the counts of a stream are the running totals
+		a.usage = *chunk.UsageMetadata
+	}
+	if chunk.ModelVersion != "" {
+		a.modelVersion = chunk.ModelVersion
+	}
+	if chunk.ResponseID != "" {
+		a.responseID = chunk.ResponseID
+	}
+	if len(chunk.Candidates) == 0 {
+		return nil
+	}
+
+	c := chunk.Candidates[0]
+	if c.FinishReason != "" {
+		a.finishReason = c.FinishReason
+	}
+	for _, p := range c.Content.Parts {
+		switch {
+		case p.FunctionCall != nil:
+			id := p.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", len(a.toolCalls))
+			}
+			arguments := string(p.FunctionCall.Args)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			a.toolCalls = append(a.toolCalls, llms.ToolCall{
+				ID:           id,
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: p.FunctionCall.Name, Arguments: arguments},
+			})
+		case p.Thought:
+			a.thoughts.WriteString(p.Text)
+		default:
+			a.text.WriteString(p.Text)
+		}
+	}
+	return nil
+}
+
+// readStream consumes the server-sent events of a streaming response, each
+// event being a chunk of the response.
+func (a *accumulator) readStream(
+	ctx context.Context,
+	body io.Reader,
+	startTime time.Time,
+	streamingFunc func(ctx context.Context, chunk []byte) error,
+) error {
+	var data bytes.Buffer
+	handle := func() error {
+		defer data.Reset()
+		if data.Len() == 0 {
+			return nil
+		}
+
+		var chunk generateResponse
+		if err := json.Unmarshal(data.Bytes(), &chunk); err != nil {
+			return fmt.Errorf("gemini: failed to decode the stream: %w", err)
+		}
+		before := a.text.Len()
+		if err := a.add(chunk); err != nil {
+			return err
+		}
+		if a.text.Len() == before {
+			return nil
+		}
+		if a.firstTokenTime == 0 {
+			a.firstTokenTime = time.Since(startTime)
+		}
+		return streamingFunc(ctx, []byte(a.text.String()[before:]))
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := handle(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return handle()
+}
+
+func (a *accumulator) toContentResponse(model string, startTime time.Time) *llms.ContentResponse {
+	choice := &llms.ContentChoice{
+		Content:          a.text.String(),
+		ReasoningContent: a.thoughts.String(),
+		StopReason:       stopReason(a.finishReason, len(a.toolCalls) > 0),
+		ToolCalls:        a.toolCalls,
+		GenerationInfo: map[string]any{
+			"id":            a.responseID,
+			"model":         model,
+			"model_version": a.modelVersion,
+			"finish_reason": a.finishReason,
+		},
+	}
+	if len(choice.ToolCalls) > 0 {
+		choice.FuncCall = choice.ToolCalls[0].FunctionCall
+	}
+
+	totalTime := time.Since(startTime)
+	firstTokenTime := a.firstTokenTime
+	if firstTokenTime == 0 {
+		firstTokenTime = totalTime
+	}
+	usage := llms.Usage{
+		FirstTokenTime: firstTokenTime,
+		TotalTime:      totalTime,
+		PromptTokens:   a.usage.PromptTokenCount,
+		// the thoughts are output tokens the model is billed for
+		CompletionTokens: a.usage.CandidatesTokenCount + a.usage.ThoughtsTokenCount,
+		TotalTokens:      a.usage.TotalTokenCount,
+		PromptTokensDetails: llms.PromptTokensDetail{
+			CachedTokens: a.usage.CachedContentTokenCount,
+		},
+		CompletionTokensDetails: llms.CompletionTokensDetails{
+			ReasoningTokens: a.usage.ThoughtsTokenCount,
+		},
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if totalTime > 0 {
+		usage.AverageTokensPerSecond = float64(usage.CompletionTokens) / totalTime.Seconds()
+	}
+
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{choice},
+		Usage:   usage,
+	}
+}
+
+// stopReason maps the Gemini finish reasons onto the OpenAI style values
+// used by the other providers.
+func stopReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "STOP":
+		if toolCalls {
+			return "tool_calls"
+		}
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &Error{StatusCode: resp.StatusCode, Status: http.StatusText(resp.StatusCode), Message: strings.TrimSpace(string(body))}
+
+	// errors of a stream come as an array of responses
+	var payload errorResponse
+	var payloads []errorResponse
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error.Message == "" {
+		if json.Unmarshal(body, &payloads) == nil && len(payloads) > 0 {
+			payload = payloads[0]
+		}
+	}
+	if payload.Error.Message != "" {
+		apiErr.Status = payload.Error.Status
+		apiErr.Message = payload.Error.Message
+	}
+	return apiErr
+}
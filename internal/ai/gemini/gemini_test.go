@@ -0,0 +1,248 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+// newTestModel returns a model talking to a test server, which checks the
+// request of the given method and hands its body to the handler.
+func newTestModel(t *testing.T, method string, handler func(w http.ResponseWriter, req generateRequest)) *Model {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/"+method, r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+
+		var req generateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); !assert.NoError(t, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(srv.Close)
+
+	m, err := New(WithToken("test-key"), WithBaseURL(srv.URL+"/v1beta"), WithModel("gemini-test"))
+	require.NoError(t, err)
+	return m
+}
+
+// badRequest fails the request of a handler whose checks failed, the checks
+// of a handler cannot stop the test.
+func badRequest(w http.ResponseWriter) {
+	http.Error(w, "unexpected request", http.StatusBadRequest)
+}
+
+func TestGenerateContent(t *testing.T) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "be brief"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "how are you?"),
+	}
+
+	t.Run("request mapping", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:generateContent", func(w http.ResponseWriter, req generateRequest) {
+			if !assert.NotNil(t, req.SystemInstruction) || !assert.Len(t, req.Contents, 1) ||
+				!assert.NotNil(t, req.GenerationConfig) || !assert.NotNil(t, req.GenerationConfig.Temperature) {
+				badRequest(w)
+				return
+			}
+			assert.Equal(t, []part{{Text: "be brief"}}, req.SystemInstruction.Parts)
+			assert.Equal(t, content{Role: "user", Parts: []part{{Text: "how are you?"}}}, req.Contents[0])
+			assert.Equal(t, []string{"STOP"}, req.GenerationConfig.StopSequences)
+			assert.Equal(t, 100, req.GenerationConfig.MaxOutputTokens)
+			assert.InDelta(t, 0.2, *req.GenerationConfig.Temperature, 1e-9)
+
+			_, _ = fmt.Fprint(w, `{
+				"candidates": [{"content": {"role": "model", "parts": [{"text": "fine, "}, {"text": "thanks"}]}, "finishReason": "STOP"}],
+				"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 3, "totalTokenCount": 13},
+				"modelVersion": "gemini-test-001", "responseId": "r1"
+			}`)
+		})
+
+		rsp, err := m.GenerateContent(context.Background(), messages,
+			llms.WithStopWords([]string{"STOP"}), llms.WithMaxTokens(100), llms.WithTemperature(0.2))
+		require.NoError(t, err)
+		require.Len(t, rsp.Choices, 1)
+		assert.Equal(t, "fine, thanks", rsp.Choices[0].Content)
+		assert.Equal(t, "stop", rsp.Choices[0].StopReason)
+		assert.Equal(t, 10, rsp.Usage.PromptTokens)
+		assert.Equal(t, 3, rsp.Usage.CompletionTokens)
+		assert.Equal(t, 13, rsp.Usage.TotalTokens)
+	})
+
+	t.Run("model of the call", func(t *testing.T) {
+		m := newTestModel(t, "gemini-other:generateContent", func(w http.ResponseWriter, _ generateRequest) {
+			_, _ = fmt.Fprint(w, `{"candidates": [{"content": {"parts": [{"text": "ok"}]}, "finishReason": "STOP"}]}`)
+		})
+
+		_, err := m.GenerateContent(context.Background(), messages, llms.WithModel("gemini-other"))
+		require.NoError(t, err)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:streamGenerateContent", func(w http.ResponseWriter, _ generateRequest) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: "+`{"candidates": [{"content": {"role": "model", "parts": [{"text": "thinking", "thought": true}]}}]}`+"\r\n\r\n")
+			_, _ = fmt.Fprint(w, "data: "+`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hel"}]}}], "usageMetadata": {"promptTokenCount": 5}}`+"\r\n\r\n")
+			_, _ = fmt.Fprint(w, "data: "+`{"candidates": [{"content": {"role": "model", "parts": [{"text": "lo"}]}, "finishReason": "MAX_TOKENS"}],`+
+				`"usageMetadata": {"promptTokenCount": 5, "candidatesTokenCount": 2, "thoughtsTokenCount": 4, "cachedContentTokenCount": 1, "totalTokenCount": 11}}`+"\r\n\r\n")
+		})
+
+		var chunks []string
+		rsp, err := m.GenerateContent(context.Background(), messages,
+			llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+				chunks = append(chunks, string(chunk))
+				return nil
+			}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hel", "lo"}, chunks)
+		assert.Equal(t, "Hello", rsp.Choices[0].Content)
+		assert.Equal(t, "thinking", rsp.Choices[0].ReasoningContent)
+		assert.Equal(t, "length", rsp.Choices[0].StopReason)
+		assert.Equal(t, 5, rsp.Usage.PromptTokens)
+		assert.Equal(t, 6, rsp.Usage.CompletionTokens)
+		assert.Equal(t, 11, rsp.Usage.TotalTokens)
+		assert.Equal(t, 1, rsp.Usage.PromptTokensDetails.CachedTokens)
+		assert.Equal(t, 4, rsp.Usage.CompletionTokensDetails.ReasoningTokens)
+	})
+
+	t.Run("function calls", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:generateContent", func(w http.ResponseWriter, req generateRequest) {
+			if !assert.Len(t, req.Tools, 1) || !assert.Len(t, req.Tools[0].FunctionDeclarations, 1) {
+				badRequest(w)
+				return
+			}
+			assert.Equal(t, "weather", req.Tools[0].FunctionDeclarations[0].Name)
+
+			_, _ = fmt.Fprint(w, `{"candidates": [{"content": {"role": "model", "parts": [
+				{"functionCall": {"name": "weather", "args": {"city": "Paris"}}}
+			]}, "finishReason": "STOP"}]}`)
+		})
+
+		rsp, err := m.GenerateContent(context.Background(), messages, llms.WithTools([]llms.Tool{{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:       "weather",
+				Parameters: map[string]any{"type": "object"},
+			},
+		}}))
+		require.NoError(t, err)
+		choice := rsp.Choices[0]
+		assert.Equal(t, "tool_calls", choice.StopReason)
+		require.Len(t, choice.ToolCalls, 1)
+		assert.NotEmpty(t, choice.ToolCalls[0].ID)
+		assert.Equal(t, "weather", choice.ToolCalls[0].FunctionCall.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, choice.ToolCalls[0].FunctionCall.Arguments)
+	})
+
+	t.Run("tool results", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:generateContent", func(w http.ResponseWriter, req generateRequest) {
+			if !assert.Len(t, req.Contents, 3) || !assert.NotEmpty(t, req.Contents[1].Parts) ||
+				!assert.NotNil(t, req.Contents[1].Parts[0].FunctionCall) ||
+				!assert.Len(t, req.Contents[2].Parts, 2) {
+				badRequest(w)
+				return
+			}
+			assert.Equal(t, "model", req.Contents[1].Role)
+			call := req.Contents[1].Parts[0].FunctionCall
+			assert.Equal(t, "weather", call.Name)
+			assert.JSONEq(t, `{"city":"Paris"}`, string(call.Args))
+
+			// the results of the tools are user turns
+			assert.Equal(t, "user", req.Contents[2].Role)
+			assert.Equal(t, "weather", req.Contents[2].Parts[0].FunctionResponse.Name)
+			assert.JSONEq(t, `{"content":"sunny"}`, string(req.Contents[2].Parts[0].FunctionResponse.Response))
+			assert.Equal(t, "weather", req.Contents[2].Parts[1].FunctionResponse.Name)
+			assert.JSONEq(t, `{"temp":21}`, string(req.Contents[2].Parts[1].FunctionResponse.Response))
+
+			_, _ = fmt.Fprint(w, `{"candidates": [{"content": {"parts": [{"text": "sunny"}]}, "finishReason": "STOP"}]}`)
+		})
+
+		_, err := m.GenerateContent(context.Background(), []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeHuman, "weather in Paris?"),
+			{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{llms.ToolCall{
+				ID: "call_0", Type: "function",
+				FunctionCall: &llms.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`},
+			}}},
+			{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "call_0", Name: "weather", Content: "sunny"}}},
+			{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "call_0", Content: `{"temp":21}`}}},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("images", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:generateContent", func(w http.ResponseWriter, req generateRequest) {
+			if !assert.Len(t, req.Contents, 1) || !assert.Len(t, req.Contents[0].Parts, 3) {
+				badRequest(w)
+				return
+			}
+			parts := req.Contents[0].Parts
+			assert.Equal(t, &blob{MIMEType: "image/png", Data: "AQ=="}, parts[1].InlineData)
+			assert.Equal(t, &blob{MIMEType: "image/jpeg", Data: "Ag=="}, parts[2].InlineData)
+
+			_, _ = fmt.Fprint(w, `{"candidates": [{"content": {"parts": [{"text": "cats"}]}, "finishReason": "STOP"}]}`)
+		})
+
+		_, err := m.GenerateContent(context.Background(), []llms.MessageContent{{
+			Role: llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{
+				llms.TextContent{Text: "what are these?"},
+				llms.BinaryContent{MIMEType: "image/png", Data: []byte{1}},
+				llms.ImageURLContent{URL: "data:image/jpeg;base64,Ag=="},
+			},
+		}})
+		require.NoError(t, err)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:generateContent", func(w http.ResponseWriter, _ generateRequest) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"error": {"code": 429, "message": "quota exceeded", "status": "RESOURCE_EXHAUSTED"}}`)
+		})
+
+		_, err := m.GenerateContent(context.Background(), messages)
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+		assert.Equal(t, "RESOURCE_EXHAUSTED", apiErr.Status)
+		assert.Equal(t, "quota exceeded", apiErr.Message)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:streamGenerateContent", func(w http.ResponseWriter, _ generateRequest) {
+			_, _ = fmt.Fprint(w, "data: "+`{"error": {"code": 503, "message": "overloaded", "status": "UNAVAILABLE"}}`+"\n\n")
+		})
+
+		_, err := m.GenerateContent(context.Background(), messages,
+			llms.WithStreamingFunc(func(context.Context, []byte) error { return nil }))
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	})
+
+	t.Run("blocked prompt", func(t *testing.T) {
+		m := newTestModel(t, "gemini-test:generateContent", func(w http.ResponseWriter, _ generateRequest) {
+			_, _ = fmt.Fprint(w, `{"promptFeedback": {"blockReason": "SAFETY"}}`)
+		})
+
+		_, err := m.GenerateContent(context.Background(), messages)
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, "SAFETY", apiErr.Status)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		_, err := New(WithModel("gemini-test"))
+		assert.ErrorIs(t, err, ErrMissingToken)
+	})
+}
@@ -0,0 +1,47 @@
+package gemini
+
+import (
+	"net/http"
+)
+
+// DefaultBaseURL is the base URL of the Gemini API.
+const DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+type clientOptions struct {
+	token      string
+	model      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Option is a functional option for the Gemini client.
+type Option func(*clientOptions)
+
+// WithToken passes the Gemini API key to the client.
+func WithToken(token string) Option {
+	return func(opts *clientOptions) {
+		opts.token = token
+	}
+}
+
+// WithModel sets the default model, used when the call does not name one.
+func WithModel(model string) Option {
+	return func(opts *clientOptions) {
+		opts.model = model
+	}
+}
+
+// WithBaseURL overrides the base URL of the API, e.g. for a proxy or the
+// v1 version of the API.
+func WithBaseURL(baseURL string) Option {
+	return func(opts *clientOptions) {
+		opts.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to talk to the API.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *clientOptions) {
+		opts.httpClient = client
+	}
+}
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+)
+
+// maxImageSize is the largest image file that is attached to a message.
+const maxImageSize = 20 << 20
+
+var imageExtensions = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// ImageChatMessage is a human message with images attached. Only its text is
+// kept when the conversation is saved.
+type ImageChatMessage struct {
+	Content string
+	Images  []llms.BinaryContent
+}
+
+func (m ImageChatMessage) GetType() llms.ChatMessageType { return llms.ChatMessageTypeHuman }
+func (m ImageChatMessage) GetContent() string            { return m.Content }
+
+// IsImageFile reports whether the file is an image that can be attached to a
+// message, judging by its extension.
+func IsImageFile(path string) bool {
+	_, ok := imageExtensions[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// LoadImage reads an image file to attach it to a message.
+func LoadImage(path string) (llms.BinaryContent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return llms.BinaryContent{}, errbook.Wrap("Could not read the image "+path, err)
+	}
+	if info.Size() > maxImageSize {
+		return llms.BinaryContent{}, errbook.New("The image %s is larger than %d MiB.", path, maxImageSize>>20)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return llms.BinaryContent{}, errbook.Wrap("Could not read the image "+path, err)
+	}
+
+	mimeType, ok := imageExtensions[strings.ToLower(filepath.Ext(path))]
+	if detected, _, _ := mime.ParseMediaType(http.DetectContentType(data)); strings.HasPrefix(detected, "image/") {
+		mimeType, ok = detected, true
+	}
+	if !ok {
+		return llms.BinaryContent{}, errbook.New("The file %s is not a png, jpeg, gif or webp image.", path)
+	}
+
+	return llms.BinaryContent{MIMEType: mimeType, Data: data}, nil
+}
+
+// WithImages attaches the images to the last human message.
+func WithImages(messages []llms.ChatMessage, images []llms.BinaryContent) []llms.ChatMessage {
+	if len(images) == 0 {
+		return messages
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].GetType() != llms.ChatMessageTypeHuman {
+			continue
+		}
+		messages = append([]llms.ChatMessage(nil), messages...)
+		attached := ImageChatMessage{Content: messages[i].GetContent()}
+		if m, ok := messages[i].(ImageChatMessage); ok {
+			attached.Images = append(attached.Images, m.Images...)
+		}
+		attached.Images = append(attached.Images, images...)
+		messages[i] = attached
+		return messages
+	}
+	return append(messages, ImageChatMessage{Images: images})
+}
+
+// hasImageParts reports whether the request carries images.
+func hasImageParts(messages []llms.MessageContent) bool {
+	for _, mc := range messages {
+		for _, part := range mc.Parts {
+			switch part.(type) {
+			case llms.ImageURLContent, llms.BinaryContent:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func errNoVision(model string) error {
+	return errbook.New(
+		"The model %s can't take images. Pick a vision model with --model, or set `vision: true` in the settings of the model if it can.",
+		model,
+	)
+}
+
+// imagePartsTransport fixes the requests of the OpenAI client of the SDK,
+// which sends image parts with the type of a text part.
+type imagePartsTransport struct {
+	base http.RoundTripper
+}
+
+func (t imagePartsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Method != http.MethodPost {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(body, []byte(`"image_url"`)) {
+		body = fixImageParts(body)
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(req)
+}
+
+func fixImageParts(body []byte) []byte {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body
+	}
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(request["messages"], &messages); err != nil {
+		return body
+	}
+
+	for _, msg := range messages {
+		var parts []map[string]json.RawMessage
+		if err := json.Unmarshal(msg["content"], &parts); err != nil {
+			continue
+		}
+		for _, part := range parts {
+			if _, ok := part["image_url"]; ok {
+				part["type"] = json.RawMessage(`"image_url"`)
+				delete(part, "text")
+			}
+		}
+		msg["content"], _ = json.Marshal(parts)
+	}
+
+	raw, err := json.Marshal(messages)
+	if err != nil {
+		return body
+	}
+	request["messages"] = raw
+	fixed, err := json.Marshal(request)
+	if err != nil {
+		return body
+	}
+	return fixed
+}
@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/anthropic"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func writePNG(t *testing.T, name string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+	return path
+}
+
+func TestLoadImage(t *testing.T) {
+	t.Run("detects the type", func(t *testing.T) {
+		img, err := LoadImage(writePNG(t, "shot.jpg"))
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", img.MIMEType)
+		assert.NotEmpty(t, img.Data)
+	})
+
+	t.Run("rejects other files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notes.txt")
+		require.NoError(t, os.WriteFile(path, []byte("plain text"), 0o600))
+		_, err := LoadImage(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("image files", func(t *testing.T) {
+		assert.True(t, IsImageFile("a/b.PNG"))
+		assert.True(t, IsImageFile("b.webp"))
+		assert.False(t, IsImageFile("main.go"))
+	})
+}
+
+func TestWithImages(t *testing.T) {
+	img := llms.BinaryContent{MIMEType: "image/png", Data: []byte{1}}
+	messages := []llms.ChatMessage{
+		llms.SystemChatMessage{Content: "system"},
+		llms.HumanChatMessage{Content: "what is this?"},
+	}
+
+	attached := WithImages(messages, []llms.BinaryContent{img})
+	assert.Equal(t, llms.HumanChatMessage{Content: "what is this?"}, messages[1])
+	assert.Equal(t, ImageChatMessage{Content: "what is this?", Images: []llms.BinaryContent{img}}, attached[1])
+	assert.Equal(t, messages, WithImages(messages, nil))
+
+	mc := convert(attached[1])
+	require.Len(t, mc.Parts, 2)
+	assert.Equal(t, llms.TextPart("what is this?"), mc.Parts[0])
+	assert.Equal(t, llms.ImageURLContent{URL: "data:image/png;base64,AQ=="}, mc.Parts[1])
+}
+
+func TestEngineImages(t *testing.T) {
+	ctx := context.Background()
+	input := WithImages(
+		[]llms.ChatMessage{llms.HumanChatMessage{Content: "what is this?"}},
+		[]llms.BinaryContent{{MIMEType: "image/png", Data: []byte{1}}},
+	)
+
+	t.Run("sends images to vision models", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("a cat", llms.Usage{})}}
+		engine := newTestEngine(t, model)
+		engine.Config.CurrentModel.Vision = true
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		assert.True(t, model.options[0].SupportMultiContent)
+		assert.IsType(t, llms.ImageURLContent{}, model.requests[0][0].Parts[1])
+	})
+
+	t.Run("refuses models that can't take images", func(t *testing.T) {
+		model := &fakeModel{}
+		engine := newTestEngine(t, model)
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't take images")
+		assert.Empty(t, model.requests)
+	})
+
+	t.Run("keeps text requests single content", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{textResponse("hi", llms.Usage{})}}
+		engine := newTestEngine(t, model)
+		engine.Config.CurrentModel.Vision = true
+
+		_, err := engine.CreateCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		assert.False(t, model.options[0].SupportMultiContent)
+	})
+
+	t.Run("skips fallbacks that can't take images", func(t *testing.T) {
+		tertiary := &fakeModel{responses: []*llms.ContentResponse{textResponse("a cat", llms.Usage{})}}
+		secondary := &fakeModel{}
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary":   &failingModel{err: &anthropic.Error{StatusCode: http.StatusBadGateway}},
+			"secondary": secondary,
+			"tertiary":  tertiary,
+		})
+		engine.Config.CurrentModel.Vision = true
+		tertiaryCfg := engine.Config.Models["tertiary"]
+		tertiaryCfg.Vision = true
+		engine.Config.Models["tertiary"] = tertiaryCfg
+
+		out, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, "tertiary", out.Model)
+		assert.Empty(t, secondary.requests)
+	})
+}
+
+func TestImagePartsTransport(t *testing.T) {
+	var parts []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+
+		var req struct {
+			Messages []struct {
+				Content []map[string]any `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		parts = req.Messages[0].Content
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"a cat"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	model, err := newModel(options.Model{Name: "gpt-4o"}, options.API{Name: "openai", BaseURL: srv.URL, APIKey: "key"})
+	require.NoError(t, err)
+
+	rsp, err := model.GenerateContent(context.Background(), []llms.MessageContent{convert(ImageChatMessage{
+		Content: "what is this?",
+		Images:  []llms.BinaryContent{{MIMEType: "image/png", Data: []byte{1}}},
+	})}, llms.WithMultiContent(true))
+	require.NoError(t, err)
+	assert.Equal(t, "a cat", rsp.Choices[0].Content)
+
+	require.Len(t, parts, 2)
+	assert.Equal(t, "text", parts[0]["type"])
+	assert.Equal(t, "image_url", parts[1]["type"])
+	assert.Equal(t, map[string]any{"url": "data:image/png;base64,AQ=="}, parts[1]["image_url"])
+}
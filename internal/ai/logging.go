@@ -11,7 +11,8 @@ import (
 	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
 )
 
-// LogHandler is a callback handler that prints to the standard output.
+// LogHandler is a callback handler that logs the requests and the responses
+// of the engine at verbosity 2, the streamed chunks at verbosity 3.
 type LogHandler struct{}
 
 var _ callbacks.Handler = LogHandler{}
@@ -56,43 +57,43 @@ func (l LogHandler) HandleLLMGenerateContentEnd(_ context.Context, res *llms.Con
 }
 
 func (l LogHandler) HandleStreamingFunc(_ context.Context, chunk []byte) {
-	fmt.Println(string(chunk))
+	klog.V(3).Info(string(chunk))
 }
 
 func (l LogHandler) HandleText(_ context.Context, text string) {
-	fmt.Println(text)
+	klog.V(2).Info(text)
 }
 
 func (l LogHandler) HandleLLMStart(_ context.Context, prompts []string) {
-	fmt.Println("Entering LLM with prompt:", prompts)
+	klog.V(2).Infof("Entering LLM with prompt: %v", prompts)
 }
 
 func (l LogHandler) HandleLLMError(_ context.Context, err error) {
-	fmt.Println("Exiting LLM with error:", err)
+	klog.V(2).Infof("Exiting LLM with error: %v", err)
 }
 
 func (l LogHandler) HandleChainStart(_ context.Context, inputs map[string]any) {
-	fmt.Println("Entering chain with inputs:", formatChainValues(inputs))
+	klog.V(2).Infof("Entering chain with inputs: %v", formatChainValues(inputs))
 }
 
 func (l LogHandler) HandleChainEnd(_ context.Context, outputs map[string]any) {
-	fmt.Println("Exiting chain with outputs:", formatChainValues(outputs))
+	klog.V(2).Infof("Exiting chain with outputs: %v", formatChainValues(outputs))
 }
 
 func (l LogHandler) HandleChainError(_ context.Context, err error) {
-	fmt.Println("Exiting chain with error:", err)
+	klog.V(2).Infof("Exiting chain with error: %v", err)
 }
 
 func (l LogHandler) HandleToolStart(_ context.Context, input string) {
-	fmt.Println("Entering tool with input:", removeNewLines(input))
+	klog.V(2).Infof("Entering tool with input: %v", removeNewLines(input))
 }
 
 func (l LogHandler) HandleToolEnd(_ context.Context, output string) {
-	fmt.Println("Exiting tool with output:", removeNewLines(output))
+	klog.V(2).Infof("Exiting tool with output: %v", removeNewLines(output))
 }
 
 func (l LogHandler) HandleToolError(_ context.Context, err error) {
-	fmt.Println("Exiting tool with error:", err)
+	klog.V(2).Infof("Exiting tool with error: %v", err)
 }
 
 func formatChainValues(values map[string]any) string {
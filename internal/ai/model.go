@@ -7,8 +7,17 @@ import (
 )
 
 const (
-	ModelTypeOpenAI = "openai"
-	ModelTypeARK    = "ark"
+	ModelTypeOpenAI    = "openai"
+	ModelTypeARK       = "ark"
+	ModelTypeAnthropic = "anthropic"
+	ModelTypeOllama    = "ollama"
+	ModelTypeGoogle    = "google"
+	ModelTypeGemini    = "gemini"
+	// ModelTypeAzure is an Azure OpenAI resource authenticated by its key.
+	ModelTypeAzure = "azure"
+	// ModelTypeAzureAD is an Azure OpenAI resource authenticated by a
+	// Microsoft Entra ID token.
+	ModelTypeAzureAD = "azure-ad"
 )
 
 type Model interface {
@@ -0,0 +1,357 @@
+// Package ollama implements an ai.Model backed by the native Ollama chat API,
+// so that local models keep their runtime options and can be discovered.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+var (
+	ErrEmptyResponse = errors.New("ollama: no response")
+	ErrMissingModel  = errors.New("ollama: model needs to be provided")
+)
+
+// Error is returned when the server answers with an error.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ollama: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// Model talks to an Ollama server.
+type Model struct {
+	opts clientOptions
+}
+
+// New creates an Ollama model client.
+func New(opts ...Option) (*Model, error) {
+	o := clientOptions{
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.baseURL == "" {
+		o.baseURL = DefaultBaseURL
+	}
+	if o.httpClient == nil {
+		o.httpClient = http.DefaultClient
+	}
+	o.baseURL = strings.TrimSuffix(o.baseURL, "/")
+	return &Model{opts: o}, nil
+}
+
+// ModelInfo describes a model installed on the server.
+type ModelInfo struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// ListModels returns the models installed on the server.
+func (m *Model) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.opts.baseURL+"/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.opts.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var tags struct {
+		Models []ModelInfo `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("ollama: failed to decode model list: %w", err)
+	}
+	return tags.Models, nil
+}
+
+// GenerateContent implements the ai.Model interface.
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	startTime := time.Now()
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	req, err := m.buildRequest(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.opts.baseURL+"/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.opts.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	return parseResponse(ctx, resp.Body, startTime, opts.StreamingFunc)
+}
+
+func (m *Model) buildRequest(messages []llms.MessageContent, opts llms.CallOptions) (*chatRequest, error) {
+	req := &chatRequest{
+		Model:  opts.Model,
+		Stream: opts.StreamingFunc != nil,
+		Options: &modelOptions{
+			NumCtx:      m.opts.numCtx,
+			NumPredict:  opts.MaxTokens,
+			Temperature: opts.Temperature,
+			TopP:        opts.TopP,
+			TopK:        opts.TopK,
+			Stop:        opts.StopWords,
+		},
+		KeepAlive: keepAlive(m.opts.keepAlive),
+	}
+	if req.Model == "" {
+		req.Model = m.opts.model
+	}
+	if req.Model == "" {
+		return nil, ErrMissingModel
+	}
+	if opts.JSONMode {
+		req.Format = "json"
+	}
+
+	for _, tool := range opts.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		req.Tools = append(req.Tools, toolDefinition{
+			Type: "function",
+			Function: functionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
+
+	for _, mc := range messages {
+		msg, err := toMessage(mc)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, msg)
+	}
+
+	return req, nil
+}
+
+func toMessage(mc llms.MessageContent) (message, error) {
+	var msg message
+	switch mc.Role {
+	case llms.ChatMessageTypeSystem:
+		msg.Role = "system"
+	case llms.ChatMessageTypeAI:
+		msg.Role = "assistant"
+	case llms.ChatMessageTypeTool:
+		msg.Role = "tool"
+	default:
+		msg.Role = "user"
+	}
+
+	var text []string
+	for _, part := range mc.Parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			text = append(text, p.Text)
+		case llms.ToolCall:
+			if p.FunctionCall == nil {
+				continue
+			}
+			args := json.RawMessage(p.FunctionCall.Arguments)
+			if !json.Valid(args) {
+				args = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, toolCall{Function: toolCallFunction{Name: p.FunctionCall.Name, Arguments: args}})
+		case llms.ToolCallResponse:
+			msg.ToolName = p.Name
+			text = append(text, p.Content)
+		case llms.BinaryContent:
+			msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(p.Data))
+		case llms.ImageURLContent:
+			// the server only takes the images themselves
+			_, data, ok := strings.Cut(p.URL, ";base64,")
+			if !ok || !strings.HasPrefix(p.URL, "data:") {
+				return msg, fmt.Errorf("ollama: images must be attached as data URLs, got %s", p.URL)
+			}
+			msg.Images = append(msg.Images, data)
+		default:
+			return msg, fmt.Errorf("ollama: unsupported content part %T", part)
+		}
+	}
+	msg.Content = strings.Join(text, "\n")
+
+	return msg, nil
+}
+
+// parseResponse reads the newline delimited JSON objects the server streams,
+// a non streaming response is a single object.
+func parseResponse(
+	ctx context.Context,
+	body io.Reader,
+	startTime time.Time,
+	streamingFunc func(ctx context.Context, chunk []byte) error,
+) (*llms.ContentResponse, error) {
+	var (
+		choice         = &llms.ContentChoice{}
+		final          chatResponse
+		firstTokenTime time.Duration
+		received       bool
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("ollama: failed to decode response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, &Error{StatusCode: http.StatusInternalServerError, Message: chunk.Error}
+		}
+		received = true
+
+		choice.ReasoningContent += chunk.Message.Thinking
+		for _, call := range chunk.Message.ToolCalls {
+			choice.ToolCalls = append(choice.ToolCalls, llms.ToolCall{
+				ID:   fmt.Sprintf("call_%d", len(choice.ToolCalls)),
+				Type: "function",
+				FunctionCall: &llms.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: string(call.Function.Arguments),
+				},
+			})
+		}
+		if content := chunk.Message.Content; content != "" {
+			if firstTokenTime == 0 {
+				firstTokenTime = time.Since(startTime)
+			}
+			choice.Content += content
+			if streamingFunc != nil {
+				if err := streamingFunc(ctx, []byte(content)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if chunk.Done {
+			final = chunk
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !received {
+		return nil, ErrEmptyResponse
+	}
+
+	choice.StopReason = final.DoneReason
+	if len(choice.ToolCalls) > 0 {
+		choice.StopReason = "tool_calls"
+		choice.FuncCall = choice.ToolCalls[0].FunctionCall
+	}
+	choice.GenerationInfo = map[string]any{
+		"model":       final.Model,
+		"done_reason": final.DoneReason,
+	}
+
+	totalTime := time.Since(startTime)
+	if firstTokenTime == 0 {
+		firstTokenTime = totalTime
+	}
+	usage := llms.Usage{
+		FirstTokenTime:   firstTokenTime,
+		TotalTime:        totalTime,
+		PromptTokens:     final.PromptEvalCount,
+		CompletionTokens: final.EvalCount,
+		TotalTokens:      final.PromptEvalCount + final.EvalCount,
+	}
+	if final.EvalDuration > 0 {
+		usage.AverageTokensPerSecond = float64(final.EvalCount) / time.Duration(final.EvalDuration).Seconds()
+	} else if totalTime > 0 {
+		usage.AverageTokensPerSecond = float64(final.EvalCount) / totalTime.Seconds()
+	}
+
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{choice},
+		Usage:   usage,
+	}, nil
+}
+
+// keepAlive encodes the keep_alive value, the server expects numbers to be
+// sent as JSON numbers and durations as strings.
+func keepAlive(value string) json.RawMessage {
+	if value == "" {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return json.RawMessage(value)
+	}
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
+
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error != "" {
+		apiErr.Message = payload.Error
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,163 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, req chatRequest)) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		handler(w, req)
+	})
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"models":[{"name":"llama3.2:3b","size":2019393189,"details":{"family":"llama","parameter_size":"3.2B"}}]}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGenerateContent(t *testing.T) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "be brief"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "hello"),
+	}
+
+	t.Run("streaming", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req chatRequest) {
+			assert.Equal(t, "llama3.2:3b", req.Model)
+			assert.True(t, req.Stream)
+			require.Len(t, req.Messages, 2)
+			assert.Equal(t, "system", req.Messages[0].Role)
+			assert.Equal(t, "user", req.Messages[1].Role)
+			assert.Equal(t, 8192, req.Options.NumCtx)
+			assert.Equal(t, []string{"STOP"}, req.Options.Stop)
+			assert.JSONEq(t, `"10m"`, string(req.KeepAlive))
+
+			for _, line := range []string{
+				`{"model":"llama3.2:3b","message":{"role":"assistant","content":"Hel"},"done":false}`,
+				`{"model":"llama3.2:3b","message":{"role":"assistant","content":"lo"},"done":false}`,
+				`{"model":"llama3.2:3b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":26,"eval_count":2,"eval_duration":1000000000}`,
+			} {
+				_, _ = fmt.Fprintln(w, line)
+			}
+		})
+
+		m, err := New(WithBaseURL(srv.URL+"/api"), WithModel("llama3.2:3b"), WithNumCtx(8192), WithKeepAlive("10m"))
+		require.NoError(t, err)
+
+		var chunks []string
+		rsp, err := m.GenerateContent(context.Background(), messages,
+			llms.WithStopWords([]string{"STOP"}),
+			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+				chunks = append(chunks, string(chunk))
+				return nil
+			}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hel", "lo"}, chunks)
+		assert.Equal(t, "Hello", rsp.Choices[0].Content)
+		assert.Equal(t, "stop", rsp.Choices[0].StopReason)
+		assert.Equal(t, 26, rsp.Usage.PromptTokens)
+		assert.Equal(t, 2, rsp.Usage.CompletionTokens)
+		assert.Equal(t, 28, rsp.Usage.TotalTokens)
+		assert.InDelta(t, 2.0, rsp.Usage.AverageTokensPerSecond, 0.001)
+	})
+
+	t.Run("non streaming with tool calls", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req chatRequest) {
+			assert.False(t, req.Stream)
+			assert.Nil(t, req.KeepAlive)
+			require.Len(t, req.Tools, 1)
+			assert.Equal(t, "grep", req.Tools[0].Function.Name)
+			_, _ = fmt.Fprint(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"grep","arguments":{"pattern":"main"}}}]},"done":true,"done_reason":"stop"}`)
+		})
+
+		m, err := New(WithBaseURL(srv.URL+"/api"), WithModel("llama3.2:3b"))
+		require.NoError(t, err)
+
+		rsp, err := m.GenerateContent(context.Background(), messages,
+			llms.WithTools([]llms.Tool{{Type: "function", Function: &llms.FunctionDefinition{Name: "grep"}}}))
+		require.NoError(t, err)
+		require.Len(t, rsp.Choices[0].ToolCalls, 1)
+		assert.Equal(t, "grep", rsp.Choices[0].ToolCalls[0].FunctionCall.Name)
+		assert.JSONEq(t, `{"pattern":"main"}`, rsp.Choices[0].ToolCalls[0].FunctionCall.Arguments)
+		assert.Equal(t, "tool_calls", rsp.Choices[0].StopReason)
+	})
+
+	t.Run("images", func(t *testing.T) {
+		srv := newTestServer(t, func(w http.ResponseWriter, req chatRequest) {
+			require.Len(t, req.Messages, 1)
+			assert.Equal(t, []string{"iVBORw==", "AQI="}, req.Messages[0].Images)
+			_, _ = fmt.Fprint(w, `{"message":{"role":"assistant","content":"a cat"},"done":true,"done_reason":"stop"}`)
+		})
+
+		m, err := New(WithBaseURL(srv.URL+"/api"), WithModel("llava"))
+		require.NoError(t, err)
+
+		rsp, err := m.GenerateContent(context.Background(), []llms.MessageContent{{
+			Role: llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{
+				llms.TextPart("what is this?"),
+				llms.ImageURLPart("data:image/png;base64,iVBORw=="),
+				llms.BinaryPart("image/png", []byte{1, 2}),
+			},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, "a cat", rsp.Choices[0].Content)
+
+		_, err = m.GenerateContent(context.Background(), []llms.MessageContent{{
+			Role:  llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{llms.ImageURLPart("https://example.com/a.png")},
+		}})
+		assert.Error(t, err)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":"model \"missing\" not found, try pulling it first"}`)
+		}))
+		defer srv.Close()
+
+		m, err := New(WithBaseURL(srv.URL+"/api"), WithModel("missing"))
+		require.NoError(t, err)
+
+		_, err = m.GenerateContent(context.Background(), messages)
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Contains(t, apiErr.Message, "try pulling it first")
+	})
+
+	t.Run("keep alive encoding", func(t *testing.T) {
+		assert.Equal(t, `-1`, string(keepAlive("-1")))
+		assert.Equal(t, `"5m"`, string(keepAlive("5m")))
+		assert.Nil(t, keepAlive(""))
+	})
+}
+
+func TestListModels(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	m, err := New(WithBaseURL(srv.URL + "/api"))
+	require.NoError(t, err)
+
+	models, err := m.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "llama3.2:3b", models[0].Name)
+	assert.Equal(t, "3.2B", models[0].Details.ParameterSize)
+}
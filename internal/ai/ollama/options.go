@@ -0,0 +1,55 @@
+package ollama
+
+import (
+	"net/http"
+)
+
+// DefaultBaseURL is the address of a local Ollama server.
+const DefaultBaseURL = "http://localhost:11434/api"
+
+type clientOptions struct {
+	model      string
+	baseURL    string
+	numCtx     int
+	keepAlive  string
+	httpClient *http.Client
+}
+
+// Option is a functional option for the Ollama client.
+type Option func(*clientOptions)
+
+// WithModel sets the default model, used when the call does not name one.
+func WithModel(model string) Option {
+	return func(opts *clientOptions) {
+		opts.model = model
+	}
+}
+
+// WithBaseURL sets the API address of the Ollama server, including the /api path.
+func WithBaseURL(baseURL string) Option {
+	return func(opts *clientOptions) {
+		opts.baseURL = baseURL
+	}
+}
+
+// WithNumCtx sets the context window (num_ctx) the model is loaded with.
+func WithNumCtx(numCtx int) Option {
+	return func(opts *clientOptions) {
+		opts.numCtx = numCtx
+	}
+}
+
+// WithKeepAlive controls how long the model stays loaded after a request,
+// e.g. "10m", or "-1" to keep it loaded.
+func WithKeepAlive(keepAlive string) Option {
+	return func(opts *clientOptions) {
+		opts.keepAlive = keepAlive
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to talk to the server.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *clientOptions) {
+		opts.httpClient = client
+	}
+}
@@ -0,0 +1,228 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/x/exp/ordered"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/util/rest"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+
+	// maxRetryAfter is the longest Retry-After the engine waits for, longer
+	// waits fail the request so that a fallback model can take over.
+	maxRetryAfter = time.Minute
+)
+
+// retryModel retries the requests of the wrapped model on transient errors
+// with exponential backoff, bounding every attempt by a timeout.
+type retryModel struct {
+	model      Model
+	maxRetries int
+	timeout    time.Duration
+
+	// sleep waits for d or until ctx is done, replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryModel(model Model, maxRetries int, timeout time.Duration) *retryModel {
+	return &retryModel{
+		model:      model,
+		maxRetries: maxRetries,
+		timeout:    timeout,
+		sleep:      sleepContext,
+	}
+}
+
+// withRetry wraps the model of the given API in a retryModel. The API retry
+// times take precedence over the max retries setting. ARK models are left
+// alone, their client retries and times out requests by itself.
+func (e *Engine) withRetry(model Model, api options.API) Model {
+	if providerType(api) == ModelTypeARK {
+		return model
+	}
+	return newRetryModel(model, ordered.First(api.RetryTimes, e.Config.MaxRetries), api.Timeout)
+}
+
+// GenerateContent implements Model. A streaming request is only retried
+// while nothing has been streamed, so the caller never sees a chunk twice.
+func (m *retryModel) GenerateContent(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	var streamed atomic.Bool
+	if stream := opts.StreamingFunc; stream != nil {
+		options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			if len(chunk) > 0 {
+				streamed.Store(true)
+			}
+			return stream(ctx, chunk)
+		}))
+	}
+
+	for attempt := 0; ; attempt++ {
+		hint := &retryAfterHint{}
+		rsp, err := m.attempt(withRetryAfterHint(ctx, hint), messages, options)
+		if err == nil {
+			return rsp, nil
+		}
+		if attempt >= m.maxRetries || ctx.Err() != nil || streamed.Load() || !shouldRetry(err) {
+			return nil, err
+		}
+
+		delay := backoff(attempt)
+		if after := hint.get(); after > 0 {
+			if after > maxRetryAfter {
+				return nil, err
+			}
+			delay = after
+		}
+		klog.V(1).Infof("request failed, retrying in %s (%d/%d): %v", delay, attempt+1, m.maxRetries, err)
+		if err := m.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (m *retryModel) attempt(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	options []llms.CallOption,
+) (*llms.ContentResponse, error) {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	return m.model.GenerateContent(ctx, messages, options...)
+}
+
+// shouldRetry reports whether err is transient: rate limits, server errors,
+// timeouts and dropped connections.
+func shouldRetry(err error) bool {
+	switch status := statusCode(err); {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status == http.StatusNotImplemented:
+		return false
+	case status >= http.StatusInternalServerError:
+		return true
+	case status != 0:
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// backoff returns the delay before the given retry, doubling with every
+// attempt and jittered to spread out concurrent clients.
+func backoff(attempt int) time.Duration {
+	d := retryMaxDelay
+	if attempt < 16 {
+		d = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryAfterHint carries the Retry-After of a failed response from the
+// HTTP transport to the retry loop, the provider errors do not expose it.
+type retryAfterHint struct {
+	after atomic.Int64
+}
+
+func (h *retryAfterHint) set(d time.Duration) {
+	h.after.Store(int64(d))
+}
+
+func (h *retryAfterHint) get() time.Duration {
+	return time.Duration(h.after.Load())
+}
+
+type retryAfterKey struct{}
+
+func withRetryAfterHint(ctx context.Context, hint *retryAfterHint) context.Context {
+	return context.WithValue(ctx, retryAfterKey{}, hint)
+}
+
+// retryAfterTransport records the Retry-After header of throttled responses
+// in the hint of the request context.
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if hint, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHint); ok {
+			hint.set(parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date. It returns zero if the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// newHTTPClient returns the HTTP client the providers talk to the API with,
+// going through the proxy and with the TLS settings of the API.
+func newHTTPClient(api options.API) (*http.Client, error) {
+	transport, err := rest.NewTransport(api.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &retryAfterTransport{base: transport}}, nil
+}
@@ -0,0 +1,227 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdk "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/ollama"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// flakyModel fails with the scripted errors before answering.
+type flakyModel struct {
+	errs   []error
+	chunk  string
+	calls  int
+	handle func(ctx context.Context) error
+}
+
+func (m *flakyModel) GenerateContent(ctx context.Context, _ []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if m.handle != nil {
+		if err := m.handle(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if m.chunk != "" && o.StreamingFunc != nil {
+		_ = o.StreamingFunc(ctx, []byte(m.chunk))
+	}
+	if m.calls <= len(m.errs) {
+		return nil, m.errs[m.calls-1]
+	}
+	return textResponse("done", llms.Usage{}), nil
+}
+
+func newTestRetryModel(model Model, maxRetries int, timeout time.Duration) (*retryModel, *[]time.Duration) {
+	m := newRetryModel(model, maxRetries, timeout)
+	var delays []time.Duration
+	m.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return m, &delays
+}
+
+func TestRetryModel(t *testing.T) {
+	ctx := context.Background()
+	unavailable := &sdk.RequestError{HTTPStatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		model := &flakyModel{errs: []error{unavailable, unavailable}}
+		m, delays := newTestRetryModel(model, 3, 0)
+
+		rsp, err := m.GenerateContent(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "done", rsp.Choices[0].Content)
+		assert.Equal(t, 3, model.calls)
+		require.Len(t, *delays, 2)
+		assert.GreaterOrEqual(t, (*delays)[0], retryBaseDelay/2)
+		assert.LessOrEqual(t, (*delays)[1], 2*retryBaseDelay)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		model := &flakyModel{errs: []error{unavailable, unavailable, unavailable}}
+		m, _ := newTestRetryModel(model, 2, 0)
+
+		_, err := m.GenerateContent(ctx, nil)
+		assert.ErrorIs(t, err, unavailable)
+		assert.Equal(t, 3, model.calls)
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		model := &flakyModel{errs: []error{&sdk.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "invalid"}}}
+		m, _ := newTestRetryModel(model, 3, 0)
+
+		_, err := m.GenerateContent(ctx, nil)
+		require.Error(t, err)
+		assert.Equal(t, 1, model.calls)
+	})
+
+	t.Run("does not retry after streaming started", func(t *testing.T) {
+		model := &flakyModel{errs: []error{unavailable}, chunk: "partial"}
+		m, _ := newTestRetryModel(model, 3, 0)
+
+		var chunks []string
+		_, err := m.GenerateContent(ctx, nil, llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		}))
+		require.Error(t, err)
+		assert.Equal(t, 1, model.calls)
+		assert.Equal(t, []string{"partial"}, chunks)
+	})
+
+	t.Run("bounds every attempt by the timeout", func(t *testing.T) {
+		model := &flakyModel{handle: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("no deadline")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+		m, delays := newTestRetryModel(model, 1, 10*time.Millisecond)
+
+		_, err := m.GenerateContent(ctx, nil)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 2, model.calls)
+		assert.Len(t, *delays, 1)
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		model := &flakyModel{errs: []error{unavailable}}
+		m, _ := newTestRetryModel(model, 3, 0)
+
+		_, err := m.GenerateContent(ctx, nil)
+		require.Error(t, err)
+		assert.Equal(t, 1, model.calls)
+	})
+
+	t.Run("honors retry after", func(t *testing.T) {
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = fmt.Fprint(w, `{"error":"slow down"}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"message":{"role":"assistant","content":"done"},"done":true,"done_reason":"stop"}`)
+		}))
+		defer srv.Close()
+
+		httpClient, err := newHTTPClient(options.API{})
+		require.NoError(t, err)
+		client, err := ollama.New(ollama.WithBaseURL(srv.URL), ollama.WithModel("llama3.2"), ollama.WithHTTPClient(httpClient))
+		require.NoError(t, err)
+		m, delays := newTestRetryModel(client, 3, 0)
+
+		rsp, err := m.GenerateContent(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")})
+		require.NoError(t, err)
+		assert.Equal(t, "done", rsp.Choices[0].Content)
+		assert.Equal(t, []time.Duration{7 * time.Second}, *delays)
+	})
+
+	t.Run("gives up on long retry after", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		httpClient, err := newHTTPClient(options.API{})
+		require.NoError(t, err)
+		client, err := ollama.New(ollama.WithBaseURL(srv.URL), ollama.WithModel("llama3.2"), ollama.WithHTTPClient(httpClient))
+		require.NoError(t, err)
+		m, delays := newTestRetryModel(client, 3, 0)
+
+		_, err = m.GenerateContent(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")})
+		require.Error(t, err)
+		assert.Empty(t, *delays)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter("", now))
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		d := backoff(attempt)
+		assert.Positive(t, d)
+		assert.LessOrEqual(t, d, retryMaxDelay)
+	}
+	assert.GreaterOrEqual(t, backoff(10), retryMaxDelay/2)
+}
+
+func TestNewModelProxy(t *testing.T) {
+	for _, typ := range []string{ModelTypeOpenAI, ModelTypeARK} {
+		t.Run(typ, func(t *testing.T) {
+			var host string
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				host = r.URL.Host
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"proxied"},"finish_reason":"stop"}]}`)
+			}))
+			defer proxy.Close()
+
+			api := options.API{Name: "api", Type: typ, BaseURL: "http://api.example.invalid/v1", APIKey: "key"}
+			api.HTTP = api.HTTP.Or(options.HTTP{Proxy: proxy.URL})
+			model, err := newModel(options.Model{Name: "gpt"}, api)
+			require.NoError(t, err)
+
+			rsp, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")})
+			require.NoError(t, err)
+			assert.Equal(t, "proxied", rsp.Choices[0].Content)
+			assert.Equal(t, "api.example.invalid", host)
+		})
+	}
+
+	t.Run("invalid proxy", func(t *testing.T) {
+		_, err := newModel(options.Model{Name: "gpt"}, options.API{Name: "openai", HTTP: options.HTTP{Proxy: "::"}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"os"
+	"strings"
+
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/util/rest"
+)
+
+const roleFilePrefix = "file://"
+
+// LoadRole returns the system messages of the role with the given name.
+// Messages given as a file:// path or an http(s) URL are read from there.
+func LoadRole(cfg *options.Config, name string) ([]string, error) {
+	role, err := cfg.GetRole(name)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]string, 0, len(role))
+	for _, msg := range role {
+		content, err := loadRoleMessage(msg, cfg.HTTP)
+		if err != nil {
+			return nil, errbook.Wrap("Could not load the role "+name, err)
+		}
+		if content = strings.TrimSpace(content); content != "" {
+			messages = append(messages, content)
+		}
+	}
+	return messages, nil
+}
+
+func loadRoleMessage(msg string, settings options.HTTP) (string, error) {
+	switch {
+	case strings.HasPrefix(msg, "https://"), strings.HasPrefix(msg, "http://"):
+		return rest.FetchURLContent(msg, settings)
+	case strings.HasPrefix(msg, roleFilePrefix):
+		content, err := os.ReadFile(strings.TrimPrefix(msg, roleFilePrefix))
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	}
+	return msg, nil
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func TestLoadRole(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/style.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprint(w, "follow the style guide\n")
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "reviewer.md")
+	require.NoError(t, os.WriteFile(file, []byte("you review go code\n"), 0o600))
+
+	cfg := &options.Config{Roles: options.Roles{
+		"default":  {},
+		"reviewer": {"you are strict", "file://" + file, srv.URL + "/style.txt"},
+		"broken":   {srv.URL + "/missing.txt"},
+	}}
+
+	t.Run("inline, file and url messages", func(t *testing.T) {
+		messages, err := LoadRole(cfg, "reviewer")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"you are strict", "you review go code", "follow the style guide"}, messages)
+	})
+
+	t.Run("empty role", func(t *testing.T) {
+		messages, err := LoadRole(cfg, "default")
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := LoadRole(cfg, "missing")
+		assert.Error(t, err)
+		_, err = LoadRole(cfg, "broken")
+		assert.Error(t, err)
+	})
+}
+
+func TestEngineRole(t *testing.T) {
+	model := &fakeModel{responses: []*llms.ContentResponse{textResponse("ls -S | head", llms.Usage{})}}
+	engine := newTestEngine(t, model, WithRole("shell"))
+	assert.Equal(t, "shell", engine.Role())
+
+	_, err := engine.CreateCompletion(context.Background(), []llms.ChatMessage{
+		llms.SystemChatMessage{Content: "answer with a command"},
+		llms.HumanChatMessage{Content: "largest files"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, model.requests, 1)
+	request := model.requests[0]
+	require.Len(t, request, 3)
+	assert.Equal(t, llms.TextParts(llms.ChatMessageTypeSystem, "you are a shell expert"), request[0])
+	assert.Equal(t, llms.TextParts(llms.ChatMessageTypeSystem, "answer with a command"), request[1])
+
+	t.Run("unknown role", func(t *testing.T) {
+		_, err := New(WithConfig(engine.Config), WithStore(engine.GetConvoStore()), WithModel(model), WithRole("missing"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"context"
+	"sync"
+)
+
+// Stream is the response of a single streaming completion. The chunks of
+// the answer are delivered on Outputs, the last output carries the usage
+// and the answering model. Outputs is closed once the completion is over,
+// Wait then returns the complete answer or the error that ended it, along
+// with the partial answer when the completion was interrupted.
+type Stream struct {
+	outputs chan StreamCompletionOutput
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	once   sync.Once
+	result *StreamCompletionOutput
+	err    error
+}
+
+func newStream(ctx context.Context) *Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Stream{
+		outputs: make(chan StreamCompletionOutput),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Outputs returns the channel the chunks of the answer are delivered on.
+func (s *Stream) Outputs() <-chan StreamCompletionOutput {
+	return s.outputs
+}
+
+// Close cancels the completion, aborting the request to the API. It is safe
+// to call Close more than once and after the completion is over.
+func (s *Stream) Close() {
+	s.cancel()
+}
+
+// Done returns a channel that is closed once the completion is over.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the completion is over, draining the outputs nobody
+// reads, and returns the complete answer. An interrupted completion returns
+// its partial answer, marked with Interrupt, and the error of the interruption.
+func (s *Stream) Wait() (*StreamCompletionOutput, error) {
+	for {
+		select {
+		case <-s.done:
+			return s.result, s.err
+		case _, ok := <-s.outputs:
+			if !ok {
+				<-s.done
+				return s.result, s.err
+			}
+		}
+	}
+}
+
+// send delivers an output unless the stream was closed.
+func (s *Stream) send(out StreamCompletionOutput) error {
+	select {
+	case s.outputs <- out:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// finish ends the stream with the complete answer or an error.
+func (s *Stream) finish(result *StreamCompletionOutput, err error) {
+	s.once.Do(func() {
+		s.result, s.err = result, err
+		close(s.done)
+		close(s.outputs)
+		s.cancel()
+	})
+}
@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// echoModel answers every request with the text of its last message.
+type echoModel struct{}
+
+func (echoModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	content := textOf(messages[len(messages)-1])
+	if o.StreamingFunc != nil {
+		for _, r := range content {
+			if err := o.StreamingFunc(ctx, []byte(string(r))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return textResponse(content, llms.Usage{}), nil
+}
+
+// blockingModel streams a chunk and blocks until the request is canceled.
+type blockingModel struct {
+	canceled chan struct{}
+}
+
+func (m *blockingModel) GenerateContent(ctx context.Context, _ []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.StreamingFunc != nil {
+		_ = o.StreamingFunc(ctx, []byte("partial"))
+	}
+	<-ctx.Done()
+	close(m.canceled)
+	return nil, ctx.Err()
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("streams concurrent completions separately", func(t *testing.T) {
+		engine := newTestEngine(t, echoModel{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				question := fmt.Sprintf("stream %d", i)
+				stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: question}})
+				if !assert.NoError(t, err) {
+					return
+				}
+				var content string
+				for out := range stream.Outputs() {
+					content += out.GetContent()
+				}
+				out, err := stream.Wait()
+				if assert.NoError(t, err) {
+					assert.Equal(t, question, content)
+					assert.Equal(t, question, out.GetContent())
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				question := fmt.Sprintf("completion %d", i)
+				out, err := engine.CreateCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: question}})
+				if assert.NoError(t, err) {
+					assert.Equal(t, question, out.Explanation)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("close cancels the request", func(t *testing.T) {
+		model := &blockingModel{canceled: make(chan struct{})}
+		engine := newTestEngine(t, model)
+
+		stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		out := <-stream.Outputs()
+		assert.Equal(t, "partial", out.GetContent())
+
+		stream.Close()
+		<-model.canceled
+		_, err = stream.Wait()
+		assert.ErrorIs(t, err, context.Canceled)
+		_, open := <-stream.Outputs()
+		assert.False(t, open)
+	})
+
+	t.Run("close keeps the partial answer", func(t *testing.T) {
+		model := &blockingModel{canceled: make(chan struct{})}
+		engine := newTestEngine(t, model)
+		engine.Config.CacheWriteToID = "interrupted"
+
+		stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		<-stream.Outputs()
+		stream.Close()
+
+		out, err := stream.Wait()
+		assert.ErrorIs(t, err, context.Canceled)
+		require.NotNil(t, out)
+		assert.True(t, out.IsInterrupt())
+		assert.True(t, out.IsLast())
+		assert.Equal(t, "partial", out.GetContent())
+		assert.Equal(t, "fake", out.GetModel())
+
+		messages, err := engine.GetConvoStore().Messages(ctx, "interrupted")
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, llms.ChatMessageTypeAI, messages[1].GetType())
+		assert.Equal(t, "partial\n\n"+InterruptedMarker, messages[1].GetContent())
+	})
+
+	t.Run("canceling the context cancels the request", func(t *testing.T) {
+		model := &blockingModel{canceled: make(chan struct{})}
+		engine := newTestEngine(t, model)
+
+		ctx, cancel := context.WithCancel(ctx)
+		stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		cancel()
+
+		_, err = stream.Wait()
+		assert.Error(t, err)
+		<-model.canceled
+	})
+
+	t.Run("wait drains unread outputs", func(t *testing.T) {
+		engine := newTestEngine(t, echoModel{})
+
+		stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "unread"}})
+		require.NoError(t, err)
+		out, err := stream.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, "unread", out.GetContent())
+		assert.True(t, out.IsLast())
+	})
+
+	t.Run("close aborts the http request", func(t *testing.T) {
+		aborted := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"partial"}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				close(aborted)
+			case <-time.After(10 * time.Second):
+			}
+		}))
+		defer server.Close()
+
+		model, err := newModel(options.Model{Name: "gpt"}, options.API{Name: "openai", BaseURL: server.URL, APIKey: "key"})
+		require.NoError(t, err)
+		engine := newTestEngine(t, model)
+
+		stream, err := engine.CreateStreamCompletion(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+		require.NoError(t, err)
+		out := <-stream.Outputs()
+		assert.Equal(t, "partial", out.GetContent())
+
+		stream.Close()
+		select {
+		case <-aborted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the http request was not aborted")
+		}
+		_, err = stream.Wait()
+		assert.Error(t, err)
+	})
+}
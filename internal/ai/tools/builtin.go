@@ -0,0 +1,399 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/coding-hui/ai-terminal/internal/runner"
+)
+
+const (
+	maxListEntries = 500
+	maxGrepMatches = 200
+
+	// shellWaitDelay is how long an interrupted run_shell waits for the
+	// output of the killed command.
+	shellWaitDelay = time.Second
+)
+
+// NewBuiltinRegistry returns a registry with the built-in repository tools.
+// All paths are resolved relative to root and may not escape it, not even
+// through symlinks.
+// confirm is asked before run_shell executes anything and before apply_edit
+// writes a file; a nil confirm denies every command and edit.
+func NewBuiltinRegistry(root string, confirm ConfirmFunc) *Registry {
+	ws := workspace{root: root}
+	return NewRegistry(
+		&readFile{ws},
+		&listFiles{ws},
+		&grep{ws},
+		&runShell{ws: ws, confirm: confirm},
+		&applyEdit{ws: ws, confirm: confirm},
+	)
+}
+
+type workspace struct {
+	root string
+}
+
+// resolve converts a model supplied path into an absolute path inside root.
+// The symlinks of the path are followed, a path that only lexically lies
+// inside root is rejected too.
+func (w workspace) resolve(path string) (string, error) {
+	root, err := filepath.Abs(w.root)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = "."
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(root, path)
+	}
+	abs = filepath.Clean(abs)
+	if !within(root, abs) {
+		return "", fmt.Errorf("path %q is outside of %s", path, root)
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := evalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	if !within(resolvedRoot, resolved) {
+		return "", fmt.Errorf("path %q links outside of %s", path, root)
+	}
+	return abs, nil
+}
+
+// within reports whether path lies inside root, both absolute and clean.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalSymlinks follows the symlinks of path. A path that does not exist yet
+// resolves through its nearest existing parent, where it would be created.
+func evalSymlinks(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return resolved, err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", err
+	}
+	resolved, err = evalSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, filepath.Base(path)), nil
+}
+
+func (w workspace) rel(abs string) string {
+	root, _ := filepath.Abs(w.root)
+	if rel, err := filepath.Rel(root, abs); err == nil {
+		return rel
+	}
+	return abs
+}
+
+type readFile struct{ ws workspace }
+
+func (t *readFile) Name() string { return "read_file" }
+
+func (t *readFile) Description() string {
+	return "Read the content of a file in the repository."
+}
+
+func (t *readFile) Parameters() map[string]any {
+	return objectSchema(map[string]any{
+		"path": stringProperty("Path of the file relative to the repository root."),
+	}, "path")
+}
+
+func (t *readFile) Call(_ context.Context, arguments string) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	path, err := t.ws.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+type listFiles struct{ ws workspace }
+
+func (t *listFiles) Name() string { return "list_files" }
+
+func (t *listFiles) Description() string {
+	return "Recursively list the files below a directory of the repository."
+}
+
+func (t *listFiles) Parameters() map[string]any {
+	return objectSchema(map[string]any{
+		"path": stringProperty("Directory relative to the repository root, defaults to the root."),
+	})
+}
+
+func (t *listFiles) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	dir, err := t.ws.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, t.ws.rel(path))
+		if len(files) >= maxListEntries {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(files) >= maxListEntries {
+		files = append(files, fmt.Sprintf("... (listing stopped after %d files)", maxListEntries))
+	}
+	return strings.Join(files, "\n"), nil
+}
+
+type grep struct{ ws workspace }
+
+func (t *grep) Name() string { return "grep" }
+
+func (t *grep) Description() string {
+	return "Search the repository files for lines matching a regular expression."
+}
+
+func (t *grep) Parameters() map[string]any {
+	return objectSchema(map[string]any{
+		"pattern": stringProperty("Regular expression (Go RE2 syntax) to search for."),
+		"path":    stringProperty("Directory or file to search, defaults to the repository root."),
+	}, "pattern")
+}
+
+func (t *grep) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	re, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", err
+	}
+	dir, err := t.ws.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if _, err := t.ws.resolve(path); err != nil {
+				// links outside of the repository
+				return nil
+			}
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(content, 0) >= 0 {
+			// unreadable or binary file
+			return nil
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for no := 1; scanner.Scan(); no++ {
+			if re.MatchString(scanner.Text()) {
+				matches = append(matches, fmt.Sprintf("%s:%d:%s", t.ws.rel(path), no, scanner.Text()))
+				if len(matches) >= maxGrepMatches {
+					return fs.SkipAll
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "no matches found", nil
+	}
+	return strings.Join(matches, "\n"), nil
+}
+
+type runShell struct {
+	ws      workspace
+	confirm ConfirmFunc
+}
+
+func (t *runShell) Name() string { return "run_shell" }
+
+func (t *runShell) Description() string {
+	return "Run a shell command in the repository root and return its combined output. The user has to approve every command."
+}
+
+func (t *runShell) Parameters() map[string]any {
+	return objectSchema(map[string]any{
+		"command": stringProperty("The shell command to execute."),
+	}, "command")
+}
+
+func (t *runShell) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Command) == "" {
+		return "", fmt.Errorf("empty command")
+	}
+	if !Confirm(ctx, t.confirm, "Allow the model to run %s?", args.Command) {
+		return "", fmt.Errorf("the user declined to run the command")
+	}
+
+	cmd := runner.PrepareInteractiveCommandContext(ctx, args.Command)
+	cmd.Dir = t.ws.root
+	// the children of the shell may keep the output open after it was killed
+	cmd.WaitDelay = shellWaitDelay
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("the command was interrupted: %w", ctx.Err())
+	}
+	if err != nil {
+		return fmt.Sprintf("%s\nexit error: %v", out, err), nil
+	}
+	return string(out), nil
+}
+
+type applyEdit struct {
+	ws      workspace
+	confirm ConfirmFunc
+}
+
+func (t *applyEdit) Name() string { return "apply_edit" }
+
+func (t *applyEdit) Description() string {
+	return "Edit a file by replacing one exact occurrence of `search` with `replace`. " +
+		"Use an empty `search` to create a new file with `replace` as its content. " +
+		"The user has to approve every edit."
+}
+
+func (t *applyEdit) Parameters() map[string]any {
+	return objectSchema(map[string]any{
+		"path":    stringProperty("Path of the file relative to the repository root."),
+		"search":  stringProperty("Exact text to replace, must occur exactly once in the file."),
+		"replace": stringProperty("Replacement text."),
+	}, "path", "replace")
+}
+
+func (t *applyEdit) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Search  string `json:"search"`
+		Replace string `json:"replace"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	path, err := t.ws.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if args.Search == "" {
+		if _, err := os.Stat(path); err == nil {
+			return "", fmt.Errorf("%s already exists, provide the text to replace", args.Path)
+		}
+		if !Confirm(ctx, t.confirm, "Allow the model to create %s?", args.Path) {
+			return "", fmt.Errorf("the user declined to create %s", args.Path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(args.Replace), 0o644); err != nil { //nolint:gosec
+			return "", err
+		}
+		return fmt.Sprintf("created %s", args.Path), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	switch n := strings.Count(string(content), args.Search); n {
+	case 0:
+		return "", fmt.Errorf("search text not found in %s", args.Path)
+	case 1:
+	default:
+		return "", fmt.Errorf("search text occurs %d times in %s, make it unique", n, args.Path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !Confirm(ctx, t.confirm, "Allow the model to edit %s?", args.Path) {
+		return "", fmt.Errorf("the user declined to edit %s", args.Path)
+	}
+	updated := strings.Replace(string(content), args.Search, args.Replace, 1)
+	if err := os.WriteFile(path, []byte(updated), info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("updated %s", args.Path), nil
+}
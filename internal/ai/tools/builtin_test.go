@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+func TestBuiltinTools(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "pkg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pkg", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("# demo\n"), 0o644))
+
+	var confirmed []string
+	allow := true
+	registry := NewBuiltinRegistry(root, func(format string, args ...any) bool {
+		confirmed = append(confirmed, args[0].(string))
+		return allow
+	})
+
+	call := func(name, arguments string) string {
+		msg := registry.Execute(ctx, llms.ToolCall{
+			ID:           "call",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: name, Arguments: arguments},
+		})
+		require.Equal(t, llms.ChatMessageTypeTool, msg.Role)
+		return msg.Parts[0].(llms.ToolCallResponse).Content
+	}
+
+	t.Run("definitions", func(t *testing.T) {
+		assert.Equal(t, []string{"apply_edit", "grep", "list_files", "read_file", "run_shell"}, registry.Names())
+		for _, def := range registry.Definitions() {
+			assert.Equal(t, "function", def.Type)
+			assert.NotEmpty(t, def.Function.Description)
+		}
+	})
+
+	t.Run("read_file", func(t *testing.T) {
+		assert.Equal(t, "# demo\n", call("read_file", `{"path":"README.md"}`))
+		assert.Contains(t, call("read_file", `{"path":"../outside"}`), "error: path")
+		assert.Contains(t, call("read_file", `{"path":`), "error: invalid arguments")
+	})
+
+	t.Run("truncates long results at a rune", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "long.txt"), []byte("a"+strings.Repeat("é", maxResultLength)), 0o644))
+		defer os.Remove(filepath.Join(root, "long.txt")) //nolint:errcheck
+
+		result := call("read_file", `{"path":"long.txt"}`)
+		assert.True(t, utf8.ValidString(result))
+		assert.True(t, strings.HasSuffix(result, "é\n... (truncated)"))
+		assert.LessOrEqual(t, len(result), maxResultLength+len("\n... (truncated)"))
+	})
+
+	t.Run("list_files", func(t *testing.T) {
+		assert.Equal(t, "README.md\n"+filepath.Join("pkg", "main.go"), call("list_files", `{}`))
+	})
+
+	t.Run("grep", func(t *testing.T) {
+		assert.Equal(t, filepath.Join("pkg", "main.go")+":3:func main() {}", call("grep", `{"pattern":"^func"}`))
+		assert.Equal(t, "no matches found", call("grep", `{"pattern":"nothing"}`))
+	})
+
+	t.Run("apply_edit", func(t *testing.T) {
+		assert.Equal(t, "updated pkg/main.go", call("apply_edit", `{"path":"pkg/main.go","search":"func main() {}","replace":"func main() { println() }"}`))
+		content, err := os.ReadFile(filepath.Join(root, "pkg", "main.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "println()")
+
+		assert.Contains(t, call("apply_edit", `{"path":"pkg/main.go","search":"missing","replace":"x"}`), "not found")
+		assert.Equal(t, "created new/file.txt", call("apply_edit", `{"path":"new/file.txt","replace":"hello"}`))
+		assert.Contains(t, call("apply_edit", `{"path":"new/file.txt","replace":"again"}`), "already exists")
+		assert.Equal(t, []string{"pkg/main.go", "new/file.txt"}, confirmed)
+
+		allow = false
+		defer func() { allow = true }()
+		assert.Contains(t, call("apply_edit", `{"path":"README.md","search":"demo","replace":"x"}`), "declined")
+		assert.Contains(t, call("apply_edit", `{"path":"denied.txt","replace":"x"}`), "declined")
+		content, err = os.ReadFile(filepath.Join(root, "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "# demo\n", string(content))
+		assert.NoFileExists(t, filepath.Join(root, "denied.txt"))
+	})
+
+	t.Run("symlinks", func(t *testing.T) {
+		outside := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret\n"), 0o644))
+		require.NoError(t, os.Symlink(outside, filepath.Join(root, "out")))
+		require.NoError(t, os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "secret")))
+		defer os.Remove(filepath.Join(root, "out"))    //nolint:errcheck
+		defer os.Remove(filepath.Join(root, "secret")) //nolint:errcheck
+
+		assert.Contains(t, call("read_file", `{"path":"secret"}`), "links outside")
+		assert.Contains(t, call("read_file", `{"path":"out/secret"}`), "links outside")
+		assert.Contains(t, call("list_files", `{"path":"out"}`), "links outside")
+		assert.Equal(t, "no matches found", call("grep", `{"pattern":"secret"}`))
+		assert.Contains(t, call("apply_edit", `{"path":"out/new.txt","replace":"x"}`), "links outside")
+		assert.NoFileExists(t, filepath.Join(outside, "new.txt"))
+	})
+
+	t.Run("run_shell", func(t *testing.T) {
+		confirmed = nil
+		assert.Equal(t, "hi\n", call("run_shell", `{"command":"echo hi"}`))
+		assert.Equal(t, []string{"echo hi"}, confirmed)
+
+		allow = false
+		defer func() { allow = true }()
+		assert.Contains(t, call("run_shell", `{"command":"echo denied"}`), "declined")
+	})
+
+	t.Run("confirm of the context", func(t *testing.T) {
+		confirmed = nil
+		var asked []string
+		ctx := WithConfirm(ctx, func(format string, args ...any) bool {
+			asked = append(asked, args[0].(string))
+			return true
+		})
+		msg := registry.Execute(ctx, llms.ToolCall{
+			ID:           "call",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: "run_shell", Arguments: `{"command":"echo hi"}`},
+		})
+		assert.Equal(t, "hi\n", msg.Parts[0].(llms.ToolCallResponse).Content)
+		assert.Equal(t, []string{"echo hi"}, asked)
+		assert.Empty(t, confirmed)
+	})
+
+	t.Run("run_shell is interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		msg := registry.Execute(ctx, llms.ToolCall{
+			ID:           "call",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: "run_shell", Arguments: `{"command":"sleep 10; echo done"}`},
+		})
+		assert.Contains(t, msg.Parts[0].(llms.ToolCallResponse).Content, "interrupted")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("unknown tool", func(t *testing.T) {
+		assert.Contains(t, call("missing", `{}`), `unknown tool "missing"`)
+	})
+}
@@ -0,0 +1,199 @@
+// Package tools implements the function-calling tools that the ai engine
+// exposes to models, together with a registry used to look them up.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+)
+
+// maxResultLength caps the size of a single tool result fed back to the model.
+const maxResultLength = 32 * 1024
+
+// Tool is a function that the model can ask the engine to invoke.
+type Tool interface {
+	// Name returns the unique name the model uses to call the tool.
+	Name() string
+	// Description tells the model what the tool does and when to use it.
+	Description() string
+	// Parameters returns the JSON schema of the tool arguments.
+	Parameters() map[string]any
+	// Call executes the tool with the JSON encoded arguments.
+	Call(ctx context.Context, arguments string) (string, error)
+}
+
+// ConfirmFunc asks the user whether a potentially dangerous action may run.
+type ConfirmFunc func(format string, args ...any) bool
+
+// ConsoleConfirm asks the user on the terminal, defaulting to no.
+func ConsoleConfirm(format string, args ...any) bool {
+	return console.WaitForUserConfirm(console.No, format, args...)
+}
+
+type confirmKey struct{}
+
+// WithConfirm returns a context whose tool calls ask confirm instead of the
+// ConfirmFunc the tools were created with, e.g. a terminal program that has
+// to hand over the terminal before the user is asked.
+func WithConfirm(ctx context.Context, confirm ConfirmFunc) context.Context {
+	return context.WithValue(ctx, confirmKey{}, confirm)
+}
+
+// Confirm asks the ConfirmFunc of the context, or fallback if the context has
+// none. Without any ConfirmFunc the action is denied.
+func Confirm(ctx context.Context, fallback ConfirmFunc, format string, args ...any) bool {
+	if confirm, ok := ctx.Value(confirmKey{}).(ConfirmFunc); ok && confirm != nil {
+		return confirm(format, args...)
+	}
+	if fallback == nil {
+		return false
+	}
+	return fallback(format, args...)
+}
+
+// Registry holds the tools available to the engine.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewRegistry creates a registry with the given tools.
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{tools: make(map[string]Tool)}
+	for _, t := range tools {
+		r.Register(t)
+	}
+	return r
+}
+
+// Register adds a tool to the registry, replacing any tool with the same name.
+func (r *Registry) Register(t Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[t.Name()] = t
+}
+
+// Get returns the tool registered under name.
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Len returns the number of registered tools.
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// Names returns the sorted names of all registered tools.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Definitions returns the tool definitions in the form expected by llms.WithTools.
+func (r *Registry) Definitions() []llms.Tool {
+	defs := make([]llms.Tool, 0, r.Len())
+	for _, name := range r.Names() {
+		t, _ := r.Get(name)
+		defs = append(defs, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        t.Name(),
+				Description: t.Description(),
+				Parameters:  t.Parameters(),
+			},
+		})
+	}
+	return defs
+}
+
+// Execute runs the requested tool call and returns the message that carries
+// its result back to the model. Failures are reported to the model as the
+// tool result so that it can recover instead of aborting the whole request.
+func (r *Registry) Execute(ctx context.Context, call llms.ToolCall) llms.MessageContent {
+	result, err := r.call(ctx, call)
+	if err != nil {
+		result = fmt.Sprintf("error: %v", err)
+	}
+	if len(result) > maxResultLength {
+		// cut at a rune boundary, the model gets valid UTF-8
+		n := maxResultLength
+		for n > 0 && !utf8.RuneStart(result[n]) {
+			n--
+		}
+		result = result[:n] + "\n... (truncated)"
+	}
+
+	name := ""
+	if call.FunctionCall != nil {
+		name = call.FunctionCall.Name
+	}
+
+	return llms.MessageContent{
+		Role: llms.ChatMessageTypeTool,
+		Parts: []llms.ContentPart{llms.ToolCallResponse{
+			ToolCallID: call.ID,
+			Name:       name,
+			Content:    result,
+		}},
+	}
+}
+
+func (r *Registry) call(ctx context.Context, call llms.ToolCall) (string, error) {
+	if call.FunctionCall == nil {
+		return "", fmt.Errorf("tool call %s has no function", call.ID)
+	}
+	t, ok := r.Get(call.FunctionCall.Name)
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.FunctionCall.Name)
+	}
+	return t.Call(ctx, call.FunctionCall.Arguments)
+}
+
+// decodeArgs unmarshals the JSON arguments of a tool call into v.
+func decodeArgs(arguments string, v any) error {
+	if arguments == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// objectSchema builds a JSON schema object with the given properties.
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProperty(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
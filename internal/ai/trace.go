@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/callbacks"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+// TraceHandler is a callback handler appending every request of the engine
+// to a model, with its response or error, as a JSON line to a file.
+type TraceHandler struct {
+	callbacks.SimpleHandler
+
+	path string
+	mu   sync.Mutex
+}
+
+var _ callbacks.Handler = (*TraceHandler)(nil)
+
+// TraceRecord is a line of the trace file.
+type TraceRecord struct {
+	Time       time.Time             `json:"time"`
+	Command    string                `json:"command,omitempty"`
+	Model      string                `json:"model"`
+	API        string                `json:"api"`
+	Params     llms.CallOptions      `json:"params"`
+	Messages   []llms.MessageContent `json:"messages"`
+	Response   string                `json:"response,omitempty"`
+	StopReason string                `json:"stopReason,omitempty"`
+	ToolCalls  []llms.ToolCall       `json:"toolCalls,omitempty"`
+	Usage      llms.Usage            `json:"usage"`
+	LatencyMS  int64                 `json:"latencyMs"`
+	Error      string                `json:"error,omitempty"`
+}
+
+// NewTraceHandler returns a handler tracing to the file at path, the file
+// is created on the first request.
+func NewTraceHandler(path string) *TraceHandler {
+	return &TraceHandler{path: path}
+}
+
+func (h *TraceHandler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	h.trace(ctx, res, nil)
+}
+
+func (h *TraceHandler) HandleLLMError(ctx context.Context, err error) {
+	h.trace(ctx, nil, err)
+}
+
+func (h *TraceHandler) trace(ctx context.Context, res *llms.ContentResponse, err error) {
+	call, ok := CallFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	record := TraceRecord{
+		Time:      call.Start,
+		Command:   call.Command,
+		Model:     call.Model,
+		API:       call.API,
+		Params:    call.Options,
+		Messages:  call.Messages,
+		LatencyMS: time.Since(call.Start).Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if res != nil {
+		record.Usage = res.Usage
+		if len(res.Choices) > 0 {
+			record.Response = res.Choices[0].Content
+			record.StopReason = res.Choices[0].StopReason
+			record.ToolCalls = res.Choices[0].ToolCalls
+		}
+	}
+
+	// failing to trace does not fail the request
+	if err := h.write(record); err != nil {
+		klog.Warningf("failed to write the trace to %s: %v", h.path, err)
+	}
+}
+
+func (h *TraceHandler) write(record TraceRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil { //nolint:mnd
+		return err
+	}
+	// the file is opened for every line, processes sharing it append whole lines
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:mnd
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/callbacks"
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
+)
+
+// recordingHandler records the callbacks it gets.
+type recordingHandler struct {
+	callbacks.SimpleHandler
+
+	mu     sync.Mutex
+	events []string
+	models []string
+}
+
+func (h *recordingHandler) record(ctx context.Context, event string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	if call, ok := CallFromContext(ctx); ok {
+		h.models = append(h.models, call.Model)
+	}
+}
+
+func (h *recordingHandler) HandleLLMGenerateContentStart(ctx context.Context, _ []llms.MessageContent) {
+	h.record(ctx, "start")
+}
+
+func (h *recordingHandler) HandleLLMGenerateContentEnd(ctx context.Context, _ *llms.ContentResponse) {
+	h.record(ctx, "end")
+}
+
+func (h *recordingHandler) HandleLLMError(ctx context.Context, _ error) {
+	h.record(ctx, "error")
+}
+
+func (h *recordingHandler) HandleStreamingFunc(ctx context.Context, _ []byte) {
+	h.record(ctx, "chunk")
+}
+
+func (h *recordingHandler) HandleToolStart(ctx context.Context, input string) {
+	h.record(ctx, "tool "+input)
+}
+
+func (h *recordingHandler) HandleToolEnd(ctx context.Context, output string) {
+	h.record(ctx, "tool result "+output)
+}
+
+func readTrace(t *testing.T, path string) []TraceRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	var records []TraceRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record TraceRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestEngineCallbacks(t *testing.T) {
+	handler := &recordingHandler{}
+	model := &fakeModel{responses: []*llms.ContentResponse{
+		toolCallResponse("call-1", "echo", `{"a":1}`, llms.Usage{}),
+		textResponse("done", llms.Usage{}),
+	}}
+	engine := newTestEngine(t, model, WithTools(tools.NewRegistry(echoTool{})), WithCallbacks(handler))
+
+	stream, err := engine.CreateStreamCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}})
+	require.NoError(t, err)
+	_, err = stream.Wait()
+	require.NoError(t, err)
+
+	// the tool call deltas are not streamed
+	assert.Equal(t, []string{
+		"start", "end",
+		`tool echo {"a":1}`, `tool result echo {"a":1}`,
+		"start", "chunk", "end",
+	}, handler.events)
+	assert.Equal(t, []string{"fake", "fake", "fake", "fake", "fake"}, handler.models)
+}
+
+func TestTraceHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "trace.jsonl")
+
+	usage := llms.Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4}
+	engine := newTestEngine(t, &fakeModel{responses: []*llms.ContentResponse{textResponse("pong", usage)}},
+		WithCallbacks(NewTraceHandler(path)))
+	engine.Config.Temperature = 0.3
+	engine.Config.Command = "ask"
+	_, err := engine.CreateCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "ping"}})
+	require.NoError(t, err)
+
+	failing := newTestEngine(t, &failingModel{err: errors.New("bad gateway")}, WithCallbacks(NewTraceHandler(path)))
+	_, err = failing.CreateCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "ping"}})
+	require.Error(t, err)
+
+	records := readTrace(t, path)
+	require.Len(t, records, 2)
+
+	ok := records[0]
+	assert.Equal(t, "ask", ok.Command)
+	assert.Equal(t, "fake", ok.Model)
+	assert.Equal(t, "fake", ok.API)
+	assert.Equal(t, "fake", ok.Params.Model)
+	assert.InDelta(t, 0.3, ok.Params.Temperature, 1e-9)
+	require.Len(t, ok.Messages, 1)
+	assert.Equal(t, llms.TextParts(llms.ChatMessageTypeHuman, "ping"), ok.Messages[0])
+	assert.Equal(t, "pong", ok.Response)
+	assert.Equal(t, usage, ok.Usage)
+	assert.Empty(t, ok.Error)
+	assert.False(t, ok.Time.IsZero())
+
+	assert.Equal(t, "bad gateway", records[1].Error)
+	assert.Empty(t, records[1].Response)
+
+	t.Run("trace file of the settings", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trace.jsonl")
+		cfg := *engine.Config
+		cfg.TraceFile = path
+		traced, err := New(WithConfig(&cfg), WithStore(engine.GetConvoStore()),
+			WithModel(&fakeModel{responses: []*llms.ContentResponse{textResponse("pong", usage)}}))
+		require.NoError(t, err)
+
+		_, err = traced.CreateCompletion(context.Background(), []llms.ChatMessage{llms.HumanChatMessage{Content: "ping"}})
+		require.NoError(t, err)
+		assert.Len(t, readTrace(t, path), 1)
+	})
+}
@@ -21,6 +21,10 @@ type CompletionOutput struct {
 	Command     string `json:"cmd"`
 	Explanation string `json:"exp"`
 	Executable  bool   `json:"exec"`
+	// Model is the model that answered, a fallback model if the configured one failed.
+	Model string `json:"model"`
+	// Warnings tell about the input that was cut to fit the limit of the model.
+	Warnings []string `json:"warnings,omitempty"`
 
 	Usage llms.Usage `json:"usage"`
 }
@@ -44,10 +48,17 @@ type CompletionInput struct {
 
 // StreamCompletionOutput a tea.Msg that wraps the content returned from ai.
 type StreamCompletionOutput struct {
-	Content    string
-	Last       bool
+	Content string
+	Last    bool
+	// Interrupt tells the completion was interrupted, the content of the last
+	// output is then the partial answer.
 	Interrupt  bool
 	Executable bool
+	// Model is the model that answered, set on the last message.
+	Model string
+	// Warnings tell about the input that was cut to fit the limit of the model,
+	// set on the last message.
+	Warnings []string
 
 	Usage llms.Usage `json:"usage"`
 }
@@ -68,6 +79,14 @@ func (c StreamCompletionOutput) IsExecutable() bool {
 	return c.Executable
 }
 
+func (c StreamCompletionOutput) GetModel() string {
+	return c.Model
+}
+
+func (c StreamCompletionOutput) GetWarnings() []string {
+	return c.Warnings
+}
+
 func (c StreamCompletionOutput) GetUsage() llms.Usage {
 	return c.Usage
 }
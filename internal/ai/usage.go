@@ -0,0 +1,38 @@
+package ai
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+// recordUsage appends the usage of a completion to the usage ledger of the
+// datastore. Failing to record it does not fail the completion.
+func (e *Engine) recordUsage(ctx context.Context, c candidate, usage llms.Usage) {
+	if e.convoStore == nil {
+		return
+	}
+
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	entry := &convo.Usage{
+		Model:            c.config.Name,
+		API:              c.api,
+		Command:          e.Config.Command,
+		ConversationID:   e.Config.CacheWriteToID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      total,
+	}
+	// the answer was paid for even if the caller gave up on it meanwhile
+	if err := e.convoStore.SaveUsage(context.WithoutCancel(ctx), entry); err != nil {
+		klog.Warningf("failed to record the usage of %s: %v", c.config.Name, err)
+	}
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/anthropic"
+)
+
+func TestRecordUsage(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now().Add(-time.Minute)
+	input := []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}}
+
+	t.Run("records every completion", func(t *testing.T) {
+		model := &fakeModel{responses: []*llms.ContentResponse{
+			textResponse("first", llms.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}),
+			textResponse("second", llms.Usage{PromptTokens: 3, CompletionTokens: 2}),
+		}}
+		engine := newTestEngine(t, model)
+		engine.Config.Command = "ask"
+		engine.Config.CacheWriteToID = "convo"
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+		stream, err := engine.CreateStreamCompletion(ctx, input)
+		require.NoError(t, err)
+		_, err = stream.Wait()
+		require.NoError(t, err)
+
+		usages, err := engine.GetConvoStore().ListUsages(ctx, start)
+		require.NoError(t, err)
+		require.Len(t, usages, 2)
+		assert.Equal(t, "fake", usages[0].Model)
+		assert.Equal(t, "fake", usages[0].API)
+		assert.Equal(t, "ask", usages[0].Command)
+		assert.Equal(t, "convo", usages[0].ConversationID)
+		assert.Equal(t, 15, usages[0].TotalTokens)
+		assert.Equal(t, 5, usages[1].TotalTokens)
+	})
+
+	t.Run("records the answering fallback", func(t *testing.T) {
+		engine := newFallbackEngine(t, map[string]Model{
+			"primary":   &failingModel{err: &anthropic.Error{StatusCode: http.StatusBadGateway}},
+			"secondary": &fakeModel{responses: []*llms.ContentResponse{textResponse("ok", llms.Usage{TotalTokens: 7})}},
+		})
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.NoError(t, err)
+
+		usages, err := engine.GetConvoStore().ListUsages(ctx, start)
+		require.NoError(t, err)
+		require.Len(t, usages, 1)
+		assert.Equal(t, "secondary", usages[0].Model)
+		assert.Equal(t, "two", usages[0].API)
+	})
+
+	t.Run("does not record failed completions", func(t *testing.T) {
+		engine := newTestEngine(t, &failingModel{err: errors.New("boom")})
+
+		_, err := engine.CreateCompletion(ctx, input)
+		require.Error(t, err)
+
+		usages, err := engine.GetConvoStore().ListUsages(ctx, start)
+		require.NoError(t, err)
+		assert.Empty(t, usages)
+	})
+}
@@ -5,19 +5,27 @@
 package ask
 
 import (
+	"context"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
 	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/git"
+	"github.com/coding-hui/ai-terminal/internal/mcp"
 	"github.com/coding-hui/ai-terminal/internal/options"
 	"github.com/coding-hui/ai-terminal/internal/ui"
 	"github.com/coding-hui/ai-terminal/internal/ui/coders"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
 	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
 	"github.com/coding-hui/ai-terminal/internal/util/templates"
 	"github.com/coding-hui/ai-terminal/internal/util/term"
@@ -32,6 +40,21 @@ var askExample = templates.Examples(`
 
 		# Write new sections for a readme": 
 		cat README.md | ai ask "write a new section to this README documenting a pdf sharing feature"
+
+		# Get validated JSON output for scripts:
+		ai ask --format-as json list the three largest planets with their radius
+
+		# Stop generating at a custom sequence:
+		ai ask --stop "END" write a haiku, then write END
+
+		# Ask a model that takes images about a screenshot:
+		ai ask --image screenshot.png what is wrong with this dialog
+
+		# Answer as one of the roles of your settings:
+		ai ask --role shell list the ten largest files in this directory
+
+		# Compare the answers of several models of your settings side by side:
+		ai ask --models gpt-4o,deepseek-chat,claude explain the CAP theorem in two sentences
 `)
 
 // Options is a struct to support ask command.
@@ -39,6 +62,9 @@ type Options struct {
 	genericclioptions.IOStreams
 	pipe           string
 	prompts        []string
+	images         []string
+	models         []string
+	save           bool
 	tempPromptFile string
 	cfg            *options.Config
 }
@@ -66,6 +92,9 @@ func NewCmdASK(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobr
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Validate(); err != nil {
+				return err
+			}
 			return o.Run()
 		},
 		PostRunE: func(c *cobra.Command, args []string) error {
@@ -81,24 +110,75 @@ func NewCmdASK(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobr
 
 	cmd.Flags().BoolVarP(&o.cfg.Interactive, "interactive", "i", o.cfg.Interactive, "Interactive dialogue model.")
 	cmd.Flags().StringVarP(&o.cfg.PromptFile, "file", "f", o.cfg.PromptFile, "File containing prompt.")
+	cmd.Flags().StringSliceVar(&o.images, "image", nil, options.Help["image"])
+	cmd.Flags().StringVarP(&o.cfg.Role, "role", "R", o.cfg.Role, options.Help["role"])
+	cmd.Flags().BoolVar(&o.cfg.AllContext, "all-context", o.cfg.AllContext, options.Help["all-context"])
+	cmd.Flags().StringSliceVar(&o.models, "models", nil, options.Help["models"])
+	cmd.Flags().BoolVar(&o.save, "save", false, options.Help["save-answers"])
 
 	return cmd
 }
 
 // Validate validates the provided options.
 func (o *Options) Validate() error {
+	if o.cfg.FormatAs != "" {
+		if _, ok := o.cfg.FormatText[o.cfg.FormatAs]; !ok {
+			return errbook.NewUserErrorf(
+				"Unknown format %s, the format text is only configured for: %s",
+				o.cfg.FormatAs, strings.Join(slices.Sorted(maps.Keys(o.cfg.FormatText)), ", "),
+			)
+		}
+	}
+	if len(o.models) > 0 && o.cfg.Interactive {
+		return errbook.NewUserErrorf("The models can only be compared on a single question, drop --interactive.")
+	}
+	if o.save && len(o.models) == 0 {
+		return errbook.NewUserErrorf("--save saves the answers of the models compared with --models.")
+	}
+	if o.save && o.cfg.NoCache {
+		return errbook.NewUserErrorf("The answers cannot be saved with --no-cache.")
+	}
 	return nil
 }
 
 // Run executes ask command.
 func (o *Options) Run() error {
+	images := make([]llms.BinaryContent, 0, len(o.images))
+	for _, path := range o.images {
+		image, err := ai.LoadImage(path)
+		if err != nil {
+			return err
+		}
+		images = append(images, image)
+	}
+
+	content := strings.TrimSpace(o.pipe + "\n\n" + strings.Join(o.prompts, "\n\n"))
+	if len(o.models) > 0 {
+		if content == "" {
+			return errbook.NewUserErrorf("Please give the question to ask the models.")
+		}
+		return o.compare(context.Background(), content, images)
+	}
+
 	repo := git.New()
 	root, err := repo.GitDir()
 	if err != nil {
 		return errbook.Wrap("Could not get git root", err)
 	}
 
-	engine, err := ai.New(ai.WithConfig(o.cfg))
+	engineOpts := []ai.Option{ai.WithConfig(o.cfg), ai.WithRole(o.cfg.Role)}
+	if !o.cfg.NoTools {
+		registry := tools.NewBuiltinRegistry(filepath.Dir(root), tools.ConsoleConfirm)
+		servers, err := mcp.StartServers(context.Background(), o.cfg.MCPServers, tools.ConsoleConfirm)
+		if err != nil {
+			console.WarnStderr("The tools of some MCP servers are not available: " + err.Error())
+		}
+		defer servers.Close()
+		servers.Register(registry)
+		engineOpts = append(engineOpts, ai.WithTools(registry))
+	}
+
+	engine, err := ai.New(engineOpts...)
 	if err != nil {
 		return errbook.Wrap("Could not initialized ai engine", err)
 	}
@@ -108,14 +188,14 @@ func (o *Options) Run() error {
 		return errbook.Wrap("Could not initialize conversation store", err)
 	}
 
-	content := o.pipe + "\n\n" + strings.Join(o.prompts, "\n\n")
 	autoCoder := coders.NewAutoCoder(
 		coders.WithConfig(o.cfg),
 		coders.WithEngine(engine),
 		coders.WithRepo(repo),
 		coders.WithCodeBasePath(filepath.Dir(root)),
 		coders.WithStore(store),
-		coders.WithPrompt(strings.TrimSpace(content)),
+		coders.WithPrompt(content),
+		coders.WithImages(images),
 		coders.WithPromptMode(ui.ChatPromptMode),
 	)
 
@@ -0,0 +1,181 @@
+// Copyright (c) 2023 coding-hui. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package ask
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+	"github.com/coding-hui/ai-terminal/internal/util/term"
+)
+
+const (
+	// columnGap is the space between the columns of the compared answers.
+	columnGap = 1
+	// defaultScreenWidth is used when the width of the terminal is unknown.
+	defaultScreenWidth = 160
+)
+
+// compare asks every model given with --models the question concurrently
+// and prints their answers side by side.
+func (o *Options) compare(ctx context.Context, prompt string, images []llms.BinaryContent) error {
+	store, err := convo.GetConversationStore(o.cfg)
+	if err != nil {
+		return errbook.Wrap("Could not initialize conversation store", err)
+	}
+
+	engines := make([]*ai.Engine, 0, len(o.models))
+	for _, name := range o.models {
+		cfg, err := o.cfg.ForModel(name)
+		if err != nil {
+			return err
+		}
+		// every answer is written to a conversation of its own, kept only
+		// when the answers are saved
+		cfg.NoCache = !o.save
+		cfg.CacheReadFromID = ""
+		cfg.CacheWriteToID = convo.NewConversationID()
+
+		engine, err := ai.New(ai.WithConfig(cfg), ai.WithStore(store), ai.WithRole(o.cfg.Role))
+		if err != nil {
+			return errbook.Wrap("Could not initialize the ai engine of the model "+name, err)
+		}
+		engines = append(engines, engine)
+	}
+
+	messages := ai.WithImages([]llms.ChatMessage{llms.HumanChatMessage{Content: prompt}}, images)
+	answers := ai.Compare(ctx, engines, messages, ai.WithFormat(o.cfg.FormatAs))
+
+	if term.IsOutputTTY() && !o.cfg.Raw {
+		_, _ = fmt.Fprintln(o.Out, renderColumns(answers))
+	} else {
+		_, _ = fmt.Fprint(o.Out, renderSections(answers))
+	}
+
+	if o.save {
+		for i, answer := range answers {
+			if answer.Err != nil {
+				continue
+			}
+			if err := o.saveAnswer(ctx, store, engines[i], answer, prompt); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, answer := range answers {
+		if answer.Err == nil {
+			return nil
+		}
+	}
+	return errbook.New("None of the models %s answered.", strings.Join(o.models, ", "))
+}
+
+// saveAnswer saves the answer of a compared model as a conversation of its
+// own, titled after the question and the model.
+func (o *Options) saveAnswer(ctx context.Context, store convo.Store, engine *ai.Engine, answer ai.Answer, prompt string) error {
+	id := engine.Config.CacheWriteToID
+	title := o.cfg.Title
+	if title == "" {
+		title, _, _ = strings.Cut(prompt, "\n")
+	}
+	title = fmt.Sprintf("%s (%s)", title, answer.Model)
+
+	if err := store.PersistentMessages(ctx, id); err != nil {
+		return errbook.Wrap("Could not save the answer of "+answer.Model, err)
+	}
+	if err := store.SaveConversation(ctx, id, title, answer.Output.Model, engine.Role()); err != nil {
+		return errbook.Wrap("Could not save the answer of "+answer.Model, err)
+	}
+
+	styles := console.StdoutStyles()
+	_, _ = fmt.Fprintf(o.Out, "Conversation saved: %s %s\n", styles.SHA1.Render(id[:convo.Sha1short]), title)
+	return nil
+}
+
+// renderColumns renders the answers in columns filling the terminal.
+func renderColumns(answers []ai.Answer) string {
+	screen := defaultScreenWidth
+	if size := term.GetSize(os.Stdout.Fd()); size != nil {
+		screen = int(size.Width)
+	}
+
+	styles := console.StdoutStyles()
+	column := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Padding(0, 1).
+		Width(max(screen/len(answers)-columnGap-2, 20))
+	wrap := column.GetWidth() - column.GetHorizontalPadding()
+
+	glam, err := glamour.NewTermRenderer(
+		glamour.WithEnvironmentConfig(),
+		glamour.WithWordWrap(wrap),
+	)
+
+	columns := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		header := styles.AppName.Render(answer.Model) + "\n" + styles.Comment.Render(answerStats(answer))
+
+		var body string
+		switch {
+		case answer.Err != nil:
+			body = styles.ErrorHeader.String() + "\n" + answer.Err.Error()
+		case err != nil:
+			body = answer.Output.Content
+		default:
+			body, _ = glam.Render(answer.Output.Content)
+			body = strings.Trim(body, "\n")
+		}
+
+		columns = append(columns, column.Render(header+"\n\n"+body))
+		if len(columns) < 2*len(answers)-1 {
+			columns = append(columns, strings.Repeat(" ", columnGap))
+		}
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, columns...)
+}
+
+// renderSections renders the answers one after the other, for pipes.
+func renderSections(answers []ai.Answer) string {
+	var b strings.Builder
+	for _, answer := range answers {
+		fmt.Fprintf(&b, "## %s\n\n", answer.Model)
+		if answer.Err != nil {
+			fmt.Fprintf(&b, "Error: %s\n\n", answer.Err)
+		} else {
+			fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(answer.Output.Content))
+		}
+		fmt.Fprintf(&b, "> %s\n\n", answerStats(answer))
+	}
+	return b.String()
+}
+
+// answerStats tells how long a model took and the tokens it used.
+func answerStats(answer ai.Answer) string {
+	stats := fmt.Sprintf("%.2fs", answer.Latency.Seconds())
+	if answer.Output == nil {
+		return stats
+	}
+	if answer.Output.Model != "" && answer.Output.Model != answer.Model {
+		stats += " · answered by " + answer.Output.Model
+	}
+	usage := answer.Output.Usage
+	if usage.TotalTokens > 0 {
+		stats += fmt.Sprintf(" · %d tokens (%d prompt, %d completion)", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+	}
+	return stats
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -25,7 +26,12 @@ import (
 	"github.com/coding-hui/ai-terminal/internal/cli/hook"
 	"github.com/coding-hui/ai-terminal/internal/cli/loadctx"
 	"github.com/coding-hui/ai-terminal/internal/cli/manpage"
+	"github.com/coding-hui/ai-terminal/internal/cli/mcp"
+	"github.com/coding-hui/ai-terminal/internal/cli/models"
 	"github.com/coding-hui/ai-terminal/internal/cli/review"
+	"github.com/coding-hui/ai-terminal/internal/cli/roles"
+	"github.com/coding-hui/ai-terminal/internal/cli/serve"
+	"github.com/coding-hui/ai-terminal/internal/cli/usage"
 	"github.com/coding-hui/ai-terminal/internal/cli/version"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/options"
@@ -33,6 +39,8 @@ import (
 	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
 	"github.com/coding-hui/ai-terminal/internal/util/templates"
 
+	_ "github.com/coding-hui/ai-terminal/internal/convo/filestore"
+	_ "github.com/coding-hui/ai-terminal/internal/convo/sqldb"
 	_ "github.com/coding-hui/ai-terminal/internal/convo/sqlite3"
 )
 
@@ -69,7 +77,9 @@ func NewAICommand(in io.Reader, out, errOut io.Writer) *cobra.Command {
 		Run:           runHelp,
 		// Hook before and after Run initialize and write profiles to disk,
 		// respectively.
-		PersistentPreRunE: func(*cobra.Command, []string) error {
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// recorded with the usage of the completions the command makes
+			cfg.Command = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
 			return initProfiling()
 		},
 		PersistentPostRunE: func(*cobra.Command, []string) error {
@@ -106,12 +116,17 @@ func NewAICommand(in io.Reader, out, errOut io.Writer) *cobra.Command {
 				commit.NewCmdCommit(ioStreams, &cfg),
 				review.NewCmdCommit(ioStreams, &cfg),
 				loadctx.NewCmdContext(ioStreams, &cfg),
+				mcp.NewCmdMCP(ioStreams, &cfg),
+				serve.NewCmdServe(ioStreams, &cfg),
 			},
 		},
 		templates.CommandGroup{
 			Message: "Settings Commands:",
 			Commands: []*cobra.Command{
 				configure.NewCmdConfigure(ioStreams, &cfg),
+				models.NewCmdModels(ioStreams, &cfg),
+				usage.NewCmdUsage(ioStreams, &cfg),
+				roles.NewCmdRoles(ioStreams, &cfg),
 				completion.NewCmdCompletion(),
 				manpage.NewCmdManPage(cmds),
 				hook.NewCmdHook(),
@@ -1,18 +1,22 @@
 package coder
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/git"
+	"github.com/coding-hui/ai-terminal/internal/mcp"
 	"github.com/coding-hui/ai-terminal/internal/options"
 	"github.com/coding-hui/ai-terminal/internal/ui"
 	"github.com/coding-hui/ai-terminal/internal/ui/coders"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
 )
 
 type Options struct {
@@ -29,6 +33,8 @@ func NewCmdCoder(cfg *options.Config) *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&ops.prompt, "prompt", "p", "", "Prompt to generate code.")
+	cmd.Flags().StringVarP(&cfg.Role, "role", "R", cfg.Role, options.Help["role"])
+	cmd.Flags().BoolVar(&cfg.AllContext, "all-context", cfg.AllContext, options.Help["all-context"])
 
 	return cmd
 }
@@ -44,7 +50,19 @@ func (o *Options) run(_ *cobra.Command, args []string) error {
 		return errbook.Wrap("Could not get git root", err)
 	}
 
-	engine, err := ai.New(ai.WithConfig(o.cfg))
+	engineOpts := []ai.Option{ai.WithConfig(o.cfg), ai.WithRole(o.cfg.Role)}
+	if !o.cfg.NoTools {
+		registry := tools.NewBuiltinRegistry(filepath.Dir(root), tools.ConsoleConfirm)
+		servers, err := mcp.StartServers(context.Background(), o.cfg.MCPServers, tools.ConsoleConfirm)
+		if err != nil {
+			console.WarnStderr("The tools of some MCP servers are not available: " + err.Error())
+		}
+		defer servers.Close()
+		servers.Register(registry)
+		engineOpts = append(engineOpts, ai.WithTools(registry))
+	}
+
+	engine, err := ai.New(engineOpts...)
 	if err != nil {
 		return errbook.Wrap("Could not initialized ai engine", err)
 	}
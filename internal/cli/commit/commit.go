@@ -88,7 +88,7 @@ func (o *Options) AutoCommit(_ *cobra.Command, args []string) error {
 		return err
 	}
 
-	commitMessage, err := o.generateCommitMessage(llmEngine, g)
+	commitMessage, err := o.generateCommitMessage(context.Background(), llmEngine, g)
 	if err != nil {
 		return err
 	}
@@ -112,6 +112,27 @@ func (o *Options) AutoCommit(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+// GenerateMessage generates the commit message of the staged changes
+// without committing them. Canceling ctx aborts the completions.
+func (o *Options) GenerateMessage(ctx context.Context) (string, error) {
+	if err := o.validateGit(); err != nil {
+		return "", err
+	}
+
+	llmEngine, err := ai.New(ai.WithConfig(o.cfg))
+	if err != nil {
+		return "", err
+	}
+
+	g := git.New(
+		git.WithDiffUnified(o.diffUnified),
+		git.WithExcludeList(o.excludeList),
+		git.WithEnableAmend(o.commitAmend),
+	)
+
+	return o.generateCommitMessage(ctx, llmEngine, g)
+}
+
 func (o *Options) validateGit() error {
 	if !runner.IsCommandAvailable("git") {
 		return errbook.New("git command not found on your system's PATH. Please install Git and try again")
@@ -135,7 +156,7 @@ func (o *Options) addFilesToGit(g *git.Command) error {
 	return nil
 }
 
-func (o *Options) generateCommitMessage(llmEngine *ai.Engine, g *git.Command) (string, error) {
+func (o *Options) generateCommitMessage(ctx context.Context, llmEngine *ai.Engine, g *git.Command) (string, error) {
 	diff, err := g.DiffFiles()
 	if err != nil {
 		return "", errbook.Wrap("Could not get diff files.", err)
@@ -147,19 +168,19 @@ func (o *Options) generateCommitMessage(llmEngine *ai.Engine, g *git.Command) (s
 		prompt.OutputLanguageKey:    prompt.GetLanguage(o.commitLang),
 	}
 
-	if err := o.performCodeAnalysis(llmEngine, vars); err != nil {
+	if err := o.performCodeAnalysis(ctx, llmEngine, vars); err != nil {
 		return "", err
 	}
 
-	return o.generateFinalCommitMessage(llmEngine, vars)
+	return o.generateFinalCommitMessage(ctx, llmEngine, vars)
 }
 
-func (o *Options) performCodeAnalysis(llmEngine *ai.Engine, vars map[string]any) error {
-	if err := o.codeReview(llmEngine, vars); err != nil {
+func (o *Options) performCodeAnalysis(ctx context.Context, llmEngine *ai.Engine, vars map[string]any) error {
+	if err := o.codeReview(ctx, llmEngine, vars); err != nil {
 		return errbook.Wrap("Could not generate code review.", err)
 	}
 
-	if err := o.summarizeTitle(llmEngine, vars); err != nil {
+	if err := o.summarizeTitle(ctx, llmEngine, vars); err != nil {
 		return errbook.Wrap("Could not generate summarize title.", err)
 	}
 
@@ -168,15 +189,15 @@ func (o *Options) performCodeAnalysis(llmEngine *ai.Engine, vars map[string]any)
 		vars[prompt.SummarizePrefixKey] = o.commitPrefix
 	} else {
 		// Otherwise generate prefix from LLM
-		if err := o.summarizePrefix(llmEngine, vars); err != nil {
+		if err := o.summarizePrefix(ctx, llmEngine, vars); err != nil {
 			return errbook.Wrap("Could not generate summarize prefix.", err)
 		}
 	}
 	return nil
 }
 
-func (o *Options) generateFinalCommitMessage(llmEngine *ai.Engine, vars map[string]any) (string, error) {
-	commitMessage, err := o.generateCommitMsg(llmEngine, vars)
+func (o *Options) generateFinalCommitMessage(ctx context.Context, llmEngine *ai.Engine, vars map[string]any) (string, error) {
+	commitMessage, err := o.generateCommitMsg(ctx, llmEngine, vars)
 	if err != nil {
 		return "", errbook.Wrap("Could not generate commit message.", err)
 	}
@@ -287,7 +308,7 @@ func (o *Options) executeStandardCommit(g *git.Command, finalCommitMessage strin
 }
 
 // codeReview summary code review message from diff datas
-func (o *Options) codeReview(engine *ai.Engine, vars map[string]any) error {
+func (o *Options) codeReview(ctx context.Context, engine *ai.Engine, vars map[string]any) error {
 	console.RenderStep("Analyzing code changes...")
 
 	p, err := prompt.GetPromptStringByTemplateName(prompt.SummarizeFileDiffTemplate, vars)
@@ -295,7 +316,7 @@ func (o *Options) codeReview(engine *ai.Engine, vars map[string]any) error {
 		return err
 	}
 
-	resp, err := engine.CreateCompletion(context.Background(), p.Messages())
+	resp, err := engine.CreateCompletion(ctx, p.Messages())
 	if err != nil {
 		return err
 	}
@@ -311,7 +332,7 @@ func (o *Options) codeReview(engine *ai.Engine, vars map[string]any) error {
 	return nil
 }
 
-func (o *Options) summarizeTitle(engine *ai.Engine, vars map[string]any) error {
+func (o *Options) summarizeTitle(ctx context.Context, engine *ai.Engine, vars map[string]any) error {
 	console.RenderStep("Generating commit title...")
 
 	p, err := prompt.GetPromptStringByTemplateName(prompt.SummarizeTitleTemplate, vars)
@@ -319,7 +340,7 @@ func (o *Options) summarizeTitle(engine *ai.Engine, vars map[string]any) error {
 		return err
 	}
 
-	resp, err := engine.CreateCompletion(context.Background(), p.Messages())
+	resp, err := engine.CreateCompletion(ctx, p.Messages())
 	if err != nil {
 		return err
 	}
@@ -335,7 +356,7 @@ func (o *Options) summarizeTitle(engine *ai.Engine, vars map[string]any) error {
 	return nil
 }
 
-func (o *Options) summarizePrefix(engine *ai.Engine, vars map[string]any) error {
+func (o *Options) summarizePrefix(ctx context.Context, engine *ai.Engine, vars map[string]any) error {
 	console.RenderStep("Determining commit type...")
 
 	p, err := prompt.GetPromptStringByTemplateName(prompt.ConventionalCommitTemplate, vars)
@@ -343,7 +364,7 @@ func (o *Options) summarizePrefix(engine *ai.Engine, vars map[string]any) error
 		return err
 	}
 
-	resp, err := engine.CreateCompletion(context.Background(), p.Messages())
+	resp, err := engine.CreateCompletion(ctx, p.Messages())
 	if err != nil {
 		return err
 	}
@@ -357,7 +378,7 @@ func (o *Options) summarizePrefix(engine *ai.Engine, vars map[string]any) error
 	return nil
 }
 
-func (o *Options) generateCommitMsg(engine *ai.Engine, vars map[string]any) (string, error) {
+func (o *Options) generateCommitMsg(ctx context.Context, engine *ai.Engine, vars map[string]any) (string, error) {
 	var err error
 	var commitPromptVal llms.PromptValue
 	if o.templateFile != "" {
@@ -392,7 +413,7 @@ func (o *Options) generateCommitMsg(engine *ai.Engine, vars map[string]any) (str
 			return "", err
 		}
 
-		resp, err := engine.CreateCompletion(context.Background(), translationPrompt.Messages())
+		resp, err := engine.CreateCompletion(ctx, translationPrompt.Messages())
 		if err != nil {
 			return "", err
 		}
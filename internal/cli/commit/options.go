@@ -85,3 +85,24 @@ func WithIsAutoCoder(isAutoCoder bool) Option {
 		o.isAutoCoder = isAutoCoder
 	}
 }
+
+// WithUserPrompt sets the additional prompt of the user
+func WithUserPrompt(userPrompt string) Option {
+	return func(o *Options) {
+		o.userPrompt = userPrompt
+	}
+}
+
+// WithDiffUnified sets the number of lines of context of the diff
+func WithDiffUnified(diffUnified int) Option {
+	return func(o *Options) {
+		o.diffUnified = diffUnified
+	}
+}
+
+// WithExcludeList sets the files excluded from the diff
+func WithExcludeList(excludeList []string) Option {
+	return func(o *Options) {
+		o.excludeList = excludeList
+	}
+}
@@ -72,6 +72,9 @@ func makeOptions(conversations []convo.Conversation) []huh.Option[string] {
 		if c.Model != nil {
 			right += console.StdoutStyles().Comment.Render(*c.Model)
 		}
+		if c.Role != "" {
+			right += console.StdoutStyles().Comment.Render(" " + c.Role)
+		}
 		opts = append(opts, huh.NewOption(left+" "+right, c.ID))
 	}
 	return opts
@@ -46,7 +46,7 @@ func NewCmdExec(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cob
 				return err
 			}
 
-			engine, err := ai.New(ai.WithConfig(o.cfg))
+			engine, err := ai.New(ai.WithConfig(o.cfg), ai.WithRole(o.cfg.Role))
 			if err != nil {
 				return err
 			}
@@ -72,6 +72,7 @@ func NewCmdExec(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cob
 
 	cmd.Flags().BoolVarP(&o.auto, "yes", "y", false, "Run without confirmation (auto-execute the inferred command)")
 	cmd.Flags().BoolVarP(&o.cfg.Interactive, "interactive", "i", false, "Interactive dialogue mode.")
+	cmd.Flags().StringVarP(&o.cfg.Role, "role", "R", o.cfg.Role, options.Help["role"])
 
 	return cmd
 }
@@ -22,6 +22,7 @@ func NewCmdContext(ioStreams genericclioptions.IOStreams, cfg *options.Config) *
 		newCmdLoad(ioStreams, cfg),
 		newCmdList(ioStreams, cfg),
 		newCmdClean(ioStreams, cfg),
+		newCmdSearch(ioStreams, cfg),
 	)
 
 	return cmd
@@ -85,6 +85,7 @@ func (o *load) Run(args []string) (err error) {
 		o.currentConversation.WriteID,
 		fmt.Sprintf("load-contexts-%s", o.currentConversation.WriteID[:convo.Sha1short]),
 		o.currentConversation.Model,
+		"",
 	)
 	if err != nil {
 		return errbook.Wrap("Failed to save conversation", err)
@@ -94,30 +95,44 @@ func (o *load) Run(args []string) (err error) {
 }
 
 func (o *load) loadPath(path string) error {
-	// Handle remote URLs
 	if rest.IsValidURL(path) {
 		console.Render("Loading remote content [%s]", path)
-		content, err := rest.FetchURLContent(path)
-		if err != nil {
-			return errbook.Wrap("Failed to load remote content", err)
-		}
-		return o.saveContent(path, content, convo.ContentTypeURL)
+	} else {
+		console.Render("Loading local file [%s]", path)
 	}
 
-	// Handle local files
-	console.Render("Loading local file [%s]", path)
-	if err := o.saveContent(path, "", convo.ContentTypeFile); err != nil {
+	lc, err := LoadPath(context.Background(), o.cfg, o.convoStore, o.currentConversation.WriteID, path)
+	if err != nil {
 		return err
 	}
 
+	// save to conversation
+	console.Render("Saved content [%s] to conversation [%s]", lc.FilePath, o.currentConversation.WriteID[:convo.Sha1short])
+
 	return nil
 }
 
-func (o *load) saveContent(sourcePath, content string, contentType convo.ContentType) error {
+// LoadPath loads the local file or the remote document at path into the
+// conversation. Remote content is cached to be read when it is used.
+func LoadPath(ctx context.Context, cfg *options.Config, store convo.Store, conversationID, path string) (*convo.LoadContext, error) {
+	// Handle remote URLs
+	if rest.IsValidURL(path) {
+		content, err := rest.FetchURLContent(path, cfg.HTTP)
+		if err != nil {
+			return nil, errbook.Wrap("Failed to load remote content", err)
+		}
+		return saveContent(ctx, cfg, store, conversationID, path, content, convo.ContentTypeURL)
+	}
+
+	// Handle local files
+	return saveContent(ctx, cfg, store, conversationID, path, "", convo.ContentTypeFile)
+}
+
+func saveContent(ctx context.Context, cfg *options.Config, store convo.Store, conversationID, sourcePath, content string, contentType convo.ContentType) (*convo.LoadContext, error) {
 	// Create cache directory if it doesn't exist
-	cacheDir := filepath.Join(o.cfg.DataStore.CachePath, "loaded")
+	cacheDir := filepath.Join(cfg.DataStore.CachePath, "loaded")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return errbook.Wrap("Failed to create cache directory", err)
+		return nil, errbook.Wrap("Failed to create cache directory", err)
 	}
 
 	// Generate safe filename
@@ -128,24 +143,21 @@ func (o *load) saveContent(sourcePath, content string, contentType convo.Content
 		cachePath = filepath.Join(cacheDir, filename)
 		// Save content to cache
 		if err := os.WriteFile(cachePath, []byte(content), 0644); err != nil {
-			return errbook.Wrap("Failed to save content", err)
+			return nil, errbook.Wrap("Failed to save content", err)
 		}
 	}
 
-	err := o.convoStore.SaveContext(context.Background(), &convo.LoadContext{
-		ConversationID: o.currentConversation.WriteID,
+	lc := &convo.LoadContext{
+		ConversationID: conversationID,
 		Name:           filename,
 		Type:           contentType,
 		URL:            sourcePath,
 		FilePath:       cachePath,
 		Content:        content,
-	})
-	if err != nil {
-		return errbook.Wrap("Failed to save load content", err)
+	}
+	if err := store.SaveContext(ctx, lc); err != nil {
+		return nil, errbook.Wrap("Failed to save load content", err)
 	}
 
-	// save to conversation
-	console.Render("Saved content [%s] to conversation [%s]", cachePath, o.currentConversation.WriteID[:convo.Sha1short])
-
-	return nil
+	return lc, nil
 }
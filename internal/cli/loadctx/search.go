@@ -0,0 +1,115 @@
+package loadctx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/retrieval"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+)
+
+// search is a struct to support search command
+type search struct {
+	genericclioptions.IOStreams
+	cfg     *options.Config
+	content bool
+}
+
+func newCmdSearch(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &search{
+		IOStreams: ioStreams,
+		cfg:       cfg,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "search <question>",
+		Short: "Show the chunks of the loaded contexts picked for a question",
+		Example: `  # Show which chunks are sent with a question
+  ai context search how is the proxy configured
+
+  # Print the picked chunks
+  ai context search --content how is the proxy configured`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errbook.New("Please provide the question to pick the chunks for")
+			}
+			return o.Run(strings.Join(args, " "))
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.content, "content", false, "Print the content of the picked chunks.")
+
+	return cmd
+}
+
+// Run executes the search command
+func (o *search) Run(question string) error {
+	store, err := convo.GetConversationStore(o.cfg)
+	if err != nil {
+		return errbook.Wrap("Failed to initialize conversation store", err)
+	}
+
+	conversation, err := convo.GetCurrentConversationID(context.Background(), o.cfg, store)
+	if err != nil {
+		return errbook.Wrap("Failed to get current conversation", err)
+	}
+
+	ctxs, err := store.ListContextsByteConvoID(context.Background(), conversation.ReadID)
+	if err != nil {
+		return errbook.Wrap("Failed to list contexts", err)
+	}
+
+	var docs []retrieval.Document
+	for _, ctx := range ctxs {
+		if ctx.Type == convo.ContentTypeImage {
+			continue
+		}
+		// remote content is read from the copy saved when it was loaded
+		content, err := os.ReadFile(ctx.FilePath)
+		if err != nil {
+			return errbook.Wrap("Failed to read the loaded context "+ctx.Name, err)
+		}
+		source := ctx.FilePath
+		if ctx.Type == convo.ContentTypeURL {
+			source = ctx.URL
+		}
+		docs = append(docs, retrieval.Document{Source: source, Content: string(content)})
+	}
+
+	if len(docs) == 0 {
+		console.Render("No contexts loaded")
+		return nil
+	}
+
+	maxChars := o.cfg.Retrieval.MaxChars
+	if maxChars <= 0 {
+		maxChars = retrieval.DefaultMaxChars
+	}
+	selection := retrieval.Select(docs, question, o.cfg.Retrieval.ChunkSize, maxChars)
+	if selection.All {
+		console.Render("The loaded contexts fit into the limit of %d characters, they are sent whole.", maxChars)
+		return nil
+	}
+
+	styles := console.StdoutStyles()
+	for _, chunk := range selection.Chunks {
+		_, _ = fmt.Fprintf(o.Out, "%s\t%s\n",
+			styles.SHA1.Render(fmt.Sprintf("%s:%d-%d", chunk.Source, chunk.StartLine, chunk.EndLine)),
+			styles.Comment.Render(fmt.Sprintf("score %.2f", chunk.Score)),
+		)
+		if o.content {
+			_, _ = fmt.Fprintf(o.Out, "%s\n\n", strings.TrimRight(chunk.Content, "\n"))
+		}
+	}
+	console.RenderComment("Picked %d of %d chunks, up to %d characters.", len(selection.Chunks), selection.Total, maxChars)
+
+	return nil
+}
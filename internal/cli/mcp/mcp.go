@@ -0,0 +1,24 @@
+// Package mcp provides the commands to use ai-terminal from MCP clients.
+package mcp
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+)
+
+// NewCmdMCP returns a cobra command for the Model Context Protocol.
+func NewCmdMCP(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve ai-terminal to other agents and editors over MCP.",
+		Run: func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newCmdServe(ioStreams, cfg))
+
+	return cmd
+}
@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/coding-hui/common/version"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/mcp"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+)
+
+// serve is a struct to support the serve command
+type serve struct {
+	genericclioptions.IOStreams
+	cfg   *options.Config
+	store convo.Store
+
+	// mu serializes the calls to the tools, they share the settings and the
+	// conversation store
+	mu sync.Mutex
+}
+
+func newCmdServe(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &serve{
+		IOStreams: ioStreams,
+		cfg:       cfg,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve commit messages, code reviews, conversations and loaded contexts as MCP tools over stdio.",
+		Example: `  # Run by an MCP client, e.g. in its settings:
+  #   {"command": "ai", "args": ["mcp", "serve"]}
+  ai mcp serve`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+// Run serves the tools until the client closes the standard input.
+func (o *serve) Run() (err error) {
+	o.store, err = convo.GetConversationStore(o.cfg)
+	if err != nil {
+		return errbook.Wrap("Failed to initialize conversation store", err)
+	}
+
+	// the standard output carries the protocol, what the commands print
+	// while they run goes to the standard error
+	stdout, colorOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = os.Stderr, os.Stderr
+	defer func() {
+		os.Stdout, color.Output = stdout, colorOutput
+	}()
+
+	server := mcp.NewServer("ai-terminal", version.Get().GitVersion)
+	o.addTools(server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return server.Serve(ctx, o.In, o.Out)
+}
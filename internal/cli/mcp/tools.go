@@ -0,0 +1,396 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/exp/ordered"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/cli/commit"
+	"github.com/coding-hui/ai-terminal/internal/cli/loadctx"
+	"github.com/coding-hui/ai-terminal/internal/cli/review"
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/git"
+	"github.com/coding-hui/ai-terminal/internal/mcp"
+	"github.com/coding-hui/ai-terminal/internal/prompt"
+	"github.com/coding-hui/ai-terminal/internal/runner"
+)
+
+const (
+	// defaultSearchLimit is how many conversations a search returns by default.
+	defaultSearchLimit = 20
+	// snippetRadius is how many characters around a match a search shows.
+	snippetRadius = 60
+)
+
+var (
+	langProperty = map[string]any{
+		"type":        "string",
+		"description": "Language of the answer: en, zh-cn, zh-tw, ja, pt or pt-br. Defaults to en.",
+	}
+	excludeProperty = map[string]any{
+		"type":        "array",
+		"items":       map[string]any{"type": "string"},
+		"description": "Files left out of the diff, e.g. *.lock.",
+	}
+	conversationProperty = map[string]any{
+		"type":        "string",
+		"description": "ID or title of the conversation.",
+	}
+)
+
+func object(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addTools adds the tools of ai-terminal to the server.
+func (o *serve) addTools(server *mcp.Server) {
+	server.AddTool(mcp.Tool{
+		Name:        "generate_commit_message",
+		Description: "Generate a conventional commit message for the staged changes of the git repository the server runs in. Nothing is committed.",
+		InputSchema: object(map[string]any{
+			"prompt":       map[string]any{"type": "string", "description": "Additional instructions for the message."},
+			"prefix":       map[string]any{"type": "string", "description": "Conventional commit prefix to use, e.g. feat or fix. Picked from the changes by default."},
+			"lang":         langProperty,
+			"exclude_list": excludeProperty,
+		}),
+	}, handler(o, o.generateCommitMessage))
+
+	server.AddTool(mcp.Tool{
+		Name:        "review_changes",
+		Description: "Review the staged changes of the git repository the server runs in.",
+		InputSchema: object(map[string]any{
+			"lang":         langProperty,
+			"exclude_list": excludeProperty,
+		}),
+	}, handler(o, o.reviewChanges))
+
+	server.AddTool(mcp.Tool{
+		Name:        "search_conversations",
+		Description: "Search the saved chat conversations by title and by the content of their messages, most recent first.",
+		InputSchema: object(map[string]any{
+			"query": map[string]any{"type": "string", "description": "Text to look for, case insensitive."},
+			"limit": map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum number of conversations returned, %d by default.", defaultSearchLimit)},
+		}, "query"),
+	}, handler(o, o.searchConversations))
+
+	server.AddTool(mcp.Tool{
+		Name:        "show_conversation",
+		Description: "Show the messages of a saved chat conversation.",
+		InputSchema: object(map[string]any{"conversation": conversationProperty}, "conversation"),
+	}, handler(o, o.showConversation))
+
+	server.AddTool(mcp.Tool{
+		Name:        "load_context",
+		Description: "Load local files or remote documents into a conversation, to be sent with the questions asked in it.",
+		InputSchema: object(map[string]any{
+			"paths": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Paths of local files or URLs of remote documents.",
+			},
+			"conversation": map[string]any{"type": "string", "description": "ID or title of the conversation. A new conversation is created by default."},
+		}, "paths"),
+	}, handler(o, o.loadContext))
+
+	server.AddTool(mcp.Tool{
+		Name:        "list_contexts",
+		Description: "List the files and documents loaded into a conversation.",
+		InputSchema: object(map[string]any{"conversation": conversationProperty}, "conversation"),
+	}, handler(o, o.listContexts))
+
+	server.AddTool(mcp.Tool{
+		Name:        "clean_contexts",
+		Description: "Remove all the files and documents loaded into a conversation.",
+		InputSchema: object(map[string]any{"conversation": conversationProperty}, "conversation"),
+	}, handler(o, o.cleanContexts))
+}
+
+// handler decodes the arguments of a tool and runs it, one call at a time.
+func handler[T any](o *serve, run func(ctx context.Context, args T) (string, error)) mcp.ToolHandler {
+	return func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		var args T
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		text, err := run(ctx, args)
+		// the reason tells the client which step failed
+		var aiErr errbook.AiError
+		if errors.As(err, &aiErr) && aiErr.Reason() != "" {
+			return "", fmt.Errorf("%s %w", aiErr.Reason(), err)
+		}
+		return text, err
+	}
+}
+
+// gitRepository checks the server runs in a git repository.
+func gitRepository() error {
+	if !runner.IsCommandAvailable("git") {
+		return errors.New("git command not found on your system's PATH")
+	}
+	if _, err := git.New().GitDir(); err != nil {
+		return errors.New("the server does not run in a git repository")
+	}
+	return nil
+}
+
+type diffArgs struct {
+	Lang        string   `json:"lang"`
+	ExcludeList []string `json:"exclude_list"`
+}
+
+type commitMessageArgs struct {
+	diffArgs
+	Prompt string `json:"prompt"`
+	Prefix string `json:"prefix"`
+}
+
+func (o *serve) generateCommitMessage(ctx context.Context, args commitMessageArgs) (string, error) {
+	if err := gitRepository(); err != nil {
+		return "", err
+	}
+
+	message, err := commit.New(
+		commit.WithConfig(o.cfg),
+		commit.WithIOStreams(o.IOStreams),
+		commit.WithNoConfirm(true),
+		commit.WithUserPrompt(strings.TrimSpace(args.Prompt)),
+		commit.WithCommitPrefix(args.Prefix),
+		commit.WithCommitLang(ordered.First(args.Lang, prompt.DefaultLanguage)),
+		commit.WithDiffUnified(3),
+		commit.WithExcludeList(args.ExcludeList),
+	).GenerateMessage(ctx)
+	if err != nil {
+		return "", err
+	}
+	return html.UnescapeString(message), nil
+}
+
+func (o *serve) reviewChanges(ctx context.Context, args diffArgs) (string, error) {
+	if err := gitRepository(); err != nil {
+		return "", err
+	}
+
+	g := git.New(
+		git.WithDiffUnified(3),
+		git.WithExcludeList(args.ExcludeList),
+	)
+	reviewMessage, err := review.Review(ctx, o.cfg, g, ordered.First(args.Lang, prompt.DefaultLanguage))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reviewMessage), nil
+}
+
+type searchArgs struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+func (o *serve) searchConversations(ctx context.Context, args searchArgs) (string, error) {
+	query := strings.ToLower(strings.TrimSpace(args.Query))
+	if query == "" {
+		return "", errors.New("the query is empty")
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	conversations, err := o.store.ListConversations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var found []string
+	for _, c := range conversations {
+		if len(found) == limit {
+			break
+		}
+
+		match := strings.Contains(strings.ToLower(c.Title), query)
+		var snippet string
+		messages, err := o.store.Messages(ctx, c.ID)
+		if err != nil {
+			return "", err
+		}
+		for _, m := range messages {
+			if s, ok := findSnippet(m.GetContent(), query); ok {
+				match, snippet = true, s
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+
+		line := fmt.Sprintf("%s\t%s\t%s", c.ID[:convo.Sha1short], c.Title, c.UpdatedAt.Format(time.RFC3339))
+		if snippet != "" {
+			line += "\t" + snippet
+		}
+		found = append(found, line)
+	}
+
+	if len(found) == 0 {
+		return "No conversations found.", nil
+	}
+	return strings.Join(found, "\n"), nil
+}
+
+// findSnippet returns the text around the first case insensitive match of
+// query in content, on a single line.
+func findSnippet(content, query string) (string, bool) {
+	runes := []rune(content)
+	// lowered rune by rune to keep the positions of content
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	at := strings.Index(string(lower), query)
+	if at < 0 {
+		return "", false
+	}
+	at = utf8.RuneCountInString(string(lower)[:at])
+
+	start, end := max(at-snippetRadius, 0), min(at+utf8.RuneCountInString(query)+snippetRadius, len(runes))
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "..." + snippet
+	}
+	if end < len(runes) {
+		snippet += "..."
+	}
+	return snippet, true
+}
+
+type conversationArgs struct {
+	Conversation string `json:"conversation"`
+}
+
+func (o *serve) conversation(ctx context.Context, id string) (*convo.Conversation, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, errors.New("the conversation is missing")
+	}
+	found, err := o.store.GetConversation(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the conversation %s: %w", id, err)
+	}
+	return found, nil
+}
+
+func (o *serve) showConversation(ctx context.Context, args conversationArgs) (string, error) {
+	found, err := o.conversation(ctx, args.Conversation)
+	if err != nil {
+		return "", err
+	}
+
+	messages, err := o.store.Messages(ctx, found.ID)
+	if err != nil {
+		return "", err
+	}
+	content, err := llms.GetBufferString(messages, "", "human", "ai")
+	if err != nil {
+		return "", err
+	}
+
+	header := fmt.Sprintf("%s\t%s\t%s", found.ID[:convo.Sha1short], found.Title, found.UpdatedAt.Format(time.RFC3339))
+	if found.Model != nil {
+		header += "\t" + *found.Model
+	}
+	return header + "\n\n" + html.UnescapeString(content), nil
+}
+
+type loadArgs struct {
+	Paths        []string `json:"paths"`
+	Conversation string   `json:"conversation"`
+}
+
+func (o *serve) loadContext(ctx context.Context, args loadArgs) (string, error) {
+	if len(args.Paths) == 0 {
+		return "", errors.New("no files or URLs to load")
+	}
+
+	conversationID := convo.NewConversationID()
+	if args.Conversation != "" {
+		found, err := o.conversation(ctx, args.Conversation)
+		if err != nil {
+			return "", err
+		}
+		conversationID = found.ID
+	}
+
+	var loaded []string
+	for _, path := range args.Paths {
+		lc, err := loadctx.LoadPath(ctx, o.cfg, o.store, conversationID, path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		loaded = append(loaded, fmt.Sprintf("%s\t%s", lc.Name, lc.Type))
+	}
+
+	if args.Conversation == "" {
+		err := o.store.SaveConversation(
+			ctx,
+			conversationID,
+			fmt.Sprintf("load-contexts-%s", conversationID[:convo.Sha1short]),
+			o.cfg.Model,
+			"",
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("Loaded into the conversation %s:\n%s", conversationID[:convo.Sha1short], strings.Join(loaded, "\n")), nil
+}
+
+func (o *serve) listContexts(ctx context.Context, args conversationArgs) (string, error) {
+	found, err := o.conversation(ctx, args.Conversation)
+	if err != nil {
+		return "", err
+	}
+
+	ctxs, err := o.store.ListContextsByteConvoID(ctx, found.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(ctxs) == 0 {
+		return "No contexts loaded.", nil
+	}
+
+	lines := make([]string, 0, len(ctxs))
+	for _, lc := range ctxs {
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s\t%s", lc.Name, lc.Type, ordered.First(lc.URL, lc.FilePath), lc.UpdatedAt.Format(time.RFC3339)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (o *serve) cleanContexts(ctx context.Context, args conversationArgs) (string, error) {
+	found, err := o.conversation(ctx, args.Conversation)
+	if err != nil {
+		return "", err
+	}
+
+	count, err := o.store.CleanContexts(ctx, found.ID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Deleted %d loaded contexts.", count), nil
+}
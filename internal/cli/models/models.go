@@ -0,0 +1,138 @@
+// Package models lists the models available for the configured API.
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/ollama"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+	"github.com/coding-hui/ai-terminal/internal/util/rest"
+	"github.com/coding-hui/ai-terminal/internal/util/templates"
+)
+
+var modelsExample = templates.Examples(`
+		# List the models of the default API
+		ai models
+
+		# List the models installed on the local ollama server
+		ai models --api ollama`)
+
+// Options is a struct to support models command.
+type Options struct {
+	genericclioptions.IOStreams
+	cfg *options.Config
+}
+
+// NewCmdModels returns a cobra command for listing models.
+func NewCmdModels(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &Options{IOStreams: ioStreams, cfg: cfg}
+	cmd := &cobra.Command{
+		Use:     "models",
+		Short:   "List the models available for the configured API.",
+		Example: modelsExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run(cmd.Context())
+		},
+	}
+
+	return cmd
+}
+
+// Run executes models command.
+func (o *Options) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	name := o.cfg.API
+	if name == "" {
+		mod, err := o.cfg.GetModel(o.cfg.Model)
+		if err != nil {
+			return err
+		}
+		name = mod.API
+	}
+	api, err := o.cfg.GetAPI(name)
+	if err != nil {
+		return err
+	}
+
+	if api.IsOllama() {
+		return o.listOllamaModels(ctx, api)
+	}
+
+	names := make([]string, 0, len(api.Models))
+	for name := range api.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		_, _ = fmt.Fprintf(o.ErrOut, "No models configured for %s.\n", api.Name)
+		return nil
+	}
+	for _, name := range names {
+		line := name
+		if aliases := api.Models[name].Aliases; len(aliases) > 0 {
+			line += "\t" + console.StdoutStyles().Comment.Render(strings.Join(aliases, ", "))
+		}
+		_, _ = fmt.Fprintln(o.Out, line)
+	}
+
+	return nil
+}
+
+func (o *Options) listOllamaModels(ctx context.Context, api options.API) error {
+	transport, err := rest.NewTransport(api.HTTP)
+	if err != nil {
+		return errbook.Wrap("Could not set up the HTTP client of the API "+api.Name, err)
+	}
+	client, err := ollama.New(
+		ollama.WithBaseURL(api.BaseURL),
+		ollama.WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	if err != nil {
+		return err
+	}
+
+	installed, err := client.ListModels(ctx)
+	if err != nil {
+		return errbook.Wrap("Could not list the models installed on the ollama server.", err)
+	}
+
+	if len(installed) == 0 {
+		_, _ = fmt.Fprintln(o.ErrOut, "No models installed, pull one with `ollama pull <model>`.")
+		return nil
+	}
+	for _, m := range installed {
+		_, _ = fmt.Fprintf(o.Out, "%s\t%s\t%s\n",
+			m.Name,
+			m.Details.ParameterSize,
+			console.StdoutStyles().Comment.Render(formatSize(m.Size)),
+		)
+	}
+
+	return nil
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
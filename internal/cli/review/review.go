@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/git"
 	"github.com/coding-hui/ai-terminal/internal/options"
 	"github.com/coding-hui/ai-terminal/internal/prompt"
@@ -53,59 +54,70 @@ func (o *Options) reviewCode(cmd *cobra.Command, args []string) error {
 		return errors.New("git command not found on your system's PATH. Please install Git and try again")
 	}
 
-	llmEngine, err := ai.New(ai.WithConfig(o.cfg))
-	if err != nil {
-		return err
-	}
-
 	g := git.New(
 		git.WithDiffUnified(o.diffUnified),
 		git.WithExcludeList(o.excludeList),
 		git.WithEnableAmend(o.commitAmend),
 	)
-	diff, err := g.DiffFiles()
+	reviewMessage, err := Review(context.Background(), o.cfg, g, o.commitLang)
 	if err != nil {
 		return err
 	}
 
+	// Output core review summary
+	color.Yellow("================Review Summary====================")
+	color.Yellow("\n" + strings.TrimSpace(reviewMessage) + "\n\n")
+	color.Yellow("==================================================")
+
+	return nil
+}
+
+// Review reviews the changes of the diff of g, translating the review to the
+// language lang.
+func Review(ctx context.Context, cfg *options.Config, g *git.Command, lang string) (string, error) {
+	llmEngine, err := ai.New(ai.WithConfig(cfg))
+	if err != nil {
+		return "", err
+	}
+
+	diff, err := g.DiffFiles()
+	if err != nil {
+		return "", errbook.Wrap("Could not get diff files.", err)
+	}
+
 	vars := map[string]any{prompt.FileDiffsKey: diff}
 
 	reviewPrompt, err := prompt.GetPromptStringByTemplateName(prompt.CodeReviewTemplate, vars)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Get summarize comment from diff datas
 	color.Cyan("We are trying to review code changes")
-	reviewResp, err := llmEngine.CreateCompletion(context.Background(), reviewPrompt.Messages())
+	reviewResp, err := llmEngine.CreateCompletion(ctx, reviewPrompt.Messages())
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	reviewMessage := reviewResp.Explanation
-	if prompt.GetLanguage(o.commitLang) != prompt.DefaultLanguage {
+	if prompt.GetLanguage(lang) != prompt.DefaultLanguage {
 		translationPrompt, err := prompt.GetPromptStringByTemplateName(
 			prompt.TranslationTemplate, map[string]any{
-				prompt.OutputLanguageKey: prompt.GetLanguage(o.commitLang),
+				prompt.OutputLanguageKey: prompt.GetLanguage(lang),
 				prompt.OutputMessageKey:  reviewMessage,
 			},
 		)
 		if err != nil {
-			return err
+			return "", err
 		}
 
-		color.Cyan("we are trying to translate code review to " + o.commitLang + " language")
-		translationResp, err := llmEngine.CreateCompletion(context.Background(), translationPrompt.Messages())
+		color.Cyan("we are trying to translate code review to " + lang + " language")
+		translationResp, err := llmEngine.CreateCompletion(ctx, translationPrompt.Messages())
 		if err != nil {
-			return err
+			return "", err
 		}
 		reviewMessage = translationResp.Explanation
 	}
 
-	// Output core review summary
-	color.Yellow("================Review Summary====================")
-	color.Yellow("\n" + strings.TrimSpace(reviewMessage) + "\n\n")
-	color.Yellow("==================================================")
-
-	return nil
+	return reviewMessage, nil
 }
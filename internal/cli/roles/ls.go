@@ -0,0 +1,81 @@
+package roles
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+	"github.com/coding-hui/ai-terminal/internal/util/templates"
+)
+
+var lsExample = templates.Examples(`
+		# List the roles of the settings
+		ai roles ls`)
+
+type ls struct {
+	genericclioptions.IOStreams
+	cfg *options.Config
+}
+
+func newCmdLsRoles(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &ls{IOStreams: ioStreams, cfg: cfg}
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Short:   "List the roles.",
+		Example: lsExample,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+// Run executes ls command.
+func (o *ls) Run() error {
+	if len(o.cfg.Roles) == 0 {
+		_, _ = fmt.Fprintln(o.ErrOut, "No roles configured, add them to the roles of the settings.")
+		return nil
+	}
+
+	names := make([]string, 0, len(o.cfg.Roles))
+	for name := range o.cfg.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		line := name
+		if name == o.cfg.Role {
+			line += " " + console.StdoutStyles().Flag.Render("(default)")
+		}
+		if summary := summarize(o.cfg.Roles[name]); summary != "" {
+			line += "\t" + console.StdoutStyles().Comment.Render(summary)
+		}
+		_, _ = fmt.Fprintln(o.Out, line)
+	}
+
+	return nil
+}
+
+// summarize returns the first line of the first message of the role,
+// without reading the messages given as paths or URLs.
+func summarize(role options.Role) string {
+	const maxLen = 60
+	if len(role) == 0 {
+		return ""
+	}
+	summary, _, _ := strings.Cut(strings.TrimSpace(role[0]), "\n")
+	if runes := []rune(summary); len(runes) > maxLen {
+		summary = string(runes[:maxLen-1]) + "…"
+	}
+	if len(role) > 1 {
+		summary += fmt.Sprintf(" (+%d)", len(role)-1)
+	}
+	return summary
+}
@@ -0,0 +1,26 @@
+// Package roles lists and shows the roles, the reusable system prompts of
+// the settings.
+package roles
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+)
+
+// NewCmdRoles returns a cobra command for managing roles.
+func NewCmdRoles(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "roles",
+		Short: "List and show the roles, reusable system prompts.",
+		Run: func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newCmdLsRoles(ioStreams, cfg))
+	cmd.AddCommand(newCmdShowRole(ioStreams, cfg))
+
+	return cmd
+}
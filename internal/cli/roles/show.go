@@ -0,0 +1,69 @@
+package roles
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+	"github.com/coding-hui/ai-terminal/internal/util/templates"
+)
+
+var showExample = templates.Examples(`
+		# Show the system messages of the default role
+		ai roles show
+
+		# Show the system messages of the shell role, read from its files and URLs
+		ai roles show shell`)
+
+type show struct {
+	genericclioptions.IOStreams
+	cfg *options.Config
+}
+
+func newCmdShowRole(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &show{IOStreams: ioStreams, cfg: cfg}
+	cmd := &cobra.Command{
+		Use:          "show [role]",
+		Short:        "Show the system messages of a role.",
+		Example:      showExample,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := o.cfg.Role
+			if len(args) > 0 {
+				name = args[0]
+			}
+			return o.Run(name)
+		},
+	}
+
+	return cmd
+}
+
+// Run executes show command.
+func (o *show) Run(name string) error {
+	if name == "" {
+		return errbook.NewUserErrorf("No default role is set, name the role to show.")
+	}
+
+	messages, err := ai.LoadRole(o.cfg, name)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		_, _ = fmt.Fprintf(o.ErrOut, "The role %s has no system messages.\n", name)
+		return nil
+	}
+
+	for i, msg := range messages {
+		if i > 0 {
+			_, _ = fmt.Fprintln(o.Out)
+		}
+		_, _ = fmt.Fprintln(o.Out, msg)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package serve provides the command serving the models of the settings
+// over an OpenAI compatible API.
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/gateway"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+)
+
+const (
+	defaultListen = "127.0.0.1:8080"
+	// shutdownTimeout is how long the running requests have to finish once
+	// the server is stopped.
+	shutdownTimeout = 10 * time.Second
+)
+
+// Options is a struct to support the serve command
+type Options struct {
+	genericclioptions.IOStreams
+	cfg *options.Config
+
+	listen string
+	key    string
+}
+
+// NewCmdServe returns a cobra command serving the models of the settings.
+func NewCmdServe(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &Options{
+		IOStreams: ioStreams,
+		cfg:       cfg,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the models of the settings over an OpenAI compatible API.",
+		Example: `  # Serve on the default address
+  ai serve
+
+  # Require the clients to send a key
+  ai serve --listen 0.0.0.0:8080 --key "$GATEWAY_KEY"
+
+  # Ask the served models
+  curl http://127.0.0.1:8080/v1/chat/completions \
+    -d '{"model": "gpt-4o", "messages": [{"role": "user", "content": "hi"}]}'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.listen, "listen", defaultListen, "Address to listen on.")
+	cmd.Flags().StringVar(&o.key, "key", os.Getenv("AI_SERVE_KEY"), "Key the clients must send as a bearer token, defaults to $AI_SERVE_KEY.")
+
+	return cmd
+}
+
+// Run serves the API until the process is interrupted.
+func (o *Options) Run() error {
+	store, err := convo.GetConversationStore(o.cfg)
+	if err != nil {
+		return errbook.Wrap("Failed to initialize conversation store", err)
+	}
+
+	listener, err := net.Listen("tcp", o.listen)
+	if err != nil {
+		return errbook.Wrap("Could not listen on "+o.listen, err)
+	}
+
+	server := &http.Server{
+		Handler:           gateway.New(o.cfg, store, gateway.WithKey(o.key)).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(listener)
+	}()
+	_, _ = fmt.Fprintf(o.ErrOut, "Serving the models of the settings on %s\n",
+		console.StderrStyles().InlineCode.Render("http://"+listener.Addr().String()+"/v1"))
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
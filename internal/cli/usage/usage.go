@@ -0,0 +1,213 @@
+// Package usage reports the tokens and the cost of the completions.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+	"github.com/coding-hui/ai-terminal/internal/util/flag"
+	"github.com/coding-hui/ai-terminal/internal/util/genericclioptions"
+	"github.com/coding-hui/ai-terminal/internal/util/templates"
+)
+
+const (
+	byDay     = "day"
+	byModel   = "model"
+	byCommand = "command"
+)
+
+var usageExample = templates.Examples(`
+		# Show the usage per day
+		ai usage
+
+		# Show the usage of the last week per model
+		ai usage --by model --since 7d
+
+		# Print the usage per command as json
+		ai usage --by command --json`)
+
+// Options is a struct to support usage command.
+type Options struct {
+	genericclioptions.IOStreams
+	cfg   *options.Config
+	by    string
+	since time.Duration
+	json  bool
+}
+
+// Row is the usage of a group of completions.
+type Row struct {
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+
+	// priced tells whether the price of a model of the group is known
+	priced bool
+}
+
+// Report is the usage grouped by day, model or command.
+type Report struct {
+	By    string `json:"by"`
+	Rows  []Row  `json:"rows"`
+	Total Row    `json:"total"`
+}
+
+// NewCmdUsage returns a cobra command for reporting the usage.
+func NewCmdUsage(ioStreams genericclioptions.IOStreams, cfg *options.Config) *cobra.Command {
+	o := &Options{IOStreams: ioStreams, cfg: cfg}
+	cmd := &cobra.Command{
+		Use:     "usage",
+		Short:   "Report the tokens and the cost of the completions.",
+		Example: usageExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			return o.Run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.by, "by", byDay, console.StdoutStyles().FlagDesc.Render(options.Help["usage-by"]))
+	cmd.Flags().Var(flag.NewDurationFlag(o.since, &o.since), "since", console.StdoutStyles().FlagDesc.Render(options.Help["usage-since"]))
+	cmd.Flags().BoolVar(&o.json, "json", false, console.StdoutStyles().FlagDesc.Render(options.Help["usage-json"]))
+
+	return cmd
+}
+
+// Validate validates the provided options.
+func (o *Options) Validate() error {
+	if !slices.Contains([]string{byDay, byModel, byCommand}, o.by) {
+		return errbook.New("Invalid --by %q, the usage can be grouped by %s, %s or %s.", o.by, byDay, byModel, byCommand)
+	}
+	return nil
+}
+
+// Run executes usage command.
+func (o *Options) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	store, err := convo.GetConversationStore(o.cfg)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if o.since > 0 {
+		since = time.Now().Add(-o.since)
+	}
+	usages, err := store.ListUsages(ctx, since)
+	if err != nil {
+		return errbook.Wrap("Could not read the usage.", err)
+	}
+
+	report := o.report(usages)
+	if o.json {
+		enc := json.NewEncoder(o.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	if len(report.Rows) == 0 {
+		_, _ = fmt.Fprintln(o.ErrOut, "No usage recorded yet.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tTOTAL\tCOST\n", strings.ToUpper(report.By))
+	for _, row := range report.Rows {
+		printRow(w, row)
+	}
+	printRow(w, report.Total)
+	return w.Flush()
+}
+
+func (o *Options) report(usages []convo.Usage) Report {
+	report := Report{By: o.by, Rows: []Row{}, Total: Row{Key: "total"}}
+	index := make(map[string]int)
+	for _, u := range usages {
+		key := o.key(u)
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Rows)
+			index[key] = i
+			report.Rows = append(report.Rows, Row{Key: key})
+		}
+
+		price, priced := o.price(u.Model)
+		cost := price.Cost(u.PromptTokens, u.CompletionTokens)
+		report.Rows[i].add(u, cost, priced)
+		report.Total.add(u, cost, priced)
+	}
+
+	if o.by == byDay {
+		sort.SliceStable(report.Rows, func(i, j int) bool {
+			return report.Rows[i].Key < report.Rows[j].Key
+		})
+	} else {
+		sort.SliceStable(report.Rows, func(i, j int) bool {
+			return report.Rows[i].TotalTokens > report.Rows[j].TotalTokens
+		})
+	}
+
+	return report
+}
+
+func (o *Options) key(u convo.Usage) string {
+	var key string
+	switch o.by {
+	case byModel:
+		key = u.Model
+	case byCommand:
+		key = u.Command
+	default:
+		key = u.CreatedAt.Local().Format(time.DateOnly)
+	}
+	if key == "" {
+		return "unknown"
+	}
+	return key
+}
+
+// price returns the configured price of the model.
+func (o *Options) price(model string) (options.Price, bool) {
+	mod, ok := o.cfg.Models[model]
+	if !ok || mod.Price == (options.Price{}) {
+		return options.Price{}, false
+	}
+	return mod.Price, true
+}
+
+func (r *Row) add(u convo.Usage, cost float64, priced bool) {
+	r.Requests++
+	r.PromptTokens += u.PromptTokens
+	r.CompletionTokens += u.CompletionTokens
+	r.TotalTokens += u.TotalTokens
+	r.Cost += cost
+	r.priced = r.priced || priced
+}
+
+func printRow(w *tabwriter.Writer, row Row) {
+	cost := "-"
+	if row.priced {
+		cost = fmt.Sprintf("$%.4f", row.Cost)
+	}
+	_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n",
+		row.Key, row.Requests, row.PromptTokens, row.CompletionTokens, row.TotalTokens, cost)
+}
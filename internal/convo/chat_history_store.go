@@ -67,8 +67,9 @@ func (h *SimpleChatHistoryStore) SetMessages(ctx context.Context, convoID string
 }
 
 func (h *SimpleChatHistoryStore) Messages(_ context.Context, convoID string) ([]llms.ChatMessage, error) {
-	h.RLock()
-	defer h.RUnlock()
+	// loading the conversation fills the maps
+	h.Lock()
+	defer h.Unlock()
 
 	if !h.loaded[convoID] {
 		if err := h.load(convoID); err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -2,6 +2,7 @@ package convo
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/charmbracelet/x/exp/ordered"
@@ -25,6 +26,9 @@ const (
 
 	// ContentTypeText represents direct text content
 	ContentTypeText ContentType = "text"
+
+	// ContentTypeImage represents an image file, sent to models that take images
+	ContentTypeImage ContentType = "image"
 )
 
 // LoadContext contains metadata and content information for loaded resources.
@@ -56,6 +60,37 @@ type LoadContext struct {
 	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
 }
 
+// Usage is an entry of the usage ledger: the tokens a single completion
+// used, together with where they were spent.
+type Usage struct {
+	// ID is the auto-increment primary key
+	ID uint64 `db:"id" json:"id"`
+
+	// Model is the model that answered, may be a fallback of the configured one
+	Model string `db:"model" json:"model"`
+
+	// API is the API endpoint the model was called on
+	API string `db:"api" json:"api"`
+
+	// Command is the ai command the completion was made for, e.g. ask or commit
+	Command string `db:"command" json:"command"`
+
+	// ConversationID is the convo the completion belongs to, empty if not cached
+	ConversationID string `db:"conversation_id" json:"conversationId"`
+
+	// PromptTokens is the number of tokens of the input
+	PromptTokens int `db:"prompt_tokens" json:"promptTokens"`
+
+	// CompletionTokens is the number of tokens of the answer
+	CompletionTokens int `db:"completion_tokens" json:"completionTokens"`
+
+	// TotalTokens is the number of tokens billed for the completion
+	TotalTokens int `db:"total_tokens" json:"totalTokens"`
+
+	// CreatedAt is the time the completion finished
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
 // Conversation represents a chat convo with metadata and convo.
 // It tracks the convo ID, title, last update time, and optional model info.
 type Conversation struct {
@@ -70,6 +105,9 @@ type Conversation struct {
 
 	// Model optionally specifies the AI model used in the convo
 	Model *string `db:"model" json:"model"`
+
+	// Role is the role the convo was answered in, empty if none
+	Role string `db:"role" json:"role"`
 }
 
 // CacheDetailsMsg contains details about a cached conversation
@@ -99,21 +137,27 @@ type ChatMessageHistory interface {
 	InvalidateMessages(ctx context.Context, convoID string) error
 }
 
+// ErrNoMatches is returned by the stores when no conversation matches the
+// given ID or title.
+var ErrNoMatches = errors.New("no conversations found")
+
 // Store is the interface for chat convo convo store.
 type Store interface {
 	ChatMessageHistory
 	LoadContextStore
+	UsageStore
 
 	// LatestConversation returns the last message in the chat convo.
 	LatestConversation(ctx context.Context) (*Conversation, error)
-	// GetConversation retrieves a convo from the store
+	// GetConversation retrieves a convo from the store, ErrNoMatches if
+	// no conversation matches
 	GetConversation(ctx context.Context, convoID string) (*Conversation, error)
 	// ListConversations retrieves all convo id from the store
 	ListConversations(ctx context.Context) ([]Conversation, error)
 	// ListConversationsOlderThan retrieves all convo id from the store that are older than the given time.
 	ListConversationsOlderThan(ctx context.Context, t time.Duration) ([]Conversation, error)
-	// SaveConversation saves a convo to the store
-	SaveConversation(ctx context.Context, id, title, model string) error
+	// SaveConversation saves a convo to the store, an empty role keeps the saved one
+	SaveConversation(ctx context.Context, id, title, model, role string) error
 	// DeleteConversation removes a convo from the store
 	DeleteConversation(ctx context.Context, convoID string) error
 	// ClearConversations removes all convo from the store.
@@ -136,6 +180,14 @@ type LoadContextStore interface {
 	CleanContexts(ctx context.Context, conversationID string) (int64, error)
 }
 
+// UsageStore records the usage ledger
+type UsageStore interface {
+	// SaveUsage appends an entry to the usage ledger
+	SaveUsage(ctx context.Context, usage *Usage) error
+	// ListUsages retrieves the usage entries created since the given time, oldest first
+	ListUsages(ctx context.Context, since time.Time) ([]Usage, error)
+}
+
 // GetCurrentConversationID handles the logic for determining the current conversation ID
 // based on config parameters and existing conversations
 func GetCurrentConversationID(ctx context.Context, cfg *options.Config, store Store) (CacheDetailsMsg, error) {
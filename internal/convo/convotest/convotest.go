@@ -0,0 +1,376 @@
+// Package convotest is the conformance suite of the convo.Store
+// implementations, every datastore runs it against a store of its own.
+package convotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+// Backend creates the stores the suite runs against.
+type Backend struct {
+	// New returns an empty store.
+	New func(t *testing.T) convo.Store
+	// Age sets the last update of a conversation back by d, the suite has no
+	// other way to make a conversation old.
+	Age func(t *testing.T, s convo.Store, id string, d time.Duration)
+}
+
+// Run runs the conformance suite against the stores of the backend.
+func Run(t *testing.T, b Backend) {
+	t.Run("Conversations", func(t *testing.T) {
+		testConversations(t, b)
+	})
+	t.Run("ChatMessageHistory", func(t *testing.T) {
+		testChatMessageHistory(t, b.New(t))
+	})
+	t.Run("LoadContexts", func(t *testing.T) {
+		testLoadContexts(t, b.New(t))
+	})
+	t.Run("Usages", func(t *testing.T) {
+		testUsages(t, b.New(t))
+	})
+}
+
+func testConversations(t *testing.T, b Backend) {
+	ctx := context.Background()
+	h := b.New(t)
+	convoID := convo.NewConversationID()
+
+	t.Run("Save and Get conversation", func(t *testing.T) {
+		err := h.SaveConversation(ctx, convoID, "foo", "test", "")
+		require.NoError(t, err)
+
+		found, err := h.GetConversation(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", found.Title)
+		require.NotNil(t, found.Model)
+		assert.Equal(t, "test", *found.Model)
+		assert.False(t, found.UpdatedAt.IsZero())
+	})
+
+	t.Run("Get conversation by short id or title", func(t *testing.T) {
+		found, err := h.GetConversation(ctx, convoID[:convo.Sha1short])
+		require.NoError(t, err)
+		assert.Equal(t, convoID, found.ID)
+
+		found, err = h.GetConversation(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, convoID, found.ID)
+	})
+
+	t.Run("Save role", func(t *testing.T) {
+		id := convo.NewConversationID()
+		require.NoError(t, h.SaveConversation(ctx, id, "role", "test", "shell"))
+		// an empty role keeps the saved one
+		require.NoError(t, h.SaveConversation(ctx, id, "role", "test", ""))
+
+		found, err := h.GetConversation(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "shell", found.Role)
+	})
+
+	t.Run("Latest conversation", func(t *testing.T) {
+		convos, err := h.ListConversations(ctx)
+		require.NoError(t, err)
+		for _, c := range convos {
+			b.Age(t, h, c.ID, time.Minute)
+		}
+
+		id := convo.NewConversationID()
+		require.NoError(t, h.SaveConversation(ctx, id, "latest", "test", ""))
+
+		latest, err := h.LatestConversation(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, id, latest.ID)
+	})
+
+	t.Run("Get non-existent conversation", func(t *testing.T) {
+		_, err := h.GetConversation(ctx, "nonexistent")
+		assert.ErrorIs(t, err, convo.ErrNoMatches)
+	})
+
+	t.Run("Delete conversation", func(t *testing.T) {
+		require.NoError(t, h.DeleteConversation(ctx, convoID))
+
+		ok, err := h.ConversationExists(ctx, convoID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("List conversations", func(t *testing.T) {
+		// Create multiple conversations
+		ids := []string{
+			convo.NewConversationID(),
+			convo.NewConversationID(),
+		}
+		require.NoError(t, h.SaveConversation(ctx, ids[0], "first", "test", ""))
+		require.NoError(t, h.SaveConversation(ctx, ids[1], "second", "test", ""))
+
+		convos, err := h.ListConversations(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(convos), 2)
+	})
+
+	t.Run("ListOlderThan", func(t *testing.T) {
+		oldID := convo.NewConversationID()
+		require.NoError(t, h.SaveConversation(ctx, oldID, "old", "test", ""))
+		b.Age(t, h, oldID, time.Hour)
+
+		convos, err := h.ListConversationsOlderThan(ctx, 30*time.Minute)
+		require.NoError(t, err)
+		require.Len(t, convos, 1)
+		assert.Equal(t, oldID, convos[0].ID)
+	})
+
+	t.Run("Clear all conversations", func(t *testing.T) {
+		require.NoError(t, h.ClearConversations(ctx))
+
+		convos, err := h.ListConversations(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, convos)
+	})
+}
+
+func testChatMessageHistory(t *testing.T, h convo.Store) {
+	ctx := context.Background()
+	convoID := convo.NewConversationID()
+
+	t.Run("Add and get messages", func(t *testing.T) {
+		err := h.AddAIMessage(ctx, convoID, "foo")
+		require.NoError(t, err)
+
+		err = h.AddUserMessage(ctx, convoID, "bar")
+		require.NoError(t, err)
+
+		messages, err := h.Messages(ctx, convoID)
+		require.NoError(t, err)
+
+		assert.Equal(t, []llms.ChatMessage{
+			llms.AIChatMessage{Content: "foo"},
+			llms.HumanChatMessage{Content: "bar"},
+		}, messages)
+	})
+
+	t.Run("Set and add messages", func(t *testing.T) {
+		err := h.SetMessages(ctx,
+			convoID,
+			[]llms.ChatMessage{
+				llms.AIChatMessage{Content: "foo"},
+				llms.SystemChatMessage{Content: "bar"},
+			})
+		require.NoError(t, err)
+
+		err = h.AddUserMessage(ctx, convoID, "zoo")
+		require.NoError(t, err)
+
+		messages, err := h.Messages(ctx, convoID)
+		require.NoError(t, err)
+
+		assert.Equal(t, []llms.ChatMessage{
+			llms.AIChatMessage{Content: "foo"},
+			llms.SystemChatMessage{Content: "bar"},
+			llms.HumanChatMessage{Content: "zoo"},
+		}, messages)
+	})
+
+	t.Run("Persist and invalidate messages", func(t *testing.T) {
+		require.NoError(t, h.PersistentMessages(ctx, convoID))
+		require.NoError(t, h.InvalidateMessages(ctx, convoID))
+
+		messages, err := h.Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Get messages from non-existent conversation", func(t *testing.T) {
+		messages, err := h.Messages(ctx, "nonexistent")
+		assert.NoError(t, err)
+		assert.Len(t, messages, 0)
+	})
+}
+
+func testLoadContexts(t *testing.T, store convo.Store) {
+	ctx := context.Background()
+
+	t.Run("SaveContext and GetContext LoadContext", func(t *testing.T) {
+		lc := &convo.LoadContext{
+			Type:           "file",
+			URL:            "",
+			FilePath:       "/path/to/file",
+			Content:        "test content",
+			Name:           "test.txt",
+			ConversationID: "conv1",
+		}
+
+		err := store.SaveContext(ctx, lc)
+		require.NoError(t, err)
+		assert.NotZero(t, lc.ID)
+
+		retrieved, err := store.GetContext(ctx, lc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, lc.ID, retrieved.ID)
+		assert.Equal(t, lc.Type, retrieved.Type)
+		assert.Equal(t, lc.FilePath, retrieved.FilePath)
+		assert.Equal(t, lc.Content, retrieved.Content)
+		assert.Equal(t, lc.Name, retrieved.Name)
+		assert.Equal(t, lc.ConversationID, retrieved.ConversationID)
+	})
+
+	t.Run("GetContext non-existent LoadContext", func(t *testing.T) {
+		_, err := store.GetContext(ctx, uint64(999))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "load context not found")
+	})
+
+	t.Run("ListContextsByteConvoID LoadContexts by convo", func(t *testing.T) {
+		convID := "conv2"
+		lc1 := &convo.LoadContext{
+			ID:             uint64(2),
+			Type:           "file",
+			FilePath:       "/path/to/file1",
+			Content:        "content1",
+			Name:           "file1.txt",
+			ConversationID: convID,
+		}
+		lc2 := &convo.LoadContext{
+			ID:             uint64(3),
+			Type:           "file",
+			FilePath:       "/path/to/file2",
+			Content:        "content2",
+			Name:           "file2.txt",
+			ConversationID: convID,
+		}
+
+		require.NoError(t, store.SaveContext(ctx, lc1))
+		require.NoError(t, store.SaveContext(ctx, lc2))
+
+		contexts, err := store.ListContextsByteConvoID(ctx, convID)
+		require.NoError(t, err)
+		assert.Len(t, contexts, 2)
+	})
+
+	t.Run("DeleteContexts LoadContext", func(t *testing.T) {
+		lc := &convo.LoadContext{
+			ID:             uint64(4),
+			Type:           "file",
+			FilePath:       "/path/to/file",
+			Content:        "test content",
+			Name:           "test.txt",
+			ConversationID: "conv3",
+		}
+
+		require.NoError(t, store.SaveContext(ctx, lc))
+		require.NoError(t, store.DeleteContexts(ctx, lc.ID))
+
+		_, err := store.GetContext(ctx, lc.ID)
+		require.Error(t, err)
+		assert.Error(t, store.DeleteContexts(ctx, lc.ID))
+	})
+
+	t.Run("CleanContexts LoadContexts by convo", func(t *testing.T) {
+		convID := "conv4"
+		lc1 := &convo.LoadContext{
+			ID:             uint64(5),
+			Type:           "file",
+			FilePath:       "/path/to/file1",
+			Content:        "content1",
+			Name:           "file1.txt",
+			ConversationID: convID,
+		}
+		lc2 := &convo.LoadContext{
+			ID:             uint64(6),
+			Type:           "file",
+			FilePath:       "/path/to/file2",
+			Content:        "content2",
+			Name:           "file2.txt",
+			ConversationID: convID,
+		}
+
+		require.NoError(t, store.SaveContext(ctx, lc1))
+		require.NoError(t, store.SaveContext(ctx, lc2))
+
+		count, err := store.CleanContexts(ctx, convID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		contexts, err := store.ListContextsByteConvoID(ctx, convID)
+		require.NoError(t, err)
+		assert.Empty(t, contexts)
+	})
+
+	t.Run("Update existing LoadContext", func(t *testing.T) {
+		lc := &convo.LoadContext{
+			ID:             uint64(7),
+			Type:           "file",
+			FilePath:       "/path/to/file",
+			Content:        "initial content",
+			Name:           "test.txt",
+			ConversationID: "conv5",
+		}
+
+		require.NoError(t, store.SaveContext(ctx, lc))
+
+		// Update content
+		lc.Content = "updated content"
+		require.NoError(t, store.SaveContext(ctx, lc))
+
+		retrieved, err := store.GetContext(ctx, lc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "updated content", retrieved.Content)
+
+		contexts, err := store.ListContextsByteConvoID(ctx, "conv5")
+		require.NoError(t, err)
+		assert.Len(t, contexts, 1)
+	})
+}
+
+func testUsages(t *testing.T, store convo.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("SaveUsage and ListUsages", func(t *testing.T) {
+		usage := &convo.Usage{
+			Model:            "gpt-4o",
+			API:              "openai",
+			Command:          "ask",
+			ConversationID:   "conv1",
+			PromptTokens:     10,
+			CompletionTokens: 5,
+			TotalTokens:      15,
+			CreatedAt:        now.Add(-time.Hour),
+		}
+		require.NoError(t, store.SaveUsage(ctx, usage))
+		assert.NotZero(t, usage.ID)
+
+		usages, err := store.ListUsages(ctx, now.Add(-2*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, usages, 1)
+		assert.Equal(t, usage.ID, usages[0].ID)
+		assert.Equal(t, "gpt-4o", usages[0].Model)
+		assert.Equal(t, "openai", usages[0].API)
+		assert.Equal(t, "ask", usages[0].Command)
+		assert.Equal(t, "conv1", usages[0].ConversationID)
+		assert.Equal(t, 15, usages[0].TotalTokens)
+		assert.WithinDuration(t, usage.CreatedAt, usages[0].CreatedAt, time.Millisecond)
+	})
+
+	t.Run("ListUsages since a time", func(t *testing.T) {
+		require.NoError(t, store.SaveUsage(ctx, &convo.Usage{Model: "old", CreatedAt: now.Add(-48 * time.Hour)}))
+		require.NoError(t, store.SaveUsage(ctx, &convo.Usage{Model: "new"}))
+
+		usages, err := store.ListUsages(ctx, now.Add(-2*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, usages, 2)
+		assert.Equal(t, "gpt-4o", usages[0].Model)
+		assert.Equal(t, "new", usages[1].Model)
+	})
+}
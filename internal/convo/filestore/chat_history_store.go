@@ -0,0 +1,163 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+const messagesDir = "messages"
+
+// chatHistoryStore keeps the messages of each conversation in a JSON file.
+// Like convo.SimpleChatHistoryStore the added messages are held in memory
+// until they are persisted.
+type chatHistoryStore struct {
+	dir      *dir
+	messages map[string][]llms.ChatMessage
+	loaded   map[string]bool // tracks which conversations have been loaded
+
+	sync.Mutex // protects access to messages and loaded maps
+}
+
+func newChatHistoryStore(d *dir) *chatHistoryStore {
+	return &chatHistoryStore{
+		dir:      d,
+		messages: make(map[string][]llms.ChatMessage),
+		loaded:   make(map[string]bool),
+	}
+}
+
+// AddAIMessage adds an AIMessage to the chat message convo.
+func (h *chatHistoryStore) AddAIMessage(ctx context.Context, convoID, message string) error {
+	return h.AddMessage(ctx, convoID, llms.AIChatMessage{Content: message})
+}
+
+// AddUserMessage adds a user to the chat message convo.
+func (h *chatHistoryStore) AddUserMessage(ctx context.Context, convoID, message string) error {
+	return h.AddMessage(ctx, convoID, llms.HumanChatMessage{Content: message})
+}
+
+func (h *chatHistoryStore) AddMessage(_ context.Context, convoID string, message llms.ChatMessage) error {
+	h.Lock()
+	defer h.Unlock()
+
+	if err := h.load(convoID); err != nil {
+		return err
+	}
+	h.messages[convoID] = append(h.messages[convoID], message)
+	return nil
+}
+
+func (h *chatHistoryStore) SetMessages(_ context.Context, convoID string, messages []llms.ChatMessage) error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.messages[convoID] = messages
+	h.loaded[convoID] = true
+	return h.persist(convoID)
+}
+
+func (h *chatHistoryStore) Messages(_ context.Context, convoID string) ([]llms.ChatMessage, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if err := h.load(convoID); err != nil {
+		return nil, err
+	}
+	return h.messages[convoID], nil
+}
+
+func (h *chatHistoryStore) PersistentMessages(_ context.Context, convoID string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.persist(convoID)
+}
+
+func (h *chatHistoryStore) InvalidateMessages(_ context.Context, convoID string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	name, err := fileName(messagesDir, convoID)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	unlock, err := h.dir.lock()
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	defer unlock()
+
+	if err := h.dir.remove(name); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	delete(h.messages, convoID)
+	delete(h.loaded, convoID)
+	return nil
+}
+
+// load reads the persisted messages of a conversation once.
+func (h *chatHistoryStore) load(convoID string) error {
+	if h.loaded[convoID] {
+		return nil
+	}
+	name, err := fileName(messagesDir, convoID)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	unlock, err := h.dir.lock()
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	defer unlock()
+
+	var models []llms.ChatMessageModel
+	if err := h.dir.read(name, &models); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	h.messages[convoID] = nil
+	for _, model := range models {
+		if msg := model.ToChatMessage(); msg != nil {
+			h.messages[convoID] = append(h.messages[convoID], msg)
+		}
+	}
+	h.loaded[convoID] = true
+	return nil
+}
+
+// persist replaces the persisted messages of a conversation with the ones
+// held in memory.
+func (h *chatHistoryStore) persist(convoID string) error {
+	name, err := fileName(messagesDir, convoID)
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	models := []llms.ChatMessageModel{}
+	for _, v := range h.messages[convoID] {
+		if v == nil {
+			continue
+		}
+		model := llms.ConvertChatMessageToModel(v)
+		if ai, ok := v.(llms.AIChatMessage); ok {
+			model.Data.ReasoningContent = ai.ReasoningContent
+		}
+		models = append(models, model)
+	}
+
+	unlock, err := h.dir.lock()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	defer unlock()
+
+	if err := h.dir.write(name, models); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
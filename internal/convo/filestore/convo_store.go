@@ -0,0 +1,214 @@
+// Package filestore keeps the conversations as plain JSON files, for users
+// who don't want a database. An index file lists the conversations and the
+// load contexts, the messages and the contents of the load contexts have a
+// file each.
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func init() {
+	convo.RegisterConversationStore(&fileStoreFactory{})
+}
+
+var (
+	errNoMatches    = convo.ErrNoMatches
+	errManyMatches  = errors.New("multiple conversations matched the input")
+	errMissingTitle = errors.New("missing title")
+)
+
+type fileStoreFactory struct{}
+
+func (f *fileStoreFactory) Type() string {
+	return "file"
+}
+
+func (f *fileStoreFactory) Create(options *options.Config) (convo.Store, error) {
+	return NewFileStore(filepath.Join(options.DataStore.CachePath, "store"))
+}
+
+type FileStore struct {
+	// Dir is the directory the files are kept in.
+	Dir string
+
+	dir *dir
+
+	*chatHistoryStore
+	*loadContextStore
+	*usageStore
+}
+
+// Statically assert that FileStore implement the chat message convo interface.
+var _ convo.Store = &FileStore{}
+
+// NewFileStore creates a store keeping its files in the given directory.
+// Any number of stores and processes can share the directory.
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	d := &dir{path: path}
+	return &FileStore{
+		Dir:              path,
+		dir:              d,
+		chatHistoryStore: newChatHistoryStore(d),
+		loadContextStore: newLoadContextStore(d),
+		usageStore:       newUsageStore(d),
+	}, nil
+}
+
+// LatestConversation returns the last message in the chat convo.
+func (s *FileStore) LatestConversation(_ context.Context) (*convo.Conversation, error) {
+	var latest convo.Conversation
+	if err := s.dir.view(func(idx *index) error {
+		if len(idx.Conversations) > 0 {
+			latest = idx.Conversations[0]
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("FindHead: %w", err)
+	}
+	return &latest, nil
+}
+
+// GetConversation retrieves a convo from the store
+func (s *FileStore) GetConversation(_ context.Context, id string) (*convo.Conversation, error) {
+	var conversations []convo.Conversation
+	if err := s.dir.view(func(idx *index) error {
+		for _, c := range idx.Conversations {
+			if c.Title == id || len(id) >= convo.Sha1minLen && strings.HasPrefix(c.ID, id) {
+				conversations = append(conversations, c)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("find: %w", err)
+	}
+
+	if len(conversations) > 1 {
+		return nil, errManyMatches
+	}
+	if len(conversations) == 1 {
+		return &conversations[0], nil
+	}
+	return nil, errNoMatches
+}
+
+// ListConversations retrieves all convo ConvoID from the store
+func (s *FileStore) ListConversations(_ context.Context) ([]convo.Conversation, error) {
+	var convos []convo.Conversation
+	if err := s.dir.view(func(idx *index) error {
+		convos = idx.Conversations
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("ListConversations: %w", err)
+	}
+	return convos, nil
+}
+
+// ListConversationsOlderThan retrieves all convo ConvoID from the store that are older than the given time.
+func (s *FileStore) ListConversationsOlderThan(_ context.Context, t time.Duration) ([]convo.Conversation, error) {
+	before := time.Now().Add(-t)
+
+	var convos []convo.Conversation
+	if err := s.dir.view(func(idx *index) error {
+		for _, c := range idx.Conversations {
+			if c.UpdatedAt.Before(before) {
+				convos = append(convos, c)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("ListOlderThan: %w", err)
+	}
+	return convos, nil
+}
+
+// SaveConversation saves a convo to the store, an empty role keeps the
+// role the convo was saved with.
+func (s *FileStore) SaveConversation(_ context.Context, id, title, model, role string) error {
+	if id == "" {
+		return fmt.Errorf("SaveConversation: %w", errInvalidID)
+	}
+	if title == "" {
+		return fmt.Errorf("SaveConversation: %w", errMissingTitle)
+	}
+
+	if err := s.dir.update(func(idx *index) error {
+		now := time.Now().UTC()
+		for i := range idx.Conversations {
+			c := &idx.Conversations[i]
+			if c.ID != id {
+				continue
+			}
+			c.Title = title
+			c.Model = &model
+			if role != "" {
+				c.Role = role
+			}
+			c.UpdatedAt = now
+			return nil
+		}
+
+		idx.Conversations = append(idx.Conversations, convo.Conversation{
+			ID:        id,
+			Title:     title,
+			Model:     &model,
+			Role:      role,
+			UpdatedAt: now,
+		})
+		return nil
+	}); err != nil {
+		return fmt.Errorf("SaveConversation: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) DeleteConversation(_ context.Context, id string) error {
+	if err := s.dir.update(func(idx *index) error {
+		idx.Conversations = slices.DeleteFunc(idx.Conversations, func(c convo.Conversation) bool {
+			return c.ID == id
+		})
+		return nil
+	}); err != nil {
+		return fmt.Errorf("DeleteConversation: %w", err)
+	}
+	return nil
+}
+
+// ClearConversations resets messages.
+func (s *FileStore) ClearConversations(_ context.Context) error {
+	if err := s.dir.update(func(idx *index) error {
+		idx.Conversations = nil
+		return nil
+	}); err != nil {
+		return fmt.Errorf("ClearConversations: %w", err)
+	}
+	return nil
+}
+
+// ConversationExists checks if the given chat convo exists.
+func (s *FileStore) ConversationExists(_ context.Context, id string) (bool, error) {
+	var exists bool
+	if err := s.dir.view(func(idx *index) error {
+		exists = slices.ContainsFunc(idx.Conversations, func(c convo.Conversation) bool {
+			return c.ID == id
+		})
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("Exists: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,136 @@
+package filestore
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/convo/convotest"
+)
+
+func newTestStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+
+	s, err := NewFileStore(path)
+	require.NoError(t, err)
+	return s
+}
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	convotest.Run(t, convotest.Backend{
+		New: func(t *testing.T) convo.Store {
+			return newTestStore(t, t.TempDir())
+		},
+		Age: func(t *testing.T, s convo.Store, id string, d time.Duration) {
+			require.NoError(t, s.(*FileStore).dir.update(func(idx *index) error {
+				for i := range idx.Conversations {
+					if idx.Conversations[i].ID == id {
+						idx.Conversations[i].UpdatedAt = idx.Conversations[i].UpdatedAt.Add(-d)
+					}
+				}
+				return nil
+			}))
+		},
+	})
+}
+
+func TestFileStoreShared(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := t.TempDir()
+	s := newTestStore(t, path)
+	convoID := convo.NewConversationID()
+
+	t.Run("Another store reads the saved files", func(t *testing.T) {
+		require.NoError(t, s.SaveConversation(ctx, convoID, "foo", "test", "shell"))
+		require.NoError(t, s.SetMessages(ctx, convoID, []llms.ChatMessage{
+			llms.HumanChatMessage{Content: "foo"},
+			llms.AIChatMessage{Content: "bar", ReasoningContent: "thinking"},
+		}))
+		lc := &convo.LoadContext{Type: convo.ContentTypeText, Content: "zoo", Name: "zoo", ConversationID: convoID}
+		require.NoError(t, s.SaveContext(ctx, lc))
+
+		other := newTestStore(t, path)
+		found, err := other.GetConversation(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, "shell", found.Role)
+
+		messages, err := other.Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, []llms.ChatMessage{
+			llms.HumanChatMessage{Content: "foo"},
+			llms.AIChatMessage{Content: "bar", ReasoningContent: "thinking"},
+		}, messages)
+
+		contexts, err := other.ListContextsByteConvoID(ctx, convoID)
+		require.NoError(t, err)
+		require.Len(t, contexts, 1)
+		assert.Equal(t, lc.ID, contexts[0].ID)
+		assert.Equal(t, "zoo", contexts[0].Content)
+	})
+
+	t.Run("Added messages are not persisted yet", func(t *testing.T) {
+		require.NoError(t, s.AddUserMessage(ctx, convoID, "zoo"))
+
+		messages, err := newTestStore(t, path).Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Len(t, messages, 2)
+	})
+
+	t.Run("Concurrent stores keep every write", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// each store takes the lock file on its own
+				other, err := NewFileStore(path)
+				if !assert.NoError(t, err) {
+					return
+				}
+				for range 5 {
+					assert.NoError(t, other.SaveConversation(ctx, convo.NewConversationID(), "concurrent", "test", ""))
+					assert.NoError(t, other.SaveUsage(ctx, &convo.Usage{Model: "test"}))
+				}
+			}()
+		}
+		wg.Wait()
+
+		convos, err := s.ListConversations(ctx)
+		require.NoError(t, err)
+		assert.Len(t, convos, 41)
+
+		usages, err := s.ListUsages(ctx, time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, usages, 40)
+		assert.Equal(t, uint64(40), usages[len(usages)-1].ID)
+	})
+
+	t.Run("Writes leave no temporary files", func(t *testing.T) {
+		entries, err := os.ReadDir(path)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		assert.ElementsMatch(t, []string{lockName, indexName, usagesName, messagesDir, contextsDir}, names)
+	})
+}
+
+func TestFileName(t *testing.T) {
+	for _, id := range []string{"", ".", "..", "../index", `a\b`} {
+		_, err := fileName(messagesDir, id)
+		assert.ErrorIs(t, err, errInvalidID, id)
+	}
+}
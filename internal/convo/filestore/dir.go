@@ -0,0 +1,113 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const lockName = ".lock"
+
+var errInvalidID = errors.New("invalid id")
+
+// dir is the directory the files of the store are kept in. The files are
+// replaced atomically on writes, and the lock of the directory serializes
+// the reads and writes of the goroutines and processes sharing it.
+type dir struct {
+	path string
+
+	sync.Mutex // serializes the goroutines of the process on the lock file
+}
+
+// lock takes the lock of the directory and returns the function releasing it.
+func (d *dir) lock() (func(), error) {
+	d.Lock()
+
+	f, err := os.OpenFile(filepath.Join(d.path, lockName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		d.Unlock()
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		d.Unlock()
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+		d.Unlock()
+	}, nil
+}
+
+// read decodes the JSON file of the given name into v, a missing file
+// leaves v untouched.
+func (d *dir) read(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(d.path, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// write replaces the JSON file of the given name with v. The file is written
+// next to the old one and renamed over it, so a reader never sees a partial
+// file, not even after a crash.
+func (d *dir) write(name string, v any) (err error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(d.path, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// remove removes the file of the given name, a missing file is not an error.
+func (d *dir) remove(name string) error {
+	if err := os.Remove(filepath.Join(d.path, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// fileName returns the name of the file of an id in the given subdirectory,
+// the ids must not escape it.
+func fileName(subdir, id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", errInvalidID
+	}
+	return filepath.Join(subdir, id+".json"), nil
+}
@@ -0,0 +1,77 @@
+package filestore
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+const indexName = "index.json"
+
+// index is the content of the index file.
+type index struct {
+	// Conversations are the saved conversations, the latest updated first.
+	Conversations []convo.Conversation `json:"conversations"`
+	// Contexts maps the ids of the load contexts to their conversation.
+	Contexts map[uint64]string `json:"contexts"`
+	// LastContextID is the id of the last load context saved, the ids of
+	// the deleted ones are not reused.
+	LastContextID uint64 `json:"lastContextId"`
+}
+
+// readIndex reads the index file, the lock of the directory must be held.
+func readIndex(d *dir) (*index, error) {
+	idx := &index{}
+	if err := d.read(indexName, idx); err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	if idx.Contexts == nil {
+		idx.Contexts = make(map[uint64]string)
+	}
+	return idx, nil
+}
+
+// writeIndex replaces the index file, the lock of the directory must be held.
+func writeIndex(d *dir, idx *index) error {
+	slices.SortStableFunc(idx.Conversations, func(a, b convo.Conversation) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	if err := d.write(indexName, idx); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	return nil
+}
+
+// view runs fn on the index.
+func (d *dir) view(fn func(idx *index) error) error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	idx, err := readIndex(d)
+	if err != nil {
+		return err
+	}
+	return fn(idx)
+}
+
+// update runs fn on the index and writes the index back.
+func (d *dir) update(fn func(idx *index) error) error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	idx, err := readIndex(d)
+	if err != nil {
+		return err
+	}
+	if err := fn(idx); err != nil {
+		return err
+	}
+	return writeIndex(d, idx)
+}
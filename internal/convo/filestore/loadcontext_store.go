@@ -0,0 +1,138 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+const contextsDir = "contexts"
+
+var (
+	errLoadContextNotFound = errors.New("load context not found")
+	errMissingName         = errors.New("missing name")
+)
+
+// loadContextStore keeps each load context in a JSON file, the index maps
+// the load contexts to their conversation.
+type loadContextStore struct {
+	dir *dir
+}
+
+func newLoadContextStore(d *dir) *loadContextStore {
+	return &loadContextStore{dir: d}
+}
+
+func contextFileName(id uint64) string {
+	name, _ := fileName(contextsDir, strconv.FormatUint(id, 10))
+	return name
+}
+
+func (s *loadContextStore) SaveContext(_ context.Context, lc *convo.LoadContext) error {
+	if lc.Name == "" {
+		return fmt.Errorf("SaveContext: %w", errMissingName)
+	}
+	if lc.ConversationID == "" {
+		return fmt.Errorf("SaveContext: %w", errInvalidID)
+	}
+
+	if err := s.dir.update(func(idx *index) error {
+		saved := *lc
+		if _, ok := idx.Contexts[saved.ID]; !ok {
+			idx.LastContextID++
+			saved.ID = idx.LastContextID
+		}
+		saved.UpdatedAt = time.Now().UTC()
+
+		if err := s.dir.write(contextFileName(saved.ID), saved); err != nil {
+			return err
+		}
+		idx.Contexts[saved.ID] = saved.ConversationID
+		lc.ID = saved.ID
+		return nil
+	}); err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+	return nil
+}
+
+func (s *loadContextStore) GetContext(_ context.Context, id uint64) (*convo.LoadContext, error) {
+	var lc convo.LoadContext
+	if err := s.dir.view(func(idx *index) error {
+		if _, ok := idx.Contexts[id]; !ok {
+			return fmt.Errorf("%w: %d", errLoadContextNotFound, id)
+		}
+		return s.dir.read(contextFileName(id), &lc)
+	}); err != nil {
+		if errors.Is(err, errLoadContextNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("GetContext: %w", err)
+	}
+	return &lc, nil
+}
+
+func (s *loadContextStore) ListContextsByteConvoID(_ context.Context, conversationID string) ([]convo.LoadContext, error) {
+	var contexts []convo.LoadContext
+	if err := s.dir.view(func(idx *index) error {
+		for _, id := range contextIDs(idx, conversationID) {
+			var lc convo.LoadContext
+			if err := s.dir.read(contextFileName(id), &lc); err != nil {
+				return err
+			}
+			contexts = append(contexts, lc)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("ListContextsByteConvoID: %w", err)
+	}
+	return contexts, nil
+}
+
+func (s *loadContextStore) DeleteContexts(_ context.Context, id uint64) error {
+	if err := s.dir.update(func(idx *index) error {
+		if _, ok := idx.Contexts[id]; !ok {
+			return fmt.Errorf("no rows affected")
+		}
+		delete(idx.Contexts, id)
+		return s.dir.remove(contextFileName(id))
+	}); err != nil {
+		return fmt.Errorf("DeleteContexts: %w", err)
+	}
+	return nil
+}
+
+func (s *loadContextStore) CleanContexts(_ context.Context, conversationID string) (int64, error) {
+	var count int64
+	if err := s.dir.update(func(idx *index) error {
+		for _, id := range contextIDs(idx, conversationID) {
+			delete(idx.Contexts, id)
+			if err := s.dir.remove(contextFileName(id)); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("CleanContexts: %w", err)
+	}
+	return count, nil
+}
+
+// contextIDs returns the ids of the load contexts of a conversation in the
+// order they were created.
+func contextIDs(idx *index, conversationID string) []uint64 {
+	var ids []uint64
+	for id, convoID := range idx.Contexts {
+		if convoID == conversationID {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+const usagesName = "usages.json"
+
+// usageStore keeps the usage ledger in a JSON file.
+type usageStore struct {
+	dir *dir
+}
+
+func newUsageStore(d *dir) *usageStore {
+	return &usageStore{dir: d}
+}
+
+func (s *usageStore) SaveUsage(_ context.Context, usage *convo.Usage) error {
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	usage.CreatedAt = usage.CreatedAt.UTC()
+
+	unlock, err := s.dir.lock()
+	if err != nil {
+		return fmt.Errorf("SaveUsage: %w", err)
+	}
+	defer unlock()
+
+	var usages []convo.Usage
+	if err := s.dir.read(usagesName, &usages); err != nil {
+		return fmt.Errorf("SaveUsage: %w", err)
+	}
+
+	saved := *usage
+	saved.ID = 1
+	if len(usages) > 0 {
+		saved.ID = usages[len(usages)-1].ID + 1
+	}
+	if err := s.dir.write(usagesName, append(usages, saved)); err != nil {
+		return fmt.Errorf("SaveUsage: %w", err)
+	}
+	usage.ID = saved.ID
+
+	return nil
+}
+
+func (s *usageStore) ListUsages(_ context.Context, since time.Time) ([]convo.Usage, error) {
+	unlock, err := s.dir.lock()
+	if err != nil {
+		return nil, fmt.Errorf("ListUsages: %w", err)
+	}
+	defer unlock()
+
+	var usages []convo.Usage
+	if err := s.dir.read(usagesName, &usages); err != nil {
+		return nil, fmt.Errorf("ListUsages: %w", err)
+	}
+
+	usages = slices.DeleteFunc(usages, func(u convo.Usage) bool {
+		return u.CreatedAt.Before(since)
+	})
+	slices.SortStableFunc(usages, func(a, b convo.Usage) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return usages, nil
+}
@@ -0,0 +1,190 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+)
+
+var errInvalidID = errors.New("invalid id")
+
+// chatHistoryStore keeps the messages of the conversations on the database.
+// Like convo.SimpleChatHistoryStore the added messages are held in memory
+// until they are persisted.
+type chatHistoryStore struct {
+	db       *sqlx.DB
+	messages map[string][]llms.ChatMessage
+	loaded   map[string]bool // tracks which conversations have been loaded
+
+	sync.Mutex // protects access to messages and loaded maps
+}
+
+// message is a row of the messages table.
+type message struct {
+	ConversationID   string `db:"conversation_id"`
+	Seq              int    `db:"seq"`
+	Type             string `db:"type"`
+	Content          string `db:"content"`
+	ReasoningContent string `db:"reasoning_content"`
+}
+
+func newChatHistoryStore(db *sqlx.DB) *chatHistoryStore {
+	return &chatHistoryStore{
+		db:       db,
+		messages: make(map[string][]llms.ChatMessage),
+		loaded:   make(map[string]bool),
+	}
+}
+
+// AddAIMessage adds an AIMessage to the chat message convo.
+func (h *chatHistoryStore) AddAIMessage(ctx context.Context, convoID, message string) error {
+	return h.AddMessage(ctx, convoID, llms.AIChatMessage{Content: message})
+}
+
+// AddUserMessage adds a user to the chat message convo.
+func (h *chatHistoryStore) AddUserMessage(ctx context.Context, convoID, message string) error {
+	return h.AddMessage(ctx, convoID, llms.HumanChatMessage{Content: message})
+}
+
+func (h *chatHistoryStore) AddMessage(ctx context.Context, convoID string, message llms.ChatMessage) error {
+	h.Lock()
+	defer h.Unlock()
+
+	if err := h.load(ctx, convoID); err != nil {
+		return err
+	}
+	h.messages[convoID] = append(h.messages[convoID], message)
+	return nil
+}
+
+func (h *chatHistoryStore) SetMessages(ctx context.Context, convoID string, messages []llms.ChatMessage) error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.messages[convoID] = messages
+	h.loaded[convoID] = true
+	return h.persist(ctx, convoID)
+}
+
+func (h *chatHistoryStore) Messages(ctx context.Context, convoID string) ([]llms.ChatMessage, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if err := h.load(ctx, convoID); err != nil {
+		return nil, err
+	}
+	return h.messages[convoID], nil
+}
+
+func (h *chatHistoryStore) PersistentMessages(ctx context.Context, convoID string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.persist(ctx, convoID)
+}
+
+func (h *chatHistoryStore) InvalidateMessages(ctx context.Context, convoID string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	if convoID == "" {
+		return fmt.Errorf("delete: %w", errInvalidID)
+	}
+	if _, err := h.db.ExecContext(ctx, h.db.Rebind(`
+		DELETE FROM messages WHERE conversation_id = ?
+	`), convoID); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	delete(h.messages, convoID)
+	delete(h.loaded, convoID)
+	return nil
+}
+
+// load reads the persisted messages of a conversation once.
+func (h *chatHistoryStore) load(ctx context.Context, convoID string) error {
+	if h.loaded[convoID] {
+		return nil
+	}
+	if convoID == "" {
+		return fmt.Errorf("read: %w", errInvalidID)
+	}
+
+	var rows []message
+	if err := h.db.SelectContext(ctx, &rows, h.db.Rebind(`
+		SELECT conversation_id, seq, type, content, reasoning_content
+		FROM messages WHERE conversation_id = ?
+		ORDER BY seq
+	`), convoID); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	h.messages[convoID] = nil
+	for _, row := range rows {
+		model := llms.ChatMessageModel{
+			Type: row.Type,
+			Data: llms.ChatMessageModelData{
+				Type:             row.Type,
+				Content:          row.Content,
+				ReasoningContent: row.ReasoningContent,
+			},
+		}
+		if msg := model.ToChatMessage(); msg != nil {
+			h.messages[convoID] = append(h.messages[convoID], msg)
+		}
+	}
+	h.loaded[convoID] = true
+	return nil
+}
+
+// persist replaces the persisted messages of a conversation with the ones
+// held in memory.
+func (h *chatHistoryStore) persist(ctx context.Context, convoID string) error {
+	if convoID == "" {
+		return fmt.Errorf("write: %w", errInvalidID)
+	}
+
+	tx, err := h.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`
+		DELETE FROM messages WHERE conversation_id = ?
+	`), convoID); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	insert := tx.Rebind(`
+		INSERT INTO messages (
+			conversation_id, seq, type, content, reasoning_content
+		) VALUES (
+			?, ?, ?, ?, ?
+		)
+	`)
+	var seq int
+	for _, v := range h.messages[convoID] {
+		if v == nil {
+			continue
+		}
+		model := llms.ConvertChatMessageToModel(v)
+		if ai, ok := v.(llms.AIChatMessage); ok {
+			model.Data.ReasoningContent = ai.ReasoningContent
+		}
+		if _, err := tx.ExecContext(ctx, insert,
+			convoID, seq, model.Type, model.Data.Content, model.Data.ReasoningContent); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		seq++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
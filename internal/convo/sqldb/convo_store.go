@@ -0,0 +1,295 @@
+// Package sqldb keeps the conversations on a PostgreSQL or MySQL database,
+// so a team can share them on a central server. Unlike the sqlite3 store,
+// the messages of the conversations are kept on the database too.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	_ "github.com/lib/pq"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func init() {
+	convo.RegisterConversationStore(&storeFactory{dsType: Postgres})
+	convo.RegisterConversationStore(&storeFactory{dsType: MySQL})
+}
+
+var (
+	errNoMatches   = convo.ErrNoMatches
+	errManyMatches = errors.New("multiple conversations matched the input")
+)
+
+type storeFactory struct {
+	dsType string
+}
+
+func (f *storeFactory) Type() string {
+	return f.dsType
+}
+
+func (f *storeFactory) Create(options *options.Config) (convo.Store, error) {
+	return NewStore(f.dsType, WithDataStore(options.DataStore))
+}
+
+type Store struct {
+	// DB is the database connection.
+	DB *sqlx.DB
+	// Ctx is a context that can be used for the schema exec.
+	Ctx context.Context
+	// DataStore holds the url and the credentials of the database.
+	DataStore options.DataStore
+
+	dialect *dialect
+
+	*chatHistoryStore
+	*loadContextStore
+	*usageStore
+}
+
+// Statically assert that Store implement the chat message convo interface.
+var _ convo.Store = &Store{}
+
+// NewStore connects to the database of the given datastore type, Postgres or
+// MySQL, and creates the tables of the store.
+func NewStore(dsType string, opts ...Option) (*Store, error) {
+	d, ok := dialects[dsType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database %s", dsType)
+	}
+
+	s := &Store{dialect: d}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.Ctx == nil {
+		s.Ctx = context.Background()
+	}
+
+	if s.DB == nil {
+		dsn, err := d.dsn(s.DataStore)
+		if err != nil {
+			return nil, err
+		}
+		db, err := sqlx.Open(d.driver, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("open: %w", err)
+		}
+		s.DB = db
+	}
+
+	if err := s.DB.PingContext(s.Ctx); err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	for _, stmt := range d.schema {
+		if _, err := s.DB.ExecContext(s.Ctx, stmt); err != nil {
+			return nil, fmt.Errorf("create tables: %w", err)
+		}
+	}
+
+	s.chatHistoryStore = newChatHistoryStore(s.DB)
+	s.loadContextStore = newLoadContextStore(s.DB, d)
+	s.usageStore = newUsageStore(s.DB, d)
+
+	return s, nil
+}
+
+// LatestConversation returns the last message in the chat convo.
+func (s *Store) LatestConversation(ctx context.Context) (*convo.Conversation, error) {
+	var convo convo.Conversation
+	err := s.DB.GetContext(ctx, &convo, `
+		SELECT
+		  id, title, model, role, updated_at
+		FROM
+		  conversations
+		ORDER BY
+		  updated_at DESC
+		LIMIT
+		  1
+	`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("FindHead: %w", err)
+	}
+	return &convo, nil
+}
+
+// GetConversation retrieves a convo from the store
+func (s *Store) GetConversation(ctx context.Context, id string) (*convo.Conversation, error) {
+	var conversations []convo.Conversation
+	var err error
+
+	if len(id) < convo.Sha1minLen {
+		err = s.findByExactTitle(ctx, &conversations, id)
+	} else {
+		err = s.findByIDOrTitle(ctx, &conversations, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find: %w", err)
+	}
+
+	if len(conversations) > 1 {
+		return nil, errManyMatches
+	}
+	if len(conversations) == 1 {
+		return &conversations[0], nil
+	}
+	return nil, errNoMatches
+}
+
+func (s *Store) findByExactTitle(ctx context.Context, result *[]convo.Conversation, in string) error {
+	if err := s.DB.SelectContext(ctx, result, s.DB.Rebind(`
+		SELECT
+		  id, title, model, role, updated_at
+		FROM
+		  conversations
+		WHERE
+		  title = ?
+	`), in); err != nil {
+		return fmt.Errorf("findByExactTitle: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) findByIDOrTitle(ctx context.Context, result *[]convo.Conversation, in string) error {
+	if err := s.DB.SelectContext(ctx, result, s.DB.Rebind(`
+		SELECT
+		  id, title, model, role, updated_at
+		FROM
+		  conversations
+		WHERE
+		  id LIKE ? ESCAPE '!'
+		  OR title = ?
+	`), escapeLike(in)+"%", in); err != nil {
+		return fmt.Errorf("findByIDOrTitle: %w", err)
+	}
+	return nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern with '!'.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// ListConversations retrieves all convo ConvoID from the store
+func (s *Store) ListConversations(ctx context.Context) ([]convo.Conversation, error) {
+	var convos []convo.Conversation
+	if err := s.DB.SelectContext(ctx, &convos, `
+		SELECT
+		  id, title, model, role, updated_at
+		FROM
+		  conversations
+		ORDER BY
+		  updated_at DESC
+	`); err != nil {
+		return convos, fmt.Errorf("ListContextsByteConvoID: %w", err)
+	}
+	return convos, nil
+}
+
+// ListConversationsOlderThan retrieves all convo ConvoID from the store that are older than the given time.
+func (s *Store) ListConversationsOlderThan(ctx context.Context, t time.Duration) ([]convo.Conversation, error) {
+	var convos []convo.Conversation
+	if err := s.DB.SelectContext(ctx, &convos, s.DB.Rebind(`
+		SELECT
+		  id, title, model, role, updated_at
+		FROM
+		  conversations
+		WHERE
+		  updated_at < ?
+		`), time.Now().Add(-t).UTC()); err != nil {
+		return nil, fmt.Errorf("ListOlderThan: %w", err)
+	}
+	return convos, nil
+}
+
+// SaveConversation saves a convo to the store, an empty role keeps the
+// role the convo was saved with.
+func (s *Store) SaveConversation(ctx context.Context, id, title, model, role string) error {
+	// the time of the process, CURRENT_TIMESTAMP of MySQL drops the fraction
+	// the latest conversation is told by
+	now := time.Now().UTC()
+
+	res, err := s.DB.ExecContext(ctx, s.DB.Rebind(`
+		UPDATE conversations
+		SET
+		  title = ?,
+		  model = ?,
+		  role = COALESCE(NULLIF(?, ''), role),
+		  updated_at = ?
+		WHERE
+		  id = ?
+	`), title, model, role, now, id)
+	if err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	if _, err := s.DB.ExecContext(ctx, s.DB.Rebind(`
+		INSERT INTO
+		  conversations (id, title, model, role, updated_at)
+		VALUES
+		  (?, ?, ?, ?, ?)
+	`), id, title, model, role, now); err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteConversation(ctx context.Context, id string) error {
+	if _, err := s.DB.ExecContext(ctx, s.DB.Rebind(`
+		DELETE FROM conversations
+		WHERE
+		  id = ?
+	`), id); err != nil {
+		return fmt.Errorf("DeleteContexts: %w", err)
+	}
+	return nil
+}
+
+// ClearConversations resets messages.
+func (s *Store) ClearConversations(ctx context.Context) error {
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM conversations`); err != nil {
+		return fmt.Errorf("CleanContexts: %w", err)
+	}
+	return nil
+}
+
+// ConversationExists checks if the given chat convo exists.
+func (s *Store) ConversationExists(ctx context.Context, id string) (bool, error) {
+	var count int
+	if err := s.DB.GetContext(ctx, &count, s.DB.Rebind(`
+		SELECT
+		  COUNT(*)
+		FROM
+		  conversations
+		WHERE
+		  id = ?
+	`), id); err != nil {
+		return false, fmt.Errorf("Exists: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) Close() error {
+	return s.DB.Close() //nolint: wrapcheck
+}
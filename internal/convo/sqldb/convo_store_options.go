@@ -0,0 +1,37 @@
+package sqldb
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// Option is a function for creating a new store with other than the
+// default values.
+type Option func(s *Store)
+
+// WithDB is an option for NewStore for using an open database connection
+// instead of connecting to the datastore of the settings.
+func WithDB(db *sqlx.DB) Option {
+	return func(s *Store) {
+		s.DB = db
+	}
+}
+
+// WithContext is an option for NewStore to use a context internally when
+// running the schema.
+func WithContext(ctx context.Context) Option {
+	return func(s *Store) {
+		s.Ctx = ctx //nolint:fatcontext
+	}
+}
+
+// WithDataStore is an option for NewStore for setting the url and the
+// credentials of the database to connect to.
+func WithDataStore(ds options.DataStore) Option {
+	return func(s *Store) {
+		s.DataStore = ds
+	}
+}
@@ -0,0 +1,166 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/convo/convotest"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// testURLs are the environment variables with the url of the databases the
+// stores are tested on, e.g. the database containers the CI workflow runs:
+//
+//	docker run -d -p 5432:5432 -e POSTGRES_PASSWORD=ai postgres:16
+//	AI_TERMINAL_TEST_POSTGRES_URL='postgres://postgres:ai@localhost:5432/postgres?sslmode=disable'
+//	docker run -d -p 3306:3306 -e MYSQL_ROOT_PASSWORD=ai -e MYSQL_DATABASE=ai mysql:8
+//	AI_TERMINAL_TEST_MYSQL_URL='mysql://root:ai@localhost:3306/ai'
+var testURLs = map[string]string{
+	Postgres: "AI_TERMINAL_TEST_POSTGRES_URL",
+	MySQL:    "AI_TERMINAL_TEST_MYSQL_URL",
+}
+
+// newTestStore connects to the test database of the datastore type and
+// empties its tables. The test is skipped without a test database, on CI
+// it fails instead so the stores are never left untested.
+func newTestStore(t *testing.T, dsType string) *Store {
+	t.Helper()
+
+	url := os.Getenv(testURLs[dsType])
+	if url == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s is not set", testURLs[dsType])
+		}
+		t.Skipf("%s is not set", testURLs[dsType])
+	}
+
+	s, err := NewStore(dsType, WithDataStore(options.DataStore{Url: url}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	for _, table := range []string{"conversations", "messages", "load_contexts", "usages"} {
+		_, err := s.DB.Exec("DELETE FROM " + table)
+		require.NoError(t, err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	for _, dsType := range []string{Postgres, MySQL} {
+		t.Run(dsType, func(t *testing.T) {
+			convotest.Run(t, convotest.Backend{
+				New: func(t *testing.T) convo.Store {
+					return newTestStore(t, dsType)
+				},
+				Age: func(t *testing.T, s convo.Store, id string, d time.Duration) {
+					db := s.(*Store).DB
+					var updatedAt time.Time
+					require.NoError(t, db.Get(&updatedAt, db.Rebind(`SELECT updated_at FROM conversations WHERE id = ?`), id))
+					_, err := db.Exec(db.Rebind(`UPDATE conversations SET updated_at = ? WHERE id = ?`), updatedAt.Add(-d).UTC(), id)
+					require.NoError(t, err)
+				},
+			})
+			testLookups(t, newTestStore(t, dsType))
+			testMessages(t, newTestStore(t, dsType))
+		})
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	t.Run("rejects an unsupported database", func(t *testing.T) {
+		_, err := NewStore("oracle")
+		assert.Error(t, err)
+	})
+
+	t.Run("requires the url", func(t *testing.T) {
+		_, err := NewStore(Postgres)
+		assert.ErrorIs(t, err, errMissingURL)
+	})
+}
+
+func testLookups(t *testing.T, s *Store) {
+	ctx := context.Background()
+
+	t.Run("Wildcards match themselves only", func(t *testing.T) {
+		require.NoError(t, s.SaveConversation(ctx, convo.NewConversationID(), "foo", "test", ""))
+
+		_, err := s.GetConversation(ctx, "%%%%")
+		assert.True(t, errors.Is(err, errNoMatches))
+		_, err = s.GetConversation(ctx, "____")
+		assert.True(t, errors.Is(err, errNoMatches))
+	})
+}
+
+func testMessages(t *testing.T, s *Store) {
+	ctx := context.Background()
+	convoID := convo.NewConversationID()
+
+	t.Run("Add and get messages", func(t *testing.T) {
+		require.NoError(t, s.AddAIMessage(ctx, convoID, "foo"))
+		require.NoError(t, s.AddUserMessage(ctx, convoID, "bar"))
+
+		messages, err := s.Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, []llms.ChatMessage{
+			llms.AIChatMessage{Content: "foo"},
+			llms.HumanChatMessage{Content: "bar"},
+		}, messages)
+	})
+
+	t.Run("Persist messages", func(t *testing.T) {
+		require.NoError(t, s.PersistentMessages(ctx, convoID))
+
+		// another process reads them from the database
+		other := newChatHistoryStore(s.DB)
+		messages, err := other.Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, []llms.ChatMessage{
+			llms.AIChatMessage{Content: "foo"},
+			llms.HumanChatMessage{Content: "bar"},
+		}, messages)
+	})
+
+	t.Run("Set and add messages", func(t *testing.T) {
+		require.NoError(t, s.SetMessages(ctx, convoID, []llms.ChatMessage{
+			llms.AIChatMessage{Content: "foo", ReasoningContent: "thinking"},
+			llms.SystemChatMessage{Content: "bar"},
+		}))
+		require.NoError(t, s.AddUserMessage(ctx, convoID, "zoo"))
+
+		messages, err := s.Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Equal(t, []llms.ChatMessage{
+			llms.AIChatMessage{Content: "foo", ReasoningContent: "thinking"},
+			llms.SystemChatMessage{Content: "bar"},
+			llms.HumanChatMessage{Content: "zoo"},
+		}, messages)
+
+		// the added message is not persisted yet
+		messages, err = newChatHistoryStore(s.DB).Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Len(t, messages, 2)
+	})
+
+	t.Run("Invalidate messages", func(t *testing.T) {
+		require.NoError(t, s.InvalidateMessages(ctx, convoID))
+
+		messages, err := s.Messages(ctx, convoID)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Get messages from non-existent conversation", func(t *testing.T) {
+		messages, err := s.Messages(ctx, "nonexistent")
+		assert.NoError(t, err)
+		assert.Len(t, messages, 0)
+	})
+}
@@ -0,0 +1,233 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+const (
+	// Postgres is the type of the datastore kept on a PostgreSQL database
+	Postgres = "postgres"
+	// MySQL is the type of the datastore kept on a MySQL or MariaDB database
+	MySQL = "mysql"
+)
+
+var errMissingURL = errors.New("the datastore url is required")
+
+// dialect is what differs between the databases: the driver, the schema and
+// how to connect.
+type dialect struct {
+	// driver is the database/sql driver name, sqlx picks the bind vars by it
+	driver string
+	// schema is run statement by statement after connecting
+	schema []string
+	// returning tells the generated ids are returned by RETURNING, the
+	// driver does not support LastInsertId
+	returning bool
+	// dsn returns the data source name of the datastore settings
+	dsn func(ds options.DataStore) (string, error)
+}
+
+var dialects = map[string]*dialect{
+	Postgres: {
+		driver:    "postgres",
+		returning: true,
+		dsn:       postgresDSN,
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS conversations (
+			  id VARCHAR(64) NOT NULL PRIMARY KEY,
+			  title TEXT NOT NULL,
+			  model TEXT NOT NULL,
+			  role TEXT NOT NULL DEFAULT '',
+			  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			  CHECK (id <> ''),
+			  CHECK (title <> '')
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_conv_title ON conversations (title)`,
+			`CREATE INDEX IF NOT EXISTS idx_conv_updated_at ON conversations (updated_at)`,
+
+			`CREATE TABLE IF NOT EXISTS messages (
+			  conversation_id VARCHAR(64) NOT NULL,
+			  seq INTEGER NOT NULL,
+			  type VARCHAR(32) NOT NULL,
+			  content TEXT NOT NULL,
+			  reasoning_content TEXT NOT NULL,
+			  PRIMARY KEY (conversation_id, seq)
+			)`,
+
+			`CREATE TABLE IF NOT EXISTS load_contexts (
+			  id BIGSERIAL PRIMARY KEY,
+			  type VARCHAR(32) NOT NULL,
+			  url TEXT NOT NULL,
+			  file_path TEXT NOT NULL,
+			  content TEXT NOT NULL,
+			  name TEXT NOT NULL,
+			  conversation_id VARCHAR(64) NOT NULL,
+			  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			  CHECK (name <> ''),
+			  CHECK (conversation_id <> '')
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_loadctx_convo ON load_contexts (conversation_id)`,
+
+			`CREATE TABLE IF NOT EXISTS usages (
+			  id BIGSERIAL PRIMARY KEY,
+			  model TEXT NOT NULL,
+			  api TEXT NOT NULL,
+			  command TEXT NOT NULL,
+			  conversation_id VARCHAR(64) NOT NULL,
+			  prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			  completion_tokens INTEGER NOT NULL DEFAULT 0,
+			  total_tokens INTEGER NOT NULL DEFAULT 0,
+			  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_usages_created_at ON usages (created_at)`,
+		},
+	},
+	MySQL: {
+		driver: "mysql",
+		dsn:    mysqlDSN,
+		// MySQL has no CREATE INDEX IF NOT EXISTS, the indexes are declared
+		// with the tables
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS conversations (
+			  id VARCHAR(64) NOT NULL PRIMARY KEY,
+			  title TEXT NOT NULL,
+			  model TEXT NOT NULL,
+			  role VARCHAR(255) NOT NULL DEFAULT '',
+			  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			  CHECK (id <> ''),
+			  CHECK (title <> ''),
+			  INDEX idx_conv_title (title(191)),
+			  INDEX idx_conv_updated_at (updated_at)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+			`CREATE TABLE IF NOT EXISTS messages (
+			  conversation_id VARCHAR(64) NOT NULL,
+			  seq INTEGER NOT NULL,
+			  type VARCHAR(32) NOT NULL,
+			  content LONGTEXT NOT NULL,
+			  reasoning_content LONGTEXT NOT NULL,
+			  PRIMARY KEY (conversation_id, seq)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+			`CREATE TABLE IF NOT EXISTS load_contexts (
+			  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			  type VARCHAR(32) NOT NULL,
+			  url TEXT NOT NULL,
+			  file_path TEXT NOT NULL,
+			  content LONGTEXT NOT NULL,
+			  name TEXT NOT NULL,
+			  conversation_id VARCHAR(64) NOT NULL,
+			  updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			  CHECK (name <> ''),
+			  CHECK (conversation_id <> ''),
+			  INDEX idx_loadctx_convo (conversation_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+			`CREATE TABLE IF NOT EXISTS usages (
+			  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			  model VARCHAR(255) NOT NULL,
+			  api VARCHAR(255) NOT NULL,
+			  command VARCHAR(255) NOT NULL,
+			  conversation_id VARCHAR(64) NOT NULL,
+			  prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			  completion_tokens INTEGER NOT NULL DEFAULT 0,
+			  total_tokens INTEGER NOT NULL DEFAULT 0,
+			  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			  INDEX idx_usages_created_at (created_at)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+	},
+}
+
+// postgresDSN returns the url or the key=value connection string of the
+// settings, with the username and password of the settings when given.
+func postgresDSN(ds options.DataStore) (string, error) {
+	if ds.Url == "" {
+		return "", errMissingURL
+	}
+	if ds.Username == "" && ds.Password == "" {
+		return ds.Url, nil
+	}
+
+	if strings.HasPrefix(ds.Url, "postgres://") || strings.HasPrefix(ds.Url, "postgresql://") {
+		u, err := url.Parse(ds.Url)
+		if err != nil {
+			return "", fmt.Errorf("invalid datastore url: %w", err)
+		}
+		username, password := ds.Username, ds.Password
+		if u.User != nil {
+			if username == "" {
+				username = u.User.Username()
+			}
+			if p, ok := u.User.Password(); ok && password == "" {
+				password = p
+			}
+		}
+		u.User = url.UserPassword(username, password)
+		return u.String(), nil
+	}
+
+	dsn := ds.Url
+	if ds.Username != "" {
+		dsn += " user=" + quoteConnValue(ds.Username)
+	}
+	if ds.Password != "" {
+		dsn += " password=" + quoteConnValue(ds.Password)
+	}
+	return dsn, nil
+}
+
+// quoteConnValue quotes a value of a key=value connection string.
+func quoteConnValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// mysqlDSN returns the DSN of the driver for the url of the settings, given
+// as a DSN of the driver or a mysql:// url, with the username and password
+// of the settings when given. The times are read and written in UTC.
+func mysqlDSN(ds options.DataStore) (string, error) {
+	if ds.Url == "" {
+		return "", errMissingURL
+	}
+
+	var (
+		cfg *mysql.Config
+		err error
+	)
+	if strings.HasPrefix(ds.Url, "mysql://") {
+		u, perr := url.Parse(ds.Url)
+		if perr != nil {
+			return "", fmt.Errorf("invalid datastore url: %w", perr)
+		}
+		cfg, err = mysql.ParseDSN(fmt.Sprintf("tcp(%s)/%s?%s", u.Host, strings.TrimPrefix(u.Path, "/"), u.RawQuery))
+		if err == nil && u.User != nil {
+			cfg.User = u.User.Username()
+			cfg.Passwd, _ = u.User.Password()
+		}
+	} else {
+		cfg, err = mysql.ParseDSN(ds.Url)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid datastore url: %w", err)
+	}
+
+	if ds.Username != "" {
+		cfg.User = ds.Username
+	}
+	if ds.Password != "" {
+		cfg.Passwd = ds.Password
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,80 @@
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ds   options.DataStore
+		want string
+	}{
+		"url": {
+			ds:   options.DataStore{Url: "postgres://ai:secret@db:5432/ai?sslmode=disable"},
+			want: "postgres://ai:secret@db:5432/ai?sslmode=disable",
+		},
+		"url with credentials": {
+			ds:   options.DataStore{Url: "postgres://db/ai", Username: "ai", Password: "p@ss word"},
+			want: "postgres://ai:p%40ss%20word@db/ai",
+		},
+		"url with the user of the url": {
+			ds:   options.DataStore{Url: "postgresql://ai@db/ai", Password: "secret"},
+			want: "postgresql://ai:secret@db/ai",
+		},
+		"connection string with credentials": {
+			ds:   options.DataStore{Url: "host=db dbname=ai", Username: "ai", Password: `it's`},
+			want: `host=db dbname=ai user='ai' password='it\'s'`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dsn, err := postgresDSN(tc.ds)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, dsn)
+		})
+	}
+
+	t.Run("requires the url", func(t *testing.T) {
+		_, err := postgresDSN(options.DataStore{Username: "ai"})
+		assert.ErrorIs(t, err, errMissingURL)
+	})
+}
+
+func TestMySQLDSN(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ds   options.DataStore
+		want string
+	}{
+		"driver dsn": {
+			ds:   options.DataStore{Url: "ai:secret@tcp(db:3306)/ai"},
+			want: "ai:secret@tcp(db:3306)/ai?parseTime=true",
+		},
+		"driver dsn with credentials": {
+			ds:   options.DataStore{Url: "tcp(db:3306)/ai", Username: "ai", Password: "secret"},
+			want: "ai:secret@tcp(db:3306)/ai?parseTime=true",
+		},
+		"url": {
+			ds:   options.DataStore{Url: "mysql://ai:secret@db:3306/ai?timeout=5s"},
+			want: "ai:secret@tcp(db:3306)/ai?parseTime=true&timeout=5s",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dsn, err := mysqlDSN(tc.ds)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, dsn)
+		})
+	}
+
+	t.Run("rejects an invalid dsn", func(t *testing.T) {
+		_, err := mysqlDSN(options.DataStore{Url: "db:3306"})
+		assert.Error(t, err)
+	})
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, "a!%b!_c!!d", escapeLike("a%b_c!d"))
+}
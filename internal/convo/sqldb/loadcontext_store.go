@@ -0,0 +1,154 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+var (
+	errLoadContextNotFound = errors.New("load context not found")
+)
+
+type loadContextStore struct {
+	db      *sqlx.DB
+	dialect *dialect
+}
+
+func newLoadContextStore(db *sqlx.DB, d *dialect) *loadContextStore {
+	return &loadContextStore{db: db, dialect: d}
+}
+
+func (s *loadContextStore) SaveContext(ctx context.Context, lc *convo.LoadContext) error {
+	now := time.Now().UTC()
+
+	res, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		UPDATE load_contexts
+		SET
+			type = ?,
+			url = ?,
+			file_path = ?,
+			content = ?,
+			name = ?,
+			conversation_id = ?,
+			updated_at = ?
+		WHERE
+			id = ?
+	`), lc.Type, lc.URL, lc.FilePath, lc.Content, lc.Name, lc.ConversationID, now, lc.ID)
+	if err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	id, err := insert(ctx, s.db, s.dialect, `
+		INSERT INTO load_contexts (
+			type, url, file_path, content, name, conversation_id, updated_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?
+		)
+	`, lc.Type, lc.URL, lc.FilePath, lc.Content, lc.Name, lc.ConversationID, now)
+	if err != nil {
+		return fmt.Errorf("SaveContext: %w", err)
+	}
+	lc.ID = id
+
+	return nil
+}
+
+func (s *loadContextStore) GetContext(ctx context.Context, id uint64) (*convo.LoadContext, error) {
+	var lc convo.LoadContext
+	err := s.db.GetContext(ctx, &lc, s.db.Rebind(`
+		SELECT id, type, url, file_path, content, name, conversation_id, updated_at
+		FROM load_contexts WHERE id = ?
+	`), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %v", errLoadContextNotFound, err)
+		}
+		return nil, fmt.Errorf("GetContext: %w", err)
+	}
+	return &lc, nil
+}
+
+func (s *loadContextStore) ListContextsByteConvoID(ctx context.Context, conversationID string) ([]convo.LoadContext, error) {
+	var contexts []convo.LoadContext
+	if err := s.db.SelectContext(ctx, &contexts, s.db.Rebind(`
+		SELECT id, type, url, file_path, content, name, conversation_id, updated_at
+		FROM load_contexts WHERE conversation_id = ?
+		ORDER BY id
+	`), conversationID); err != nil {
+		return nil, fmt.Errorf("ListContextsByteConvoID: %w", err)
+	}
+	return contexts, nil
+}
+
+func (s *loadContextStore) DeleteContexts(ctx context.Context, id uint64) error {
+	res, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		DELETE FROM load_contexts WHERE id = ?
+	`), id)
+	if err != nil {
+		return fmt.Errorf("DeleteContexts: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteContexts: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("DeleteContexts: no rows affected")
+	}
+
+	return nil
+}
+
+func (s *loadContextStore) CleanContexts(ctx context.Context, conversationID string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		DELETE FROM load_contexts WHERE conversation_id = ?
+	`), conversationID)
+	if err != nil {
+		return 0, fmt.Errorf("CleanContexts: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("CleanContexts: %w", err)
+	}
+
+	return rows, nil
+}
+
+// insert runs an INSERT statement and returns the generated id of the row.
+func insert(ctx context.Context, db *sqlx.DB, d *dialect, query string, args ...any) (uint64, error) {
+	if d.returning {
+		var id uint64
+		if err := db.GetContext(ctx, &id, db.Rebind(query+" RETURNING id"), args...); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+
+	res, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
@@ -0,0 +1,54 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+type usageStore struct {
+	db      *sqlx.DB
+	dialect *dialect
+}
+
+func newUsageStore(db *sqlx.DB, d *dialect) *usageStore {
+	return &usageStore{db: db, dialect: d}
+}
+
+func (s *usageStore) SaveUsage(ctx context.Context, usage *convo.Usage) error {
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	usage.CreatedAt = usage.CreatedAt.UTC()
+
+	id, err := insert(ctx, s.db, s.dialect, `
+		INSERT INTO usages (
+			model, api, command, conversation_id, prompt_tokens, completion_tokens, total_tokens, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`, usage.Model, usage.API, usage.Command, usage.ConversationID,
+		usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("SaveUsage: %w", err)
+	}
+	usage.ID = id
+
+	return nil
+}
+
+func (s *usageStore) ListUsages(ctx context.Context, since time.Time) ([]convo.Usage, error) {
+	var usages []convo.Usage
+	if err := s.db.SelectContext(ctx, &usages, s.db.Rebind(`
+		SELECT id, model, api, command, conversation_id, prompt_tokens, completion_tokens, total_tokens, created_at
+		FROM usages WHERE created_at >= ?
+		ORDER BY created_at, id
+	`), since.UTC()); err != nil {
+		return nil, fmt.Errorf("ListUsages: %w", err)
+	}
+	return usages, nil
+}
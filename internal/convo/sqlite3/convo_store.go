@@ -21,7 +21,7 @@ func init() {
 }
 
 var (
-	errNoMatches   = errors.New("no conversations found")
+	errNoMatches   = convo.ErrNoMatches
 	errManyMatches = errors.New("multiple conversations matched the input")
 )
 
@@ -53,6 +53,7 @@ type SqliteStore struct {
 
 	*convo.SimpleChatHistoryStore
 	*sqliteLoadContextStore
+	*sqliteUsageStore
 }
 
 // Statically assert that SqliteStore implement the chat message convo interface.
@@ -165,16 +166,19 @@ func (h *SqliteStore) ListConversationsOlderThan(ctx context.Context, t time.Dur
 	return convos, nil
 }
 
-func (h *SqliteStore) SaveConversation(ctx context.Context, id, title, model string) error {
+// SaveConversation saves a convo to the store, an empty role keeps the
+// role the convo was saved with.
+func (h *SqliteStore) SaveConversation(ctx context.Context, id, title, model, role string) error {
 	res, err := h.DB.ExecContext(ctx, h.DB.Rebind(`
 		UPDATE conversations
 		SET
 		  title = ?,
 		  model = ?,
+		  role = COALESCE(NULLIF(?, ''), role),
 		  updated_at = CURRENT_TIMESTAMP
 		WHERE
 		  id = ?
-	`), title, model, id)
+	`), title, model, role, id)
 	if err != nil {
 		return fmt.Errorf("SaveContext: %w", err)
 	}
@@ -190,10 +194,10 @@ func (h *SqliteStore) SaveConversation(ctx context.Context, id, title, model str
 
 	if _, err := h.DB.ExecContext(ctx, h.DB.Rebind(`
 		INSERT INTO
-		  conversations (id, title, model)
+		  conversations (id, title, model, role)
 		VALUES
-		  (?, ?, ?)
-	`), id, title, model); err != nil {
+		  (?, ?, ?, ?)
+	`), id, title, model, role); err != nil {
 		return fmt.Errorf("SaveContext: %w", err)
 	}
 
@@ -3,6 +3,7 @@ package sqlite3
 import (
 	"context"
 	"os"
+	"slices"
 
 	"github.com/jmoiron/sqlx"
 
@@ -16,6 +17,7 @@ const DefaultSchema = `CREATE TABLE
 		    id string NOT NULL PRIMARY KEY,
 		    title string NOT NULL,
 		    model string NOT NULL,
+		    role string NOT NULL DEFAULT '',
 		    updated_at datetime NOT NULL DEFAULT (strftime ('%Y-%m-%d %H:%M:%f', 'now')),
 		    CHECK (id <> ''),
 		    CHECK (title <> '')
@@ -37,6 +39,19 @@ CREATE TABLE IF NOT EXISTS load_contexts (
 	FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
 );
 CREATE INDEX IF NOT EXISTS idx_loadctx_convo ON load_contexts (conversation_id);
+
+CREATE TABLE IF NOT EXISTS usages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	model string NOT NULL,
+	api string NOT NULL,
+	command string NOT NULL,
+	conversation_id string NOT NULL,
+	prompt_tokens integer NOT NULL DEFAULT 0,
+	completion_tokens integer NOT NULL DEFAULT 0,
+	total_tokens integer NOT NULL DEFAULT 0,
+	created_at datetime NOT NULL DEFAULT (strftime ('%Y-%m-%d %H:%M:%f', 'now'))
+);
+CREATE INDEX IF NOT EXISTS idx_usages_created_at ON usages (created_at);
 `
 
 // SqliteChatMessageHistoryOption is a function for creating new
@@ -112,6 +127,9 @@ func applyChatOptions(options ...SqliteChatMessageHistoryOption) *SqliteStore {
 			errbook.HandleError(errbook.Wrap("Could not open database.", err))
 			os.Exit(1)
 		}
+		// sqlite takes one writer at a time, concurrent completions of the
+		// process would fail with SQLITE_BUSY on their own connections
+		db.SetMaxOpenConns(1)
 		h.DB = db
 	}
 
@@ -125,8 +143,27 @@ func applyChatOptions(options ...SqliteChatMessageHistoryOption) *SqliteStore {
 		os.Exit(1)
 	}
 
+	if err := migrate(h.Ctx, h.DB); err != nil {
+		errbook.HandleError(errbook.Wrap("Could not migrate convo db table.", err))
+		os.Exit(1)
+	}
+
 	h.SimpleChatHistoryStore = convo.NewSimpleChatHistoryStore(h.DataPath)
 	h.sqliteLoadContextStore = newLoadContextStore(h.DB)
+	h.sqliteUsageStore = newUsageStore(h.DB)
 
 	return h
 }
+
+// migrate adds the columns introduced after a database was created.
+func migrate(ctx context.Context, db *sqlx.DB) error {
+	var columns []string
+	if err := db.SelectContext(ctx, &columns, `SELECT name FROM pragma_table_info('conversations')`); err != nil {
+		return err
+	}
+	if slices.Contains(columns, "role") {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `ALTER TABLE conversations ADD COLUMN role string NOT NULL DEFAULT ''`)
+	return err
+}
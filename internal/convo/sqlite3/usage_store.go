@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/coding-hui/ai-terminal/internal/convo"
+)
+
+type sqliteUsageStore struct {
+	db *sqlx.DB
+}
+
+func newUsageStore(db *sqlx.DB) *sqliteUsageStore {
+	return &sqliteUsageStore{db: db}
+}
+
+func (s *sqliteUsageStore) SaveUsage(ctx context.Context, usage *convo.Usage) error {
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	usage.CreatedAt = usage.CreatedAt.UTC()
+
+	res, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO usages (
+			model, api, command, conversation_id, prompt_tokens, completion_tokens, total_tokens, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`), usage.Model, usage.API, usage.Command, usage.ConversationID,
+		usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("SaveUsage: %w", err)
+	}
+
+	lastInsertId, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("SaveUsage: %w", err)
+	}
+	usage.ID = uint64(lastInsertId)
+
+	return nil
+}
+
+func (s *sqliteUsageStore) ListUsages(ctx context.Context, since time.Time) ([]convo.Usage, error) {
+	var usages []convo.Usage
+	if err := s.db.SelectContext(ctx, &usages, s.db.Rebind(`
+		SELECT id, model, api, command, conversation_id, prompt_tokens, completion_tokens, total_tokens, created_at
+		FROM usages WHERE created_at >= ?
+		ORDER BY created_at, id
+	`), since.UTC()); err != nil {
+		return nil, fmt.Errorf("ListUsages: %w", err)
+	}
+	return usages, nil
+}
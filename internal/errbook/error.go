@@ -49,3 +49,7 @@ func (m AiError) Error() string {
 func (m AiError) Reason() string {
 	return m.reason
 }
+
+func (m AiError) Unwrap() error {
+	return m.err
+}
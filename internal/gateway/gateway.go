@@ -0,0 +1,421 @@
+// Package gateway serves the models of the settings over an OpenAI
+// compatible API, saving the conversations to the conversation store.
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/exp/ordered"
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// ConversationHeader carries the ID of the conversation a request continues
+// and, in the response, the ID of the conversation it was saved to.
+const ConversationHeader = "X-Conversation-ID"
+
+// maxRequestSize is the largest request body read, images included.
+const maxRequestSize = 32 << 20
+
+// Server answers the chat completion requests with the models of the
+// settings, resolving their aliases, fallbacks and keys like the commands.
+type Server struct {
+	cfg           *options.Config
+	store         convo.Store
+	key           string
+	engineOptions []ai.Option
+}
+
+// Option configures the server.
+type Option func(*Server)
+
+// WithKey requires the clients to send the key as a bearer token.
+func WithKey(key string) Option {
+	return func(s *Server) {
+		s.key = key
+	}
+}
+
+// WithEngineOptions adds options to the engines answering the requests.
+func WithEngineOptions(opts ...ai.Option) Option {
+	return func(s *Server) {
+		s.engineOptions = append(s.engineOptions, opts...)
+	}
+}
+
+// New returns a server answering with the models of the settings. The keys
+// of the APIs are resolved once, api-key-cmd does not run on every request.
+func New(cfg *options.Config, store convo.Store, opts ...Option) *Server {
+	s := &Server{cfg: withKeys(cfg), store: store}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("GET /v1/models", s.models)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "not_found", fmt.Sprintf("%s %s is not served", r.Method, r.URL.Path))
+	})
+
+	if s.key == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.key)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Invalid API key.")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) models(w http.ResponseWriter, _ *http.Request) {
+	names := make([]string, 0, len(s.cfg.Models))
+	for name := range s.cfg.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := modelList{Object: "list", Data: make([]modelInfo, 0, len(names))}
+	for _, name := range names {
+		list.Data = append(list.Data, modelInfo{ID: name, Object: "model", OwnedBy: s.cfg.Models[name].API})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid request body: "+err.Error())
+		return
+	}
+	switch {
+	case len(req.Messages) == 0:
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "messages must not be empty")
+		return
+	case len(req.Tools) > 0:
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "tools are not supported")
+		return
+	case req.N > 1:
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "only a single choice is supported")
+		return
+	}
+
+	messages, err := toChatMessages(req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	cfg, err := s.config(req)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Sprintf("The model %s is not in the settings.", req.Model))
+		return
+	}
+
+	id, err := s.conversationID(ctx, r.Header.Get(ConversationHeader))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "conversation_not_found", err.Error())
+		return
+	}
+	cfg.CacheWriteToID = id
+
+	engine, err := ai.New(append([]ai.Option{ai.WithConfig(cfg), ai.WithStore(s.store)}, s.engineOptions...)...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", "", errorMessage(err))
+		return
+	}
+
+	// the client keeps the conversation, dropping parts of it is up to them
+	if budget, size := engine.InputBudget(), ai.MessagesSize(messages); budget > 0 && size > budget {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", fmt.Sprintf(
+			"The model %s takes at most %d characters of input, the messages have %d characters.", cfg.Model, budget, size))
+		return
+	}
+
+	w.Header().Set(ConversationHeader, id)
+	if req.Stream {
+		s.stream(w, r, engine, req, messages)
+		return
+	}
+
+	out, err := engine.CreateCompletion(ctx, messages)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "api_error", "", errorMessage(err))
+		return
+	}
+	s.save(ctx, engine, messages, out.Explanation, out.Model)
+
+	stop := "stop"
+	writeJSON(w, http.StatusOK, chatResponse{
+		ID:      "chatcmpl-" + convo.NewConversationID(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   out.Model,
+		Choices: []chatChoice{{
+			Message:      &chatMessage{Role: "assistant", Content: messageContent{Text: out.Explanation}},
+			FinishReason: &stop,
+		}},
+		Usage: toUsage(out.Usage),
+	})
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, engine *ai.Engine, req chatRequest, messages []llms.ChatMessage) {
+	ctx := r.Context()
+
+	stream, err := engine.CreateStreamCompletion(ctx, messages)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "api_error", "", errorMessage(err))
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	chunk := chatResponse{
+		ID:      "chatcmpl-" + convo.NewConversationID(),
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   engine.Config.CurrentModel.Name,
+	}
+	send := func(d *delta, finishReason *string, u *usage) {
+		chunk.Choices = []chatChoice{{Delta: d, FinishReason: finishReason}}
+		if d == nil && finishReason == nil {
+			chunk.Choices = []chatChoice{}
+		}
+		chunk.Usage = u
+		writeEvent(w, chunk)
+	}
+
+	send(&delta{Role: "assistant"}, nil, nil)
+	for out := range stream.Outputs() {
+		if out.Last {
+			chunk.Model = ordered.First(out.Model, chunk.Model)
+			continue
+		}
+		if out.Content != "" {
+			send(&delta{Content: out.Content}, nil, nil)
+		}
+	}
+
+	result, err := stream.Wait()
+	if err != nil {
+		if ctx.Err() == nil {
+			writeEvent(w, errorResponse{Error: errorDetail{Message: errorMessage(err), Type: "api_error"}})
+		}
+		return
+	}
+	s.save(ctx, engine, messages, result.Content, result.Model)
+
+	stop := "stop"
+	send(&delta{}, &stop, nil)
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		send(nil, nil, toUsage(result.Usage))
+	}
+	_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	flush(w)
+}
+
+// withKeys returns a copy of the settings with the keys of the APIs resolved.
+// The APIs whose key cannot be resolved are left as they are, the requests to
+// their models fail with the error.
+func withKeys(cfg *options.Config) *options.Config {
+	c := *cfg
+	c.APIs = append(options.APIs(nil), cfg.APIs...)
+	for i, api := range cfg.APIs {
+		resolved, err := cfg.GetAPI(api.Name)
+		if err != nil {
+			klog.Warningf("could not resolve the key of the API %s: %v", api.Name, err)
+			continue
+		}
+		c.APIs[i].APIKey = resolved.APIKey
+	}
+	return &c
+}
+
+// config returns a copy of the settings answering the request.
+func (s *Server) config(req chatRequest) (*options.Config, error) {
+	var cfg *options.Config
+	if req.Model == "" || req.Model == s.cfg.Model {
+		c := *s.cfg
+		cfg = &c
+	} else {
+		var err error
+		if cfg, err = s.cfg.ForModel(req.Model); err != nil {
+			return nil, err
+		}
+	}
+
+	// the request carries the whole conversation
+	cfg.CacheReadFromID = ""
+	if req.Temperature != nil {
+		cfg.Temperature = *req.Temperature
+	}
+	if req.TopP != nil {
+		cfg.TopP = *req.TopP
+	}
+	if maxTokens := ordered.First(req.MaxCompletionTokens, req.MaxTokens); maxTokens > 0 {
+		cfg.MaxTokens = maxTokens
+	}
+	if len(req.Stop) > 0 {
+		cfg.Stop = req.Stop
+	}
+	return cfg, nil
+}
+
+// conversationID returns the ID of the conversation the request continues,
+// a new one if it continues none.
+func (s *Server) conversationID(ctx context.Context, continued string) (string, error) {
+	if continued == "" {
+		return convo.NewConversationID(), nil
+	}
+	found, err := s.store.GetConversation(ctx, continued)
+	if err != nil {
+		return "", fmt.Errorf("could not find the conversation %s: %w", continued, err)
+	}
+	return found.ID, nil
+}
+
+// save replaces the messages of the conversation with the messages of the
+// request and the answer, the client keeps the whole conversation.
+func (s *Server) save(ctx context.Context, engine *ai.Engine, messages []llms.ChatMessage, answer, model string) {
+	cfg := engine.Config
+	if cfg.NoCache {
+		return
+	}
+
+	id := cfg.CacheWriteToID
+	history := append(append([]llms.ChatMessage(nil), messages...), llms.AIChatMessage{Content: answer})
+	if err := s.store.SetMessages(ctx, id, history); err != nil {
+		klog.Warningf("failed to save the conversation %s: %v", id, err)
+		return
+	}
+	if err := s.store.SaveConversation(ctx, id, title(messages, id), ordered.First(model, cfg.Model), engine.Role()); err != nil {
+		klog.Warningf("failed to save the conversation %s: %v", id, err)
+	}
+}
+
+// title returns the first line of the first question of the conversation.
+func title(messages []llms.ChatMessage, id string) string {
+	for _, msg := range messages {
+		if msg.GetType() != llms.ChatMessageTypeHuman {
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(msg.GetContent()), "\n")
+		if line != "" {
+			return line
+		}
+	}
+	return id[:convo.Sha1short]
+}
+
+func toChatMessages(messages []chatMessage) ([]llms.ChatMessage, error) {
+	result := make([]llms.ChatMessage, 0, len(messages))
+	for i, msg := range messages {
+		switch msg.Role {
+		case "system", "developer":
+			result = append(result, llms.SystemChatMessage{Content: msg.Content.Text})
+		case "assistant":
+			result = append(result, llms.AIChatMessage{Content: msg.Content.Text})
+		case "user":
+			if len(msg.Content.Images) == 0 {
+				result = append(result, llms.HumanChatMessage{Content: msg.Content.Text})
+				continue
+			}
+			images := make([]llms.BinaryContent, 0, len(msg.Content.Images))
+			for _, url := range msg.Content.Images {
+				image, err := decodeDataURL(url)
+				if err != nil {
+					return nil, fmt.Errorf("messages[%d]: %w", i, err)
+				}
+				images = append(images, image)
+			}
+			result = append(result, ai.ImageChatMessage{Content: msg.Content.Text, Images: images})
+		default:
+			return nil, fmt.Errorf("messages[%d]: the role %q is not supported", i, msg.Role)
+		}
+	}
+	return result, nil
+}
+
+// decodeDataURL decodes an image sent as a base64 data URL.
+func decodeDataURL(url string) (llms.BinaryContent, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	mimeType, encoding, _ := strings.Cut(header, ";")
+	if !ok || !strings.HasPrefix(url, "data:") || encoding != "base64" || !strings.HasPrefix(mimeType, "image/") {
+		return llms.BinaryContent{}, errors.New("only images sent as base64 data URLs are supported")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return llms.BinaryContent{}, fmt.Errorf("invalid image data: %w", err)
+	}
+	return llms.BinaryContent{MIMEType: mimeType, Data: decoded}, nil
+}
+
+func toUsage(u llms.Usage) *usage {
+	return &usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// errorMessage tells the reason of the errors of the engine with the error.
+func errorMessage(err error) string {
+	var aiErr errbook.AiError
+	if errors.As(err, &aiErr) && aiErr.Reason() != "" {
+		return aiErr.Reason() + " " + err.Error()
+	}
+	return err.Error()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, typ, code, message string) {
+	writeJSON(w, status, errorResponse{Error: errorDetail{Message: message, Type: typ, Code: code}})
+}
+
+func writeEvent(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		klog.Warningf("failed to encode the event: %v", err)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+	flush(w)
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
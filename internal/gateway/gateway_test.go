@@ -0,0 +1,314 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/convo"
+	"github.com/coding-hui/ai-terminal/internal/convo/sqlite3"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// echoModel answers with the text of the last message, streamed word by word.
+type echoModel struct {
+	mu       sync.Mutex
+	requests [][]llms.MessageContent
+}
+
+func (m *echoModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.mu.Lock()
+	m.requests = append(m.requests, messages)
+	m.mu.Unlock()
+
+	var o llms.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var texts []string
+	for _, part := range messages[len(messages)-1].Parts {
+		if text, ok := part.(llms.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	content := "echo: " + strings.Join(texts, " ")
+	if o.StreamingFunc != nil {
+		for _, word := range strings.SplitAfter(content, " ") {
+			if err := o.StreamingFunc(ctx, []byte(word)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{{Content: content}},
+		Usage:   llms.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+	}, nil
+}
+
+func (m *echoModel) last() []llms.MessageContent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[len(m.requests)-1]
+}
+
+func newTestServer(t *testing.T, opts ...Option) (*httptest.Server, convo.Store, *echoModel) {
+	t.Helper()
+
+	cfg := &options.Config{
+		Model: "default",
+		API:   "fake",
+		APIs:  options.APIs{{Name: "fake", APIKey: "key"}, {Name: "other", APIKey: "key"}},
+		Models: map[string]options.Model{
+			"default": {Name: "default", API: "fake", Vision: true},
+			"big":     {Name: "big-model", API: "other"},
+			"small":   {Name: "small", API: "other", MaxChars: 10},
+		},
+	}
+	store := sqlite3.NewSqliteStore(sqlite3.WithDataPath(t.TempDir()))
+	model := &echoModel{}
+
+	server := httptest.NewServer(New(cfg, store, append([]Option{WithEngineOptions(ai.WithModel(model))}, opts...)...).Handler())
+	t.Cleanup(server.Close)
+	return server, store, model
+}
+
+func post(t *testing.T, server *httptest.Server, body string, header ...string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = rsp.Body.Close() })
+	return rsp
+}
+
+func decodeError(t *testing.T, rsp *http.Response) errorDetail {
+	t.Helper()
+	var body errorResponse
+	require.NoError(t, json.NewDecoder(rsp.Body).Decode(&body))
+	return body.Error
+}
+
+func TestChatCompletions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("answers and saves the conversation", func(t *testing.T) {
+		server, store, model := newTestServer(t)
+
+		rsp := post(t, server, `{"model":"default","messages":[
+			{"role":"system","content":"be brief"},
+			{"role":"user","content":"what is up\nsecond line"}]}`)
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+		var body chatResponse
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(&body))
+		assert.Equal(t, "chat.completion", body.Object)
+		assert.Equal(t, "default", body.Model)
+		require.Len(t, body.Choices, 1)
+		assert.Equal(t, "assistant", body.Choices[0].Message.Role)
+		assert.Equal(t, "echo: what is up\nsecond line", body.Choices[0].Message.Content.Text)
+		assert.Equal(t, "stop", *body.Choices[0].FinishReason)
+		assert.Equal(t, &usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}, body.Usage)
+
+		assert.Len(t, model.last(), 2)
+
+		id := rsp.Header.Get(ConversationHeader)
+		require.True(t, convo.MatchSha1(id))
+		found, err := store.GetConversation(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "what is up", found.Title)
+		messages, err := store.Messages(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+		assert.Equal(t, "echo: what is up\nsecond line", messages[2].GetContent())
+	})
+
+	t.Run("streams the answer", func(t *testing.T) {
+		server, store, _ := newTestServer(t)
+
+		rsp := post(t, server, `{"model":"big","stream":true,"stream_options":{"include_usage":true},
+			"messages":[{"role":"user","content":[{"type":"text","text":"hello there"}]}]}`)
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+
+		var (
+			content string
+			chunks  []chatResponse
+			done    bool
+		)
+		scanner := bufio.NewScanner(rsp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			if data == "[DONE]" {
+				done = true
+				break
+			}
+			var chunk chatResponse
+			require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+			chunks = append(chunks, chunk)
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+				content += chunk.Choices[0].Delta.Content
+			}
+		}
+		require.True(t, done)
+		assert.Equal(t, "echo: hello there", content)
+		assert.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+
+		finish := chunks[len(chunks)-2]
+		assert.Equal(t, "stop", *finish.Choices[0].FinishReason)
+		assert.Equal(t, "big-model", finish.Model)
+		usageChunk := chunks[len(chunks)-1]
+		assert.Empty(t, usageChunk.Choices)
+		assert.Equal(t, 5, usageChunk.Usage.TotalTokens)
+
+		messages, err := store.Messages(ctx, rsp.Header.Get(ConversationHeader))
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "echo: hello there", messages[1].GetContent())
+	})
+
+	t.Run("continues a conversation", func(t *testing.T) {
+		server, store, _ := newTestServer(t)
+
+		first := post(t, server, `{"messages":[{"role":"user","content":"one"}]}`)
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		id := first.Header.Get(ConversationHeader)
+
+		second := post(t, server, `{"messages":[
+			{"role":"user","content":"one"},
+			{"role":"assistant","content":"echo: one"},
+			{"role":"user","content":"two"}]}`, ConversationHeader, id[:convo.Sha1short])
+		require.Equal(t, http.StatusOK, second.StatusCode)
+		assert.Equal(t, id, second.Header.Get(ConversationHeader))
+
+		messages, err := store.Messages(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, messages, 4)
+		assert.Equal(t, "echo: two", messages[3].GetContent())
+		found, err := store.GetConversation(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "one", found.Title)
+
+		missing := post(t, server, `{"messages":[{"role":"user","content":"one"}]}`, ConversationHeader, "no such title")
+		assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+		assert.Equal(t, "conversation_not_found", decodeError(t, missing).Code)
+	})
+
+	t.Run("sends images", func(t *testing.T) {
+		server, _, model := newTestServer(t)
+
+		rsp := post(t, server, `{"messages":[{"role":"user","content":[
+			{"type":"text","text":"what is it"},
+			{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}]}`)
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+		parts := model.last()[0].Parts
+		require.Len(t, parts, 2)
+		image, ok := parts[1].(llms.ImageURLContent)
+		require.True(t, ok)
+		assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", image.URL)
+
+		rsp = post(t, server, `{"messages":[{"role":"user","content":[
+			{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}]}`)
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		server, _, _ := newTestServer(t)
+
+		for name, body := range map[string]string{
+			"no messages":  `{"messages":[]}`,
+			"tools":        `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function"}]}`,
+			"unknown role": `{"messages":[{"role":"tool","content":"hi"}]}`,
+			"invalid json": `{"messages":`,
+		} {
+			rsp := post(t, server, body)
+			assert.Equal(t, http.StatusBadRequest, rsp.StatusCode, name)
+			assert.Equal(t, "invalid_request_error", decodeError(t, rsp).Type, name)
+		}
+
+		rsp := post(t, server, `{"model":"missing","messages":[{"role":"user","content":"hi"}]}`)
+		assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+		assert.Equal(t, "model_not_found", decodeError(t, rsp).Code)
+	})
+
+	t.Run("rejects messages over the input limit", func(t *testing.T) {
+		server, _, model := newTestServer(t)
+
+		for _, stream := range []string{"false", "true"} {
+			rsp := post(t, server, `{"model":"small","stream":`+stream+`,"messages":[{"role":"user","content":"more than ten characters"}]}`)
+			assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+			assert.Equal(t, "context_length_exceeded", decodeError(t, rsp).Code)
+		}
+		assert.Empty(t, model.requests)
+
+		rsp := post(t, server, `{"model":"small","messages":[{"role":"user","content":"short"}]}`)
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	})
+
+	t.Run("runs the key commands once", func(t *testing.T) {
+		runs := filepath.Join(t.TempDir(), "runs")
+		cfg := &options.Config{
+			Model:  "default",
+			API:    "fake",
+			APIs:   options.APIs{{Name: "fake", APIKeyCmd: fmt.Sprintf(`sh -c "echo run >> %s; echo key"`, runs)}},
+			Models: map[string]options.Model{"default": {Name: "default", API: "fake"}},
+		}
+		store := sqlite3.NewSqliteStore(sqlite3.WithDataPath(t.TempDir()))
+		server := httptest.NewServer(New(cfg, store, WithEngineOptions(ai.WithModel(&echoModel{}))).Handler())
+		defer server.Close()
+
+		for range 3 {
+			rsp := post(t, server, `{"messages":[{"role":"user","content":"hi"}]}`)
+			assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		}
+		content, err := os.ReadFile(runs)
+		require.NoError(t, err)
+		assert.Equal(t, "run\n", string(content))
+		assert.Empty(t, cfg.APIs[0].APIKey)
+	})
+
+	t.Run("requires the key", func(t *testing.T) {
+		server, _, _ := newTestServer(t, WithKey("secret"))
+
+		rsp := post(t, server, `{"messages":[{"role":"user","content":"hi"}]}`)
+		assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+		assert.Equal(t, "invalid_api_key", decodeError(t, rsp).Code)
+
+		rsp = post(t, server, `{"messages":[{"role":"user","content":"hi"}]}`, "Authorization", "Bearer secret")
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	})
+}
+
+func TestModels(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	rsp, err := http.Get(server.URL + "/v1/models")
+	require.NoError(t, err)
+	defer rsp.Body.Close() //nolint:errcheck
+
+	var list modelList
+	require.NoError(t, json.NewDecoder(rsp.Body).Decode(&list))
+	require.Len(t, list.Data, 3)
+	assert.Equal(t, modelInfo{ID: "big", Object: "model", OwnedBy: "other"}, list.Data[0])
+}
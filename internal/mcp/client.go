@@ -0,0 +1,333 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coding-hui/common/version"
+	"k8s.io/klog/v2"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+const (
+	// initializeTimeout is how long a server has to answer the handshake.
+	initializeTimeout = 30 * time.Second
+	// closeTimeout is how long a server has to exit once its input is
+	// closed before it is killed.
+	closeTimeout = 2 * time.Second
+	// stderrLimit is how much of the standard error of a server is kept to
+	// explain its failures.
+	stderrLimit = 4 * 1024
+)
+
+// Client is a connection to an MCP server run as a child process, talking
+// over its standard input and output.
+type Client struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+	server Implementation
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan *message
+
+	// done is closed once the server stopped answering, err tells why.
+	done chan struct{}
+	err  error
+}
+
+// Start runs the server and initializes the connection.
+func Start(ctx context.Context, name string, server options.MCPServer) (*Client, error) {
+	if server.Command == "" {
+		return nil, fmt.Errorf("the MCP server %s has no command", name)
+	}
+
+	cmd := exec.Command(server.Command, server.Args...) //nolint:gosec
+	cmd.Env = os.Environ()
+	for key, value := range server.Env {
+		cmd.Env = append(cmd.Env, key+"="+os.ExpandEnv(value))
+	}
+	stderr := &tailBuffer{limit: stderrLimit}
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run the MCP server %s: %w", name, err)
+	}
+
+	c := &Client{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  stderr,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.read(stdout)
+
+	if err := c.initialize(ctx); err != nil {
+		// the standard error is complete once the server exited
+		_ = c.Close()
+		return nil, fmt.Errorf("failed to initialize the MCP server %s: %w", name, c.failure(err))
+	}
+	return c, nil
+}
+
+// Name returns the name of the server in the settings.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// ServerInfo returns the name and the version the server told.
+func (c *Client) ServerInfo() Implementation {
+	return c.server
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, initializeTimeout)
+	defer cancel()
+
+	var result initializeResult
+	err := c.call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: "ai-terminal", Version: version.Get().GitVersion},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	c.server = result.ServerInfo
+
+	return c.notify("notifications/initialized", nil)
+}
+
+// ListTools returns the tools of the server.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	params := listToolsParams{}
+	for {
+		var result listToolsResult
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, c.exited(err)
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		params.Cursor = result.NextCursor
+	}
+}
+
+// CallTool calls the tool of the server with the JSON encoded arguments.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, c.exited(err)
+	}
+	return &result, nil
+}
+
+// Close closes the input of the server and waits for it to exit, killing
+// it when it does not.
+func (c *Client) Close() error {
+	_ = c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(closeTimeout):
+		_ = c.cmd.Process.Kill()
+		<-c.done
+	}
+	// the server was asked to exit, its exit status does not matter
+	_ = c.cmd.Wait()
+	return nil
+}
+
+// call sends the request and decodes its result into result.
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	id := c.nextID.Add(1)
+	answer := make(chan *message, 1)
+	c.mu.Lock()
+	c.pending[id] = answer
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := c.write(&message{JSONRPC: "2.0", ID: json.RawMessage(strconv.FormatInt(id, 10)), Method: method, Params: raw}); err != nil {
+		return err
+	}
+
+	var msg *message
+	select {
+	case msg = <-answer:
+	case <-ctx.Done():
+		// the server may stop working on the request
+		_ = c.notify("notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	case <-c.done:
+		// the answer may be the last words of the server
+		select {
+		case msg = <-answer:
+		default:
+			return c.err
+		}
+	}
+
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result == nil || len(msg.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, result)
+}
+
+func (c *Client) notify(method string, params any) error {
+	msg := &message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = raw
+	}
+	return c.write(msg)
+}
+
+func (c *Client) write(msg *message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// read dispatches the answers of the server until its output is closed.
+func (c *Client) read(stdout io.Reader) {
+	defer close(c.done)
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.handle(line)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("the server exited")
+			}
+			c.err = err
+			return
+		}
+	}
+}
+
+func (c *Client) handle(line []byte) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		klog.V(2).Infof("mcp %s: skipping the output %q: %v", c.name, line, err)
+		return
+	}
+
+	switch {
+	case msg.isRequest():
+		// the client offers no capabilities, servers may only check it is alive
+		reply := &message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage("{}")}
+		if msg.Method != "ping" {
+			reply = &message{JSONRPC: "2.0", ID: msg.ID, Error: &RPCError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}}
+		}
+		if err := c.write(reply); err != nil {
+			klog.V(2).Infof("mcp %s: failed to answer %s: %v", c.name, msg.Method, err)
+		}
+	case msg.isNotification():
+		klog.V(3).Infof("mcp %s: notification %s", c.name, msg.Method)
+	default:
+		id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+		if err != nil {
+			klog.V(2).Infof("mcp %s: skipping the answer with the id %s", c.name, msg.ID)
+			return
+		}
+		c.mu.Lock()
+		answer, ok := c.pending[id]
+		c.mu.Unlock()
+		if ok {
+			answer <- &msg
+		}
+	}
+}
+
+// exited adds what the server wrote to its standard error to the error of
+// a request when the server exited.
+func (c *Client) exited(err error) error {
+	select {
+	case <-c.done:
+		return c.failure(err)
+	default:
+		return err
+	}
+}
+
+// failure adds what the server wrote to its standard error to err.
+func (c *Client) failure(err error) error {
+	if tail := strings.TrimSpace(c.stderr.String()); tail != "" {
+		return fmt.Errorf("%w: %s", err, tail)
+	}
+	return err
+}
+
+// tailBuffer keeps the last bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,230 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// fixtureEnv makes the test binary serve as the MCP server fixture.
+const fixtureEnv = "AI_TERMINAL_MCP_FIXTURE"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(fixtureEnv) {
+	case "serve":
+		serveFixture()
+		os.Exit(0)
+	case "crash":
+		_, _ = fmt.Fprintln(os.Stderr, "boom: missing the token")
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// serveFixture is a tiny MCP server with an echo tool, a failing tool and a
+// secret tool, listed over two pages.
+func serveFixture() {
+	out := json.NewEncoder(os.Stdout)
+	send := func(msg message) {
+		msg.JSONRPC = "2.0"
+		_ = out.Encode(msg)
+	}
+	result := func(id json.RawMessage, v any) {
+		raw, _ := json.Marshal(v)
+		send(message{ID: id, Result: raw})
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		switch msg.Method {
+		case "initialize":
+			result(msg.ID, initializeResult{
+				ProtocolVersion: ProtocolVersion,
+				Capabilities:    map[string]any{"tools": map[string]any{}},
+				ServerInfo:      Implementation{Name: "fixture", Version: "1.0.0"},
+			})
+		case "tools/list":
+			// the client has to cope with notifications and requests of the server
+			send(message{Method: "notifications/message", Params: json.RawMessage(`{"level":"info","data":"listing"}`)})
+			send(message{ID: json.RawMessage(`"ping-1"`), Method: "ping"})
+
+			var params listToolsParams
+			_ = json.Unmarshal(msg.Params, &params)
+			if params.Cursor == "" {
+				result(msg.ID, listToolsResult{
+					Tools: []Tool{{
+						Name:        "echo",
+						Description: "Echo the text.",
+						InputSchema: map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}},
+					}},
+					NextCursor: "2",
+				})
+				continue
+			}
+			result(msg.ID, listToolsResult{Tools: []Tool{{Name: "fail"}, {Name: "secret"}}})
+		case "tools/call":
+			var params struct {
+				Name      string `json:"name"`
+				Arguments struct {
+					Text string `json:"text"`
+				} `json:"arguments"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
+			switch params.Name {
+			case "echo":
+				result(msg.ID, CallToolResult{Content: []Content{TextContent(params.Arguments.Text), {Type: "image", MIMEType: "image/png", Data: "AQ=="}}})
+			case "fail":
+				result(msg.ID, CallToolResult{Content: []Content{TextContent("the ticket does not exist")}, IsError: true})
+			default:
+				send(message{ID: msg.ID, Error: &RPCError{Code: -32602, Message: "unknown tool " + params.Name}})
+			}
+		}
+	}
+}
+
+func fixture(mode string) options.MCPServer {
+	return options.MCPServer{
+		Command: os.Args[0],
+		Env:     map[string]string{fixtureEnv: mode},
+	}
+}
+
+func TestClient(t *testing.T) {
+	client, err := Start(context.Background(), "fx", fixture("serve"))
+	require.NoError(t, err)
+	defer client.Close() //nolint:errcheck
+
+	assert.Equal(t, Implementation{Name: "fixture", Version: "1.0.0"}, client.ServerInfo())
+
+	t.Run("list tools", func(t *testing.T) {
+		defs, err := client.ListTools(context.Background())
+		require.NoError(t, err)
+		names := make([]string, 0, len(defs))
+		for _, def := range defs {
+			names = append(names, def.Name)
+		}
+		assert.Equal(t, []string{"echo", "fail", "secret"}, names)
+	})
+
+	t.Run("call tool", func(t *testing.T) {
+		result, err := client.CallTool(context.Background(), "echo", json.RawMessage(`{"text":"hello"}`))
+		require.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Equal(t, "hello\n[image image/png]", result.Text())
+	})
+
+	t.Run("error answer", func(t *testing.T) {
+		_, err := client.CallTool(context.Background(), "missing", nil)
+		var rpcErr *RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		assert.Equal(t, "unknown tool missing", rpcErr.Message)
+	})
+
+	t.Run("server failing to start", func(t *testing.T) {
+		_, err := Start(context.Background(), "broken", fixture("crash"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "broken")
+		assert.Contains(t, err.Error(), "boom: missing the token")
+	})
+}
+
+func TestStartServers(t *testing.T) {
+	var asked []string
+	confirm := func(format string, args ...any) bool {
+		asked = append(asked, fmt.Sprintf(format, args...))
+		return len(asked) > 1
+	}
+
+	server := fixture("serve")
+	server.Tools = map[string]string{"fail": options.MCPConfirmAllow, "secret": options.MCPConfirmDeny}
+	servers, err := StartServers(context.Background(), options.MCPServers{
+		"tickets": server,
+		"broken":  fixture("crash"),
+	}, confirm)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	defer servers.Close()
+
+	registry := tools.NewRegistry()
+	servers.Register(registry)
+	assert.Equal(t, []string{"tickets__echo", "tickets__fail"}, registry.Names())
+
+	defs := registry.Definitions()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Function.Name < defs[j].Function.Name })
+	assert.Equal(t, "Echo the text.", defs[0].Function.Description)
+	assert.Equal(t, map[string]any{"type": "object"}, defs[1].Function.Parameters)
+
+	execute := func(name, arguments string) string {
+		msg := registry.Execute(context.Background(), llms.ToolCall{
+			ID:           "call-1",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: name, Arguments: arguments},
+		})
+		return msg.Parts[0].(llms.ToolCallResponse).Content
+	}
+
+	t.Run("asked tool", func(t *testing.T) {
+		assert.Equal(t, "error: the user declined to call the tool", execute("tickets__echo", `{"text":"hi"}`))
+		assert.Equal(t, "hi\n[image image/png]", execute("tickets__echo", `{"text":"hi"}`))
+		require.Len(t, asked, 2)
+		assert.Equal(t, `Allow the model to call echo of the MCP server tickets with {"text":"hi"}?`, asked[0])
+	})
+
+	t.Run("allowed tool", func(t *testing.T) {
+		assert.Equal(t, "error: the ticket does not exist", execute("tickets__fail", "{}"))
+		assert.Len(t, asked, 2)
+	})
+
+	t.Run("colliding names", func(t *testing.T) {
+		servers, err := StartServers(context.Background(), options.MCPServers{
+			"t.x": fixture("serve"),
+			"t_x": fixture("serve"),
+		}, confirm)
+		require.NoError(t, err)
+		defer servers.Close()
+
+		registry := tools.NewRegistry()
+		servers.Register(registry)
+		assert.Equal(t, []string{
+			"t_x__echo", "t_x__echo_2", "t_x__fail", "t_x__fail_2", "t_x__secret", "t_x__secret_2",
+		}, registry.Names())
+
+		// the tools of the registry are kept
+		registry = tools.NewRegistry(servers.Tools()[0])
+		servers.Register(registry)
+		assert.Len(t, registry.Names(), 6)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		server := fixture("serve")
+		server.Confirm = "sometimes"
+		_, err := StartServers(context.Background(), options.MCPServers{"tickets": server}, confirm)
+		assert.ErrorContains(t, err, `invalid confirm policy "sometimes"`)
+	})
+}
+
+func TestUniqueName(t *testing.T) {
+	long := strings.Repeat("a", maxToolName)
+	taken := map[string]bool{"echo": true, "echo_2": true, long: true}
+
+	assert.Equal(t, "free", uniqueName("free", taken))
+	assert.Equal(t, "echo_3", uniqueName("echo", taken))
+	assert.Equal(t, long[:maxToolName-2]+"_2", uniqueName(long, taken))
+}
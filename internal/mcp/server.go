@@ -0,0 +1,237 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// ToolHandler answers a call to a tool with the JSON encoded arguments. An
+// error is returned to the client as the result of the tool, for the model
+// to see it.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Server serves tools to an MCP client over a stream, usually the standard
+// input and output.
+type Server struct {
+	info     Implementation
+	tools    map[string]Tool
+	handlers map[string]ToolHandler
+
+	writeMu sync.Mutex
+	out     io.Writer
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+// NewServer returns a server telling the clients the given name and version.
+func NewServer(name, version string) *Server {
+	return &Server{
+		info:     Implementation{Name: name, Version: version},
+		tools:    make(map[string]Tool),
+		handlers: make(map[string]ToolHandler),
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+// AddTool adds a tool to the server, replacing any tool with the same name.
+func (s *Server) AddTool(tool Tool, handler ToolHandler) {
+	if tool.InputSchema == nil {
+		tool.InputSchema = map[string]any{"type": "object"}
+	}
+	s.tools[tool.Name] = tool
+	s.handlers[tool.Name] = handler
+}
+
+// Serve answers the requests read from in until it is closed or the context
+// is done. The calls to the tools run concurrently, Serve waits for them.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = out
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-stop:
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					errc <- err
+				}
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.cancelAll()
+			return nil
+		case err := <-errc:
+			s.cancelAll()
+			return err
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			s.handle(ctx, line, &wg)
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, line []byte, wg *sync.WaitGroup) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		s.reply(json.RawMessage("null"), nil, &RPCError{Code: codeParseError, Message: err.Error()})
+		return
+	}
+
+	if msg.isNotification() {
+		if msg.Method == "notifications/cancelled" {
+			var params struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				s.cancel(string(params.RequestID))
+			}
+		}
+		return
+	}
+	if !msg.isRequest() {
+		// the server sends no requests, answers are not expected
+		return
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		_ = json.Unmarshal(msg.Params, &params)
+		klog.V(2).Infof("mcp: initialized by %s %s", params.ClientInfo.Name, params.ClientInfo.Version)
+		s.reply(msg.ID, initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.info,
+		}, nil)
+	case "ping":
+		s.reply(msg.ID, struct{}{}, nil)
+	case "tools/list":
+		names := make([]string, 0, len(s.tools))
+		for name := range s.tools {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result := listToolsResult{Tools: make([]Tool, 0, len(names))}
+		for _, name := range names {
+			result.Tools = append(result.Tools, s.tools[name])
+		}
+		s.reply(msg.ID, result, nil)
+	case "tools/call":
+		var params callToolParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			s.reply(msg.ID, nil, &RPCError{Code: codeInvalidParams, Message: err.Error()})
+			return
+		}
+		handler, ok := s.handlers[params.Name]
+		if !ok {
+			s.reply(msg.ID, nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + params.Name})
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.inflight[string(msg.ID)] = cancel
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.cancel(string(msg.ID))
+			s.reply(msg.ID, s.call(ctx, handler, params), nil)
+		}()
+	default:
+		s.reply(msg.ID, nil, &RPCError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+	}
+}
+
+func (s *Server) call(ctx context.Context, handler ToolHandler, params callToolParams) (result *CallToolResult) {
+	// a failing tool does not take the server down
+	defer func() {
+		if r := recover(); r != nil {
+			result = &CallToolResult{Content: []Content{TextContent(fmt.Sprintf("the tool %s failed: %v", params.Name, r))}, IsError: true}
+		}
+	}()
+
+	arguments := params.Arguments
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	text, err := handler(ctx, arguments)
+	if err != nil {
+		return &CallToolResult{Content: []Content{TextContent(err.Error())}, IsError: true}
+	}
+	return &CallToolResult{Content: []Content{TextContent(text)}}
+}
+
+func (s *Server) cancel(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.inflight[id]; ok {
+		cancel()
+		delete(s.inflight, id)
+	}
+}
+
+func (s *Server) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, cancel := range s.inflight {
+		cancel()
+		delete(s.inflight, id)
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *RPCError) {
+	msg := &message{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &RPCError{Code: codeInternalError, Message: err.Error()}
+		} else {
+			msg.Result = raw
+		}
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		klog.Warningf("mcp: failed to encode the answer: %v", err)
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.out.Write(append(line, '\n')); err != nil {
+		klog.Warningf("mcp: failed to write the answer: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serverConn talks to a server served in the test.
+type serverConn struct {
+	in   *io.PipeWriter
+	out  *bufio.Scanner
+	done chan error
+}
+
+func serve(t *testing.T, server *Server) *serverConn {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	conn := &serverConn{in: inWriter, out: bufio.NewScanner(outReader), done: make(chan error, 1)}
+	go func() {
+		conn.done <- server.Serve(context.Background(), inReader, outWriter)
+		_ = outWriter.Close()
+	}()
+	t.Cleanup(func() { _ = inWriter.Close() })
+	return conn
+}
+
+func (c *serverConn) send(t *testing.T, line string) {
+	_, err := io.WriteString(c.in, line+"\n")
+	require.NoError(t, err)
+}
+
+func (c *serverConn) receive(t *testing.T) message {
+	require.True(t, c.out.Scan(), "the server closed its output")
+	var msg message
+	require.NoError(t, json.Unmarshal(c.out.Bytes(), &msg))
+	return msg
+}
+
+func TestServer(t *testing.T) {
+	started := make(chan struct{})
+	server := NewServer("ai-terminal", "v1.0.0")
+	server.AddTool(Tool{
+		Name:        "echo",
+		Description: "Echo the text.",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}},
+	}, func(_ context.Context, arguments json.RawMessage) (string, error) {
+		var args struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", err
+		}
+		return args.Text, nil
+	})
+	server.AddTool(Tool{Name: "fail"}, func(context.Context, json.RawMessage) (string, error) {
+		return "", errors.New("not a git repository")
+	})
+	server.AddTool(Tool{Name: "wait"}, func(ctx context.Context, _ json.RawMessage) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	conn := serve(t, server)
+
+	t.Run("initialize", func(t *testing.T) {
+		conn.send(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+		msg := conn.receive(t)
+		assert.JSONEq(t, "1", string(msg.ID))
+		var result initializeResult
+		require.NoError(t, json.Unmarshal(msg.Result, &result))
+		assert.Equal(t, ProtocolVersion, result.ProtocolVersion)
+		assert.Equal(t, Implementation{Name: "ai-terminal", Version: "v1.0.0"}, result.ServerInfo)
+		assert.Contains(t, result.Capabilities, "tools")
+
+		conn.send(t, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+		conn.send(t, `{"jsonrpc":"2.0","id":"p","method":"ping"}`)
+		msg = conn.receive(t)
+		assert.JSONEq(t, `"p"`, string(msg.ID))
+		assert.JSONEq(t, "{}", string(msg.Result))
+	})
+
+	t.Run("list tools", func(t *testing.T) {
+		conn.send(t, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		var result listToolsResult
+		require.NoError(t, json.Unmarshal(conn.receive(t).Result, &result))
+		require.Len(t, result.Tools, 3)
+		assert.Equal(t, "echo", result.Tools[0].Name)
+		assert.Equal(t, "Echo the text.", result.Tools[0].Description)
+		assert.Equal(t, map[string]any{"type": "object"}, result.Tools[1].InputSchema)
+	})
+
+	t.Run("call tool", func(t *testing.T) {
+		conn.send(t, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hello"}}}`)
+		var result CallToolResult
+		require.NoError(t, json.Unmarshal(conn.receive(t).Result, &result))
+		assert.False(t, result.IsError)
+		assert.Equal(t, "hello", result.Text())
+	})
+
+	t.Run("failing tool", func(t *testing.T) {
+		conn.send(t, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"fail"}}`)
+		var result CallToolResult
+		require.NoError(t, json.Unmarshal(conn.receive(t).Result, &result))
+		assert.True(t, result.IsError)
+		assert.Equal(t, "not a git repository", result.Text())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		conn.send(t, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`)
+		msg := conn.receive(t)
+		require.NotNil(t, msg.Error)
+		assert.Equal(t, codeInvalidParams, msg.Error.Code)
+
+		conn.send(t, `{"jsonrpc":"2.0","id":6,"method":"resources/list"}`)
+		msg = conn.receive(t)
+		require.NotNil(t, msg.Error)
+		assert.Equal(t, codeMethodNotFound, msg.Error.Code)
+
+		conn.send(t, `{not json`)
+		msg = conn.receive(t)
+		require.NotNil(t, msg.Error)
+		assert.Equal(t, codeParseError, msg.Error.Code)
+	})
+
+	t.Run("cancelled call", func(t *testing.T) {
+		conn.send(t, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"wait"}}`)
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the tool was not called")
+		}
+		conn.send(t, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)
+		msg := conn.receive(t)
+		assert.JSONEq(t, "7", string(msg.ID))
+		var result CallToolResult
+		require.NoError(t, json.Unmarshal(msg.Result, &result))
+		assert.True(t, result.IsError)
+		assert.Equal(t, context.Canceled.Error(), result.Text())
+	})
+
+	t.Run("closed input", func(t *testing.T) {
+		require.NoError(t, conn.in.Close())
+		select {
+		case err := <-conn.done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the server did not stop")
+		}
+	})
+}
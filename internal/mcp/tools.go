@@ -0,0 +1,216 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
+	"github.com/coding-hui/ai-terminal/internal/options"
+	"github.com/coding-hui/ai-terminal/internal/ui/console"
+)
+
+// maxToolName is the longest tool name the model APIs take.
+const maxToolName = 64
+
+var invalidToolName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Servers are the running MCP servers of the settings.
+type Servers struct {
+	clients []*Client
+	tools   []tools.Tool
+}
+
+// StartServers runs the servers and lists their tools. The servers failing
+// to start are left out, the error tells about them.
+func StartServers(ctx context.Context, servers options.MCPServers, confirm tools.ConfirmFunc) (*Servers, error) {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type started struct {
+		client *Client
+		tools  []*tool
+		err    error
+	}
+	results := make([]started, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, serverTools, err := startServer(ctx, name, servers[name], confirm)
+			results[i] = started{client: client, tools: serverTools, err: err}
+		}()
+	}
+	wg.Wait()
+
+	s := &Servers{}
+	var errs []error
+	taken := make(map[string]bool)
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+			continue
+		}
+		s.clients = append(s.clients, result.client)
+		for _, t := range result.tools {
+			t.name = uniqueName(t.fullName(), taken)
+			if t.name != t.fullName() {
+				console.WarnStderr(fmt.Sprintf("The tool %s of the MCP server %s is offered as %s, its name is taken.", t.def.Name, t.client.Name(), t.name))
+			}
+			taken[t.name] = true
+			s.tools = append(s.tools, t)
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// uniqueName numbers name until it is not taken, keeping it to the length
+// the model APIs take.
+func uniqueName(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		unique = name[:min(len(name), maxToolName-len(suffix))] + suffix
+	}
+	return unique
+}
+
+func startServer(ctx context.Context, name string, server options.MCPServer, confirm tools.ConfirmFunc) (*Client, []*tool, error) {
+	for tool, policy := range server.Tools {
+		if !validPolicy(policy) {
+			return nil, nil, fmt.Errorf("invalid confirm policy %q of the tool %s of the MCP server %s, use ask, allow or deny", policy, tool, name)
+		}
+	}
+	if server.Confirm != "" && !validPolicy(server.Confirm) {
+		return nil, nil, fmt.Errorf("invalid confirm policy %q of the MCP server %s, use ask, allow or deny", server.Confirm, name)
+	}
+
+	client, err := Start(ctx, name, server)
+	if err != nil {
+		return nil, nil, err
+	}
+	defs, err := client.ListTools(ctx)
+	if err != nil {
+		_ = client.Close()
+		return nil, nil, fmt.Errorf("failed to list the tools of the MCP server %s: %w", name, err)
+	}
+
+	var serverTools []*tool
+	for _, def := range defs {
+		policy := server.Policy(def.Name)
+		// denied tools are not offered at all
+		if policy == options.MCPConfirmDeny {
+			continue
+		}
+		serverTools = append(serverTools, &tool{client: client, def: def, policy: policy, confirm: confirm})
+	}
+	return client, serverTools, nil
+}
+
+func validPolicy(policy string) bool {
+	switch policy {
+	case options.MCPConfirmAsk, options.MCPConfirmAllow, options.MCPConfirmDeny:
+		return true
+	}
+	return false
+}
+
+// Tools returns the tools of the servers.
+func (s *Servers) Tools() []tools.Tool {
+	return s.tools
+}
+
+// Register adds the tools of the servers to the registry, the tools whose
+// name is taken by a tool of the registry are left out.
+func (s *Servers) Register(registry *tools.Registry) {
+	for _, t := range s.tools {
+		if _, ok := registry.Get(t.Name()); ok {
+			console.WarnStderr(fmt.Sprintf("The tool %s is not offered, its name is taken.", t.Name()))
+			continue
+		}
+		registry.Register(t)
+	}
+}
+
+// Close stops the servers.
+func (s *Servers) Close() {
+	var wg sync.WaitGroup
+	for _, client := range s.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = client.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+// tool is a tool of an MCP server offered to the model, named after the
+// server and the tool.
+type tool struct {
+	client  *Client
+	def     Tool
+	name    string
+	policy  string
+	confirm tools.ConfirmFunc
+}
+
+var _ tools.Tool = (*tool)(nil)
+
+func (t *tool) Name() string {
+	if t.name != "" {
+		return t.name
+	}
+	return t.fullName()
+}
+
+// fullName is the name of the tool before it was made unique.
+func (t *tool) fullName() string {
+	name := invalidToolName.ReplaceAllString(t.client.Name()+"__"+t.def.Name, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+func (t *tool) Description() string {
+	return t.def.Description
+}
+
+func (t *tool) Parameters() map[string]any {
+	if t.def.InputSchema == nil {
+		return map[string]any{"type": "object"}
+	}
+	return t.def.InputSchema
+}
+
+func (t *tool) Call(ctx context.Context, arguments string) (string, error) {
+	if arguments == "" {
+		arguments = "{}"
+	}
+	if !json.Valid([]byte(arguments)) {
+		return "", fmt.Errorf("invalid arguments: %s", arguments)
+	}
+	if t.policy != options.MCPConfirmAllow {
+		if !tools.Confirm(ctx, t.confirm, "Allow the model to call %s of the MCP server %s with %s?", t.def.Name, t.client.Name(), arguments) {
+			return "", fmt.Errorf("the user declined to call the tool")
+		}
+	}
+
+	result, err := t.client.CallTool(ctx, t.def.Name, json.RawMessage(arguments))
+	if err != nil {
+		return "", err
+	}
+	if result.IsError {
+		return "", errors.New(result.Text())
+	}
+	return result.Text(), nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/caarlos0/duration"
 	"github.com/caarlos0/env/v9"
 	"github.com/caarlos0/go-shellwords"
+	"github.com/charmbracelet/x/exp/ordered"
 	str "github.com/charmbracelet/x/exp/strings"
 	"gopkg.in/yaml.v3"
 
@@ -39,14 +41,17 @@ var Help = map[string]string{
 	"api":                 "OpenAI compatible REST API (openai, localai, deepseek).",
 	"apis":                "Aliases and endpoints for OpenAI compatible REST API.",
 	"http-proxy":          "HTTP proxy to use for API requests.",
+	"ca-cert":             "PEM bundle of the certificate authorities trusted besides the system ones.",
+	"client-cert":         "PEM client certificate presented to the servers, the key may be in the same file.",
+	"client-key":          "PEM key of the client certificate.",
 	"model":               "Default model (gpt-3.5-turbo, gpt-4, ggml-gpt4all-j...).",
 	"ask-model":           "Ask which model to use with an interactive prompt.",
 	"max-input-chars":     "Default character limit on input to model.",
 	"format":              "Ask for the response to be formatted as markdown unless otherwise set.",
 	"format-text":         "Text to append when using the -f flag.",
+	"format-as":           "Format of the answer (markdown, json), json answers are validated.",
 	"role":                "System role to use.",
-	"roles":               "List of predefined system messages that can be used as roles.",
-	"list-roles":          "List the roles defined in your configuration file",
+	"roles":               "List of predefined system messages that can be used as roles, a message may be a file:// path or an http(s) URL to read it from.",
 	"prompt":              "Include the prompt from the arguments and stdin, truncate stdin to specified number of lines.",
 	"prompt-args":         "Include the prompt from the arguments in the response.",
 	"raw":                 "Render output as raw text when connected to a TTY.",
@@ -55,9 +60,10 @@ var Help = map[string]string{
 	"version":             "Show version and exit.",
 	"max-retries":         "Maximum number of times to retry API calls.",
 	"no-limit":            "Turn off the client-side limit on the size of the input into the model.",
+	"compact-threshold":   "Summarize the older turns of a conversation once it exceeds this many characters (0: three quarters of the input limit, -1: never).",
 	"word-wrap":           "Wrap formatted output at specific width (default is 80)",
 	"max-tokens":          "Maximum number of tokens in response.",
-	"temp":                "Temperature (randomness) of results, from 0.0 to 2.0.",
+	"temp":                "Temperature (randomness) of results, from 0.0 to 2.0, 0 uses the default of the provider.",
 	"stop":                "Up to 4 sequences where the API will stop generating further tokens.",
 	"topp":                "TopP, an alternative to temperature that narrows response, from 0.0 to 1.0.",
 	"topk":                "TopK, only sample from the top K options for each subsequent token.",
@@ -79,38 +85,60 @@ var Help = map[string]string{
 	"show-last":           "Show the last saved conversation.",
 	"datastore":           "Configure the datastore to use.",
 	"auto-coder":          "Configure the auto coder to use.",
+	"cassette":            "Record the completions to cassette files, or replay them without calling the API.",
+	"retrieval":           "Send only the chunks of the loaded contexts relevant to the question once they exceed max-chars characters.",
+	"all-context":         "Send the loaded contexts whole instead of the chunks relevant to the question.",
 	"auto-commit":         "Automatically commit code changes after generation.",
 	"show-token-usage":    "Show token usage in the response.",
+	"usage-by":            "Group the usage by day, model or command.",
+	"usage-since":         "Only report the usage of the given duration. Valid units are: " + str.EnglishJoin(duration.ValidUnits(), true) + ".",
+	"usage-json":          "Print the usage as json.",
 	"coding-fences":       "Specify the code fences to be used. The value should be a two-part array, such as ['```', '```'].",
 	"verbose":             "Verbose mode. 0: no verbose, 1: debug verbose",
+	"trace-file":          "Append every request to the models and its response as a JSON line to this file.",
+	"image":               "Attach an image file to the question, the model must take images (vision: true in its settings).",
+	"models":              "Ask several models of the settings the same question and compare their answers side by side.",
+	"save-answers":        "Save the answer of every compared model as a conversation of its own.",
+	"no-tools":            "Disable the tools (read files, grep, run shell commands, edit files and the tools of the MCP servers) the model can call.",
+	"mcp-servers":         "Model Context Protocol servers run over stdio, their tools are offered to the model with the built-in ones.",
 }
 
 // Config is a structure used to configure a AI.
 // Its members are sorted roughly in order of importance for composers.
 type Config struct {
-	Model           string     `yaml:"default-model" env:"MODEL"`
-	API             string     `yaml:"default-api" env:"API"`
-	Raw             bool       `yaml:"raw" env:"RAW"`
-	Quiet           bool       `yaml:"quiet" env:"QUIET"`
-	MaxTokens       int        `yaml:"max-tokens" env:"MAX_TOKENS"`
-	MaxInputChars   int        `yaml:"max-input-chars" env:"MAX_INPUT_CHARS"`
-	Temperature     float64    `yaml:"temp" env:"TEMP"`
-	Stop            []string   `yaml:"stop" env:"STOP"`
-	TopP            float64    `yaml:"topp" env:"TOPP"`
-	TopK            int        `yaml:"topk" env:"TOPK"`
-	NoLimit         bool       `yaml:"no-limit" env:"NO_LIMIT"`
-	NoCache         bool       `yaml:"no-cache" env:"NO_CACHE"`
-	MaxRetries      int        `yaml:"max-retries" env:"MAX_RETRIES"`
-	WordWrap        int        `yaml:"word-wrap" env:"WORD_WRAP"`
-	Fanciness       uint       `yaml:"fanciness" env:"FANCINESS"`
-	LoadingText     string     `yaml:"loading-text" env:"LOADING_TEXT"`
-	FormatText      FormatText `yaml:"format-text"`
-	FormatAs        string     `yaml:"format-as" env:"FORMAT_AS"`
-	Verbose         int        `yaml:"verbose" env:"VERBOSE"`
-	APIs            APIs       `yaml:"apis"`
-	DataStore       DataStore  `yaml:"datastore"`
-	AutoCoder       AutoCoder  `yaml:"auto-coder"`
-	ShowTokenUsages bool       `yaml:"show-token-usage" env:"SHOW_TOKEN_USAGES"`
+	Model            string     `yaml:"default-model" env:"MODEL"`
+	API              string     `yaml:"default-api" env:"API"`
+	Raw              bool       `yaml:"raw" env:"RAW"`
+	Quiet            bool       `yaml:"quiet" env:"QUIET"`
+	MaxTokens        int        `yaml:"max-tokens" env:"MAX_TOKENS"`
+	MaxInputChars    int        `yaml:"max-input-chars" env:"MAX_INPUT_CHARS"`
+	Temperature      float64    `yaml:"temp" env:"TEMP"`
+	Stop             []string   `yaml:"stop" env:"STOP"`
+	TopP             float64    `yaml:"topp" env:"TOPP"`
+	TopK             int        `yaml:"topk" env:"TOPK"`
+	NoLimit          bool       `yaml:"no-limit" env:"NO_LIMIT"`
+	CompactThreshold int        `yaml:"compact-threshold" env:"COMPACT_THRESHOLD"`
+	NoCache          bool       `yaml:"no-cache" env:"NO_CACHE"`
+	NoTools          bool       `yaml:"no-tools" env:"NO_TOOLS"`
+	MaxRetries       int        `yaml:"max-retries" env:"MAX_RETRIES"`
+	WordWrap         int        `yaml:"word-wrap" env:"WORD_WRAP"`
+	Fanciness        uint       `yaml:"fanciness" env:"FANCINESS"`
+	LoadingText      string     `yaml:"loading-text" env:"LOADING_TEXT"`
+	FormatText       FormatText `yaml:"format-text"`
+	FormatAs         string     `yaml:"format-as" env:"FORMAT_AS"`
+	Role             string     `yaml:"role" env:"ROLE"`
+	Roles            Roles      `yaml:"roles"`
+	Verbose          int        `yaml:"verbose" env:"VERBOSE"`
+	APIs             APIs       `yaml:"apis"`
+	DataStore        DataStore  `yaml:"datastore"`
+	AutoCoder        AutoCoder  `yaml:"auto-coder"`
+	Cassette         Cassette   `yaml:"cassette"`
+	Retrieval        Retrieval  `yaml:"retrieval"`
+	MCPServers       MCPServers `yaml:"mcp-servers"`
+	AllContext       bool       `yaml:"all-context" env:"ALL_CONTEXT"`
+	HTTP             HTTP       `yaml:",inline"`
+	ShowTokenUsages  bool       `yaml:"show-token-usage" env:"SHOW_TOKEN_USAGES"`
+	TraceFile        string     `yaml:"trace-file,omitempty" env:"TRACE_FILE"`
 
 	DefaultPromptMode string `yaml:"default-prompt-mode,omitempty"`
 	ConversationID    string `yaml:"convo-id,omitempty"`
@@ -122,6 +150,7 @@ type Config struct {
 	SettingsPath string
 	System       *system.Analysis
 	Interactive  bool
+	Command      string
 	PromptFile   string
 	ContinueLast bool
 	Continue     string
@@ -164,11 +193,29 @@ type Model struct {
 	MaxChars int      `yaml:"max-input-chars"`
 	Aliases  []string `yaml:"aliases"`
 	Fallback string   `yaml:"fallback"`
+	NumCtx   int      `yaml:"num-ctx"`
+	Price    Price    `yaml:"price"`
+	Vision   bool     `yaml:"vision"`
+	// Deployment is the Azure OpenAI deployment serving the model, the
+	// name of the model by default.
+	Deployment string `yaml:"deployment"`
+}
+
+// Price is the price of a model per million tokens.
+type Price struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// Cost returns the price of a completion with the given token counts.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1_000_000
 }
 
 // API represents an API endpoint and its models.
 type API struct {
 	Name       string
+	Type       string           `yaml:"type"`
 	APIKey     string           `yaml:"api-key"`
 	APIKeyEnv  string           `yaml:"api-key-env"`
 	APIKeyCmd  string           `yaml:"api-key-cmd"`
@@ -179,6 +226,37 @@ type API struct {
 	Timeout    time.Duration    `yaml:"timeout"`
 	Models     map[string]Model `yaml:"models"`
 	User       string           `yaml:"user"`
+	KeepAlive  string           `yaml:"keep-alive"`
+	HTTP       HTTP             `yaml:",inline"`
+}
+
+// HTTP is the proxy and the TLS settings of the HTTP clients. The settings
+// of an API take precedence over the global ones.
+type HTTP struct {
+	Proxy      string `yaml:"http-proxy,omitempty" env:"HTTP_PROXY"`
+	CACert     string `yaml:"ca-cert,omitempty" env:"CA_CERT"`
+	ClientCert string `yaml:"client-cert,omitempty" env:"CLIENT_CERT"`
+	ClientKey  string `yaml:"client-key,omitempty" env:"CLIENT_KEY"`
+}
+
+// Or fills the unset settings with the given ones.
+func (h HTTP) Or(other HTTP) HTTP {
+	if h.Proxy == "" {
+		h.Proxy = other.Proxy
+	}
+	if h.CACert == "" {
+		h.CACert = other.CACert
+	}
+	// a client key belongs to its certificate
+	if h.ClientCert == "" {
+		h.ClientCert, h.ClientKey = other.ClientCert, other.ClientKey
+	}
+	return h
+}
+
+// IsOllama reports whether the API is served by an ollama server.
+func (a API) IsOllama() bool {
+	return a.Type == "ollama" || (a.Type == "" && a.Name == "ollama")
 }
 
 // APIs is a type alias to allow custom YAML decoding.
@@ -218,6 +296,28 @@ func (ft *FormatText) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// Role is the list of system messages of a role.
+type Role []string
+
+// UnmarshalYAML conforms with yaml.Unmarshaler, a role may be a single message.
+func (r *Role) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var message string
+	if err := unmarshal(&message); err == nil {
+		*r = Role{message}
+		return nil
+	}
+
+	var messages []string
+	if err := unmarshal(&messages); err != nil {
+		return err
+	}
+	*r = messages
+	return nil
+}
+
+// Roles is a map[name]role.
+type Roles map[string]Role
+
 // Deprecated: Use Model instead.
 type Ai struct {
 	SystemPrompt        string       `yaml:"system-prompt,omitempty"`
@@ -235,9 +335,55 @@ type Ai struct {
 type DataStore struct {
 	Type      string `yaml:"type,omitempty" env:"DATASTORE_TYPE"`
 	CachePath string `yaml:"cache-path" env:"CACHE_PATH"`
-	Url       string `yaml:"url,omitempty"`
-	Username  string `yaml:"username,omitempty"`
-	Password  string `yaml:"password,omitempty"`
+	Url       string `yaml:"url,omitempty" env:"DATASTORE_URL"`
+	Username  string `yaml:"username,omitempty" env:"DATASTORE_USERNAME"`
+	Password  string `yaml:"password,omitempty" env:"DATASTORE_PASSWORD"`
+}
+
+// Cassette records the completions to cassette files or replays them, so
+// the commands can be tested without a live model.
+type Cassette struct {
+	Mode string `yaml:"mode,omitempty" env:"CASSETTE_MODE"`
+	Dir  string `yaml:"dir,omitempty" env:"CASSETTE_DIR"`
+}
+
+// Replaying reports whether the completions are answered from cassettes.
+func (c Cassette) Replaying() bool {
+	return c.Mode == "replay"
+}
+
+// Retrieval is the chunking of large loaded contexts, see package retrieval.
+type Retrieval struct {
+	ChunkSize int `yaml:"chunk-size,omitempty" env:"RETRIEVAL_CHUNK_SIZE"`
+	MaxChars  int `yaml:"max-chars,omitempty" env:"RETRIEVAL_MAX_CHARS"`
+}
+
+// The confirmation policies of the tools of MCP servers.
+const (
+	MCPConfirmAsk   = "ask"
+	MCPConfirmAllow = "allow"
+	MCPConfirmDeny  = "deny"
+)
+
+// MCPServers are the Model Context Protocol servers by name.
+type MCPServers map[string]MCPServer
+
+// MCPServer is a Model Context Protocol server run over stdio, its tools are
+// offered to the model with the built-in ones.
+type MCPServer struct {
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	// Confirm is the policy of the tools of the server: ask before every
+	// call, allow every call or deny the tools, ask by default.
+	Confirm string `yaml:"confirm,omitempty"`
+	// Tools are the policies of single tools, overriding Confirm.
+	Tools map[string]string `yaml:"tools,omitempty"`
+}
+
+// Policy returns the confirmation policy of the tool of the server.
+func (s MCPServer) Policy(tool string) string {
+	return ordered.First(s.Tools[tool], s.Confirm, MCPConfirmAsk)
 }
 
 type OutputFormat string
@@ -337,6 +483,48 @@ func (c *Config) GetModel(name string) (model Model, err error) {
 	return mod, nil
 }
 
+// ForModel returns a copy of the settings answering with the given model of
+// the settings, on its own API, instead of the default one.
+func (c *Config) ForModel(name string) (*Config, error) {
+	mod, ok := c.Models[name]
+	if !ok {
+		return nil, errbook.NewUserErrorf(
+			"The model %s is not in the settings file, configure it with %s",
+			console.StderrStyles().InlineCode.Render(name),
+			console.StderrStyles().InlineCode.Render("ai configure"),
+		)
+	}
+
+	cfg := *c
+	cfg.Model = name
+	cfg.API = mod.API
+	return &cfg, nil
+}
+
+// GetRole returns the role with the given name.
+func (c *Config) GetRole(name string) (Role, error) {
+	role, ok := c.Roles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Roles))
+		for n := range c.Roles {
+			names = append(names, console.StderrStyles().InlineCode.Render(n))
+		}
+		sort.Strings(names)
+		return nil, errbook.Wrap(
+			fmt.Sprintf(
+				"The role %s is not configured.",
+				console.StderrStyles().InlineCode.Render(name),
+			),
+			errbook.NewUserErrorf(
+				"Your configured roles are: %s, add roles to the settings with %s",
+				strings.Join(names, ", "),
+				console.StderrStyles().InlineCode.Render("ai configure"),
+			),
+		)
+	}
+	return role, nil
+}
+
 func (c *Config) GetAPI(name string) (api API, err error) {
 	for _, a := range c.APIs {
 		if name == a.Name {
@@ -362,6 +550,18 @@ func (c *Config) GetAPI(name string) (api API, err error) {
 		)
 	}
 
+	api.HTTP = api.HTTP.Or(c.HTTP)
+
+	// replayed completions never reach the API
+	if c.Cassette.Replaying() {
+		return api, nil
+	}
+
+	// a local ollama server does not need a key
+	if api.IsOllama() && api.APIKey == "" && api.APIKeyEnv == "" && api.APIKeyCmd == "" {
+		return api, nil
+	}
+
 	api.APIKey, err = ensureApiKey(api)
 	if err != nil {
 		return api, err
@@ -23,4 +23,68 @@ func TestConfig(t *testing.T) {
 			"json":     "as json",
 		}), cfg.FormatText)
 	})
+	t.Run("http settings", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, yaml.Unmarshal([]byte(`
+http-proxy: http://proxy:3128
+ca-cert: /etc/ca.pem
+apis:
+  openai:
+    api-key: key
+    client-cert: /etc/client.pem
+  ark:
+    api-key: key
+    http-proxy: http://other:3128
+`), &cfg))
+
+		openai, err := cfg.GetAPI("openai")
+		require.NoError(t, err)
+		require.Equal(t, HTTP{Proxy: "http://proxy:3128", CACert: "/etc/ca.pem", ClientCert: "/etc/client.pem"}, openai.HTTP)
+
+		ark, err := cfg.GetAPI("ark")
+		require.NoError(t, err)
+		require.Equal(t, HTTP{Proxy: "http://other:3128", CACert: "/etc/ca.pem"}, ark.HTTP)
+	})
+	t.Run("roles", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, yaml.Unmarshal([]byte(`
+role: shell
+roles:
+  default: []
+  shell:
+    - you are a shell expert
+    - file:///etc/prompts/shell.md
+  pirate: you answer like a pirate
+`), &cfg))
+
+		role, err := cfg.GetRole(cfg.Role)
+		require.NoError(t, err)
+		require.Equal(t, Role{"you are a shell expert", "file:///etc/prompts/shell.md"}, role)
+
+		role, err = cfg.GetRole("pirate")
+		require.NoError(t, err)
+		require.Equal(t, Role{"you answer like a pirate"}, role)
+
+		_, err = cfg.GetRole("missing")
+		require.Error(t, err)
+	})
+	t.Run("for model", func(t *testing.T) {
+		cfg := Config{
+			Model: "gpt-4o",
+			API:   "openai",
+			Models: map[string]Model{
+				"gpt-4o":   {Name: "gpt-4o", API: "openai"},
+				"deepseek": {Name: "deepseek-chat", API: "deepseek"},
+			},
+		}
+
+		other, err := cfg.ForModel("deepseek")
+		require.NoError(t, err)
+		require.Equal(t, "deepseek", other.Model)
+		require.Equal(t, "deepseek", other.API)
+		require.Equal(t, "gpt-4o", cfg.Model)
+
+		_, err = cfg.ForModel("missing")
+		require.Error(t, err)
+	})
 }
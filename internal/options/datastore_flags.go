@@ -53,7 +53,7 @@ func (d *DataStoreFlags) AddFlags(flags *pflag.FlagSet) {
 func (d *DataStoreFlags) Validate() error {
 	if d.Type != nil {
 		dsType := *d.Type
-		if dsType != "file" && dsType != "mongo" {
+		if dsType != "db" && dsType != "file" && dsType != "postgres" && dsType != "mysql" {
 			return fmt.Errorf("invalid datastore type: %s", dsType)
 		}
 	}
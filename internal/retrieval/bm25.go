@@ -0,0 +1,147 @@
+package retrieval
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters, the usual values: k1 saturates the weight of repeated
+// terms, b normalizes it by the length of the chunk.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// stopWords are left out of the index, they match nearly every chunk.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "do": true, "does": true, "for": true, "from": true,
+	"how": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "this": true, "to": true,
+	"what": true, "when": true, "where": true, "which": true, "why": true,
+	"with": true,
+}
+
+// Index is a BM25 index over chunks.
+type Index struct {
+	chunks    []Chunk
+	terms     []map[string]int
+	lengths   []int
+	avgLength float64
+	// frequency is the number of chunks each term is in
+	frequency map[string]int
+}
+
+// NewIndex indexes the terms of the chunks.
+func NewIndex(chunks []Chunk) *Index {
+	ix := &Index{
+		chunks:    chunks,
+		terms:     make([]map[string]int, len(chunks)),
+		lengths:   make([]int, len(chunks)),
+		frequency: make(map[string]int),
+	}
+
+	total := 0
+	for i, chunk := range chunks {
+		terms := make(map[string]int)
+		for _, term := range Tokenize(chunk.Content) {
+			terms[term]++
+			ix.lengths[i]++
+		}
+		for term := range terms {
+			ix.frequency[term]++
+		}
+		ix.terms[i] = terms
+		total += ix.lengths[i]
+	}
+	if len(chunks) > 0 {
+		ix.avgLength = float64(total) / float64(len(chunks))
+	}
+
+	return ix
+}
+
+// Search returns the chunks matching the query, the most relevant first.
+func (ix *Index) Search(query string) []Chunk {
+	queryTerms := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		queryTerms[term] = true
+	}
+
+	n := float64(len(ix.chunks))
+	var result []Chunk
+	for i, chunk := range ix.chunks {
+		var score float64
+		for term := range queryTerms {
+			tf := float64(ix.terms[i][term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(ix.frequency[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - b + b*float64(ix.lengths[i])/ix.avgLength
+			score += idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+		if score > 0 {
+			chunk.Score = score
+			result = append(result, chunk)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
+
+// Tokenize splits text into lower case terms. Identifiers are indexed whole
+// and by their camelCase and snake_case words, so a question about "load
+// context" finds LoadContext.
+func Tokenize(text string) []string {
+	var terms []string
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			terms = appendTerm(terms, word)
+		}
+		for _, part := range parts {
+			terms = appendTerm(terms, part)
+		}
+	}
+	return terms
+}
+
+func appendTerm(terms []string, term string) []string {
+	term = strings.ToLower(strings.Trim(term, "_"))
+	if len(term) < 2 || stopWords[term] {
+		return terms
+	}
+	return append(terms, term)
+}
+
+// splitIdentifier splits snake_case and camelCase words, keeping runs of
+// upper case letters like HTTP together.
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, snake := range strings.Split(word, "_") {
+		runes := []rune(snake)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
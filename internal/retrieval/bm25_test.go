@@ -0,0 +1,33 @@
+package retrieval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t,
+		[]string{"loadcontext", "load", "context", "httpserver", "http", "server", "parse_url", "parse", "url", "v2"},
+		Tokenize("What is the LoadContext of HTTPServer? parse_url(v2) a"),
+	)
+}
+
+func TestIndex(t *testing.T) {
+	ix := NewIndex([]Chunk{
+		{Source: "convo.go", Content: "SaveConversation saves the title of a conversation"},
+		{Source: "ctx.go", Content: "LoadContext is a file or an url loaded into a conversation"},
+		{Source: "ctx.go", Content: "CleanContexts removes the loaded contexts of a conversation, every loaded context"},
+		{Source: "readme.md", Content: "Install the terminal with brew"},
+	})
+
+	result := ix.Search("how are contexts loaded?")
+	if assert.Len(t, result, 2) {
+		assert.Equal(t, "ctx.go", result[0].Source)
+		assert.Contains(t, result[0].Content, "CleanContexts")
+		assert.Greater(t, result[0].Score, result[1].Score)
+	}
+
+	assert.Empty(t, ix.Search("kubernetes"))
+	assert.Empty(t, NewIndex(nil).Search("conversation"))
+}
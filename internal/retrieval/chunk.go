@@ -0,0 +1,99 @@
+// Package retrieval picks the parts of large loaded contexts relevant to a
+// question with a local BM25 index, so only those are sent to the model.
+package retrieval
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a loaded file or URL to retrieve chunks from.
+type Document struct {
+	Source  string
+	Content string
+}
+
+// Chunk is a part of a document made of whole lines, the lines of a single
+// line longer than the chunk size are cut.
+type Chunk struct {
+	Source string
+	// StartLine and EndLine are the 1-based lines of the document the
+	// chunk spans, both included.
+	StartLine int
+	EndLine   int
+	Content   string
+	// Score is the relevance of the chunk to the question, set by Search.
+	Score float64
+
+	// doc and seq order the chunks as they appear in the documents
+	doc, seq int
+}
+
+// Split cuts the document into chunks of about size characters. A chunk
+// ends at a blank line once it is half full, to keep paragraphs and
+// functions together.
+func Split(doc Document, size int) []Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	var (
+		chunks  []Chunk
+		current strings.Builder
+		start   = 1
+	)
+	flush := func(end int) {
+		if strings.TrimSpace(current.String()) != "" {
+			chunks = append(chunks, Chunk{
+				Source:    doc.Source,
+				StartLine: start,
+				EndLine:   end,
+				Content:   current.String(),
+				seq:       len(chunks),
+			})
+		}
+		current.Reset()
+		start = end + 1
+	}
+
+	lines := splitLines(doc.Content)
+	for i, line := range lines {
+		n := i + 1
+		length := utf8.RuneCountInString(line)
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+length > size {
+			flush(n - 1)
+		}
+		// the current chunk is empty, the pieces of the line make their own
+		for length > size {
+			cut := cutRunes(line, size)
+			current.WriteString(line[:cut])
+			flush(n)
+			start = n
+			line = line[cut:]
+			length = utf8.RuneCountInString(line)
+		}
+		current.WriteString(line)
+		if strings.TrimSpace(line) == "" && utf8.RuneCountInString(current.String()) >= size/2 {
+			flush(n)
+		}
+	}
+	flush(len(lines))
+
+	return chunks
+}
+
+// splitLines splits content into its lines, keeping their line feeds.
+func splitLines(content string) []string {
+	return strings.SplitAfter(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// cutRunes returns the byte offset of the n-th rune of s.
+func cutRunes(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
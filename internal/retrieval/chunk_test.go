@@ -0,0 +1,49 @@
+package retrieval
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	t.Run("chunks end at blank lines once half full", func(t *testing.T) {
+		content := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n"
+		chunks := Split(Document{Source: "a.go", Content: content}, 40)
+		assert.Len(t, chunks, 2)
+		assert.Equal(t, "func a() {\n\treturn 1\n}\n\n", chunks[0].Content)
+		assert.Equal(t, 1, chunks[0].StartLine)
+		assert.Equal(t, 4, chunks[0].EndLine)
+		assert.Equal(t, "func b() {\n\treturn 2\n}", chunks[1].Content)
+		assert.Equal(t, 5, chunks[1].StartLine)
+		assert.Equal(t, 7, chunks[1].EndLine)
+		assert.Equal(t, "a.go", chunks[1].Source)
+	})
+
+	t.Run("chunks stay within the size", func(t *testing.T) {
+		content := strings.Repeat("0123456789\n", 10)
+		chunks := Split(Document{Content: content}, 25)
+		assert.Len(t, chunks, 5)
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk.Content), 25)
+			assert.Equal(t, chunk.StartLine+1, chunk.EndLine)
+		}
+	})
+
+	t.Run("long lines are cut", func(t *testing.T) {
+		chunks := Split(Document{Content: "short\n" + strings.Repeat("é", 25) + "\nend"}, 10)
+		assert.Len(t, chunks, 4)
+		assert.Equal(t, "short\n", chunks[0].Content)
+		assert.Equal(t, strings.Repeat("é", 10), chunks[1].Content)
+		assert.Equal(t, 2, chunks[1].StartLine)
+		assert.Equal(t, 2, chunks[2].EndLine)
+		assert.Equal(t, strings.Repeat("é", 5)+"\nend", chunks[3].Content)
+		assert.Equal(t, 2, chunks[3].StartLine)
+		assert.Equal(t, 3, chunks[3].EndLine)
+	})
+
+	t.Run("blank documents have no chunks", func(t *testing.T) {
+		assert.Empty(t, Split(Document{Content: "\n\n  \n"}, 10))
+	})
+}
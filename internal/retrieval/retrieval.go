@@ -0,0 +1,93 @@
+package retrieval
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChunkSize is the size of the chunks in characters.
+	DefaultChunkSize = 1500
+	// DefaultMaxChars is the size of the chunks picked for a question.
+	DefaultMaxChars = 12000
+)
+
+// Selection is the part of the documents picked for a question.
+type Selection struct {
+	// Chunks are the picked chunks in the order of the documents, the
+	// documents are whole chunks of their own when All is set.
+	Chunks []Chunk
+	// Total is the number of chunks of the documents.
+	Total int
+	// All tells whether the documents fit into the limit and are sent whole.
+	All bool
+}
+
+// Select picks the chunks of the documents most relevant to the query that
+// fit into maxChars characters. Documents fitting into the limit together
+// are kept whole. Without any chunk matching the query, the leading chunks
+// of the documents are picked.
+func Select(docs []Document, query string, size, maxChars int) Selection {
+	if maxChars <= 0 {
+		maxChars = DefaultMaxChars
+	}
+
+	total := 0
+	for _, doc := range docs {
+		total += utf8.RuneCountInString(doc.Content)
+	}
+	if total <= maxChars {
+		return Whole(docs)
+	}
+
+	var chunks []Chunk
+	for i, doc := range docs {
+		for _, chunk := range Split(doc, size) {
+			chunk.doc = i
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	candidates := NewIndex(chunks).Search(query)
+	if len(candidates) == 0 {
+		candidates = chunks
+	}
+
+	var picked []Chunk
+	used := 0
+	for _, chunk := range candidates {
+		length := utf8.RuneCountInString(chunk.Content)
+		if used+length > maxChars {
+			continue
+		}
+		picked = append(picked, chunk)
+		used += length
+	}
+
+	sort.Slice(picked, func(i, j int) bool {
+		if picked[i].doc != picked[j].doc {
+			return picked[i].doc < picked[j].doc
+		}
+		return picked[i].seq < picked[j].seq
+	})
+
+	return Selection{Chunks: picked, Total: len(chunks)}
+}
+
+// Whole returns a selection of the whole documents.
+func Whole(docs []Document) Selection {
+	chunks := make([]Chunk, 0, len(docs))
+	for i, doc := range docs {
+		if doc.Content == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{
+			Source:    doc.Source,
+			StartLine: 1,
+			EndLine:   len(splitLines(doc.Content)),
+			Content:   doc.Content,
+			doc:       i,
+		})
+	}
+	return Selection{Chunks: chunks, Total: len(chunks), All: true}
+}
@@ -0,0 +1,49 @@
+package retrieval
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor sit amet\n", 20)
+	docs := []Document{
+		{Source: "a.md", Content: filler + "\nthe proxy is set with http-proxy\n\n" + filler},
+		{Source: "b.md", Content: filler + "\nthe proxy may need a ca-cert\n\n" + filler},
+	}
+
+	t.Run("small documents are kept whole", func(t *testing.T) {
+		sel := Select(docs, "proxy", 200, 10000)
+		assert.True(t, sel.All)
+		require.Len(t, sel.Chunks, 2)
+		assert.Equal(t, docs[1].Content, sel.Chunks[1].Content)
+		assert.Equal(t, 43, sel.Chunks[1].EndLine)
+	})
+
+	t.Run("relevant chunks in document order", func(t *testing.T) {
+		sel := Select(docs, "which ca-cert does the proxy need?", 200, 600)
+		assert.False(t, sel.All)
+		assert.Greater(t, sel.Total, 4)
+		require.Len(t, sel.Chunks, 2)
+		assert.Equal(t, "a.md", sel.Chunks[0].Source)
+		assert.Contains(t, sel.Chunks[0].Content, "http-proxy")
+		assert.Equal(t, "b.md", sel.Chunks[1].Source)
+		assert.Contains(t, sel.Chunks[1].Content, "ca-cert")
+	})
+
+	t.Run("the limit is kept", func(t *testing.T) {
+		sel := Select(docs, "which ca-cert does the proxy need?", 200, 250)
+		require.Len(t, sel.Chunks, 1)
+		assert.Contains(t, sel.Chunks[0].Content, "ca-cert")
+	})
+
+	t.Run("leading chunks without matches", func(t *testing.T) {
+		sel := Select(docs, "kubernetes", 200, 250)
+		require.NotEmpty(t, sel.Chunks)
+		assert.Equal(t, "a.md", sel.Chunks[0].Source)
+		assert.Equal(t, 1, sel.Chunks[0].StartLine)
+	})
+}
@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"runtime"
@@ -27,6 +28,13 @@ func PrepareInteractiveCommand(input string) *exec.Cmd {
 	return prepareUnixCommand(input)
 }
 
+// PrepareInteractiveCommandContext is PrepareInteractiveCommand for a command
+// that is killed when ctx is done.
+func PrepareInteractiveCommandContext(ctx context.Context, input string) *exec.Cmd {
+	cmd := PrepareInteractiveCommand(input)
+	return exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
+}
+
 func PrepareEditSettingsCommand(editor, filename string) *exec.Cmd {
 	switch editor {
 	case "vim":
@@ -6,8 +6,11 @@ import (
 	"context"
 	"fmt"
 	"html"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"unicode"
 
 	"github.com/atotto/clipboard"
@@ -19,6 +22,7 @@ import (
 	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
 
 	"github.com/coding-hui/ai-terminal/internal/ai"
+	"github.com/coding-hui/ai-terminal/internal/ai/tools"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/options"
@@ -44,10 +48,16 @@ const (
 	errorState                     // State when an error occurs
 )
 
+// interruptMsg is sent when the process gets a SIGINT, ^C only arrives as a
+// signal when the input is not a terminal.
+type interruptMsg struct{}
+
 // Chat represents the main chat application structure
 type Chat struct {
 	Error      *errbook.AiError // Error encountered during chat
 	TokenUsage llms.Usage       // Token usage statistics from the AI
+	Model      string           // Model that answered, may be a fallback of the configured one
+	Warnings   []string         // Warnings about the input that was cut to fit the model limit
 
 	output     string // Raw output from the AI
 	glamOutput string // Formatted output with markdown rendering
@@ -56,6 +66,9 @@ type Chat struct {
 	opts   *Options        // Chat options
 	config *options.Config // Application configuration
 	engine *ai.Engine      // AI engine for processing requests
+	stream *ai.Stream      // Stream of the running completion
+
+	interrupted bool // Whether the completion was interrupted by the user
 
 	anim         tea.Model             // Animation model for loading states
 	renderer     *lipgloss.Renderer    // Text renderer for styling
@@ -148,7 +161,14 @@ func (c *Chat) Run() error {
 		opts = append(opts, tea.WithoutRenderer())
 	}
 
-	if _, err := tea.NewProgram(c, opts...).Run(); err != nil {
+	opts = append(opts, tea.WithoutSignalHandler())
+
+	p := tea.NewProgram(c, opts...)
+	c.opts.ctx = tools.WithConfirm(c.opts.ctx, confirmTools(p))
+	stop := notifySignals(p)
+	_, err := p.Run()
+	stop()
+	if err != nil {
 		return errbook.Wrap("Couldn't start Bubble Tea program.", err)
 	}
 
@@ -156,6 +176,12 @@ func (c *Chat) Run() error {
 		return *c.Error
 	}
 
+	if !c.config.Quiet {
+		for _, warning := range c.Warnings {
+			console.WarnStderr(warning)
+		}
+	}
+
 	if term.IsOutputTTY() && !c.config.Raw {
 		switch {
 		case c.glamOutput != "":
@@ -165,6 +191,10 @@ func (c *Chat) Run() error {
 		}
 	}
 
+	if c.interrupted && !c.config.Quiet {
+		console.WarnStderr("The completion was interrupted, the partial answer is kept in the conversation.")
+	}
+
 	// Add clipboard support
 	if c.opts.copyToClipboard {
 		if c.output != "" {
@@ -224,9 +254,17 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if c.config.Show != "" || c.config.ShowLast {
 			cmds = append(cmds, c.readFromCacheCmd())
 		} else {
-			cmds = append(cmds, c.startCompletionCmd(msg.Messages), c.awaitChatCompletedCmd())
+			cmds = append(cmds, c.startCompletionCmd(msg.Messages))
 		}
 
+	case *ai.Stream:
+		c.stream = msg
+		if c.interrupted {
+			// interrupted while the request was being prepared
+			c.stream.Close()
+		}
+		cmds = append(cmds, c.awaitChatCompletedCmd())
+
 	case ai.StreamCompletionOutput:
 		if msg.GetContent() != "" {
 			c.appendToOutput(msg.GetContent())
@@ -234,12 +272,19 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		if msg.IsLast() {
 			c.state = doneState
+			c.interrupted = c.interrupted || msg.IsInterrupt()
 			c.TokenUsage = msg.GetUsage()
+			c.Model = msg.GetModel()
+			c.Warnings = msg.GetWarnings()
 			if c.opts.promptMode != ui.ExecPromptMode {
 				c.config.ContinueLast = true
 			}
 			// Write chat history when conversation is completed
-			if err := c.writeChatHistory("", c.GetOutput()); err != nil {
+			output := c.GetOutput()
+			if c.interrupted {
+				output = strings.TrimRightFunc(output, unicode.IsSpace) + "\n\n" + ai.InterruptedMarker
+			}
+			if err := c.writeChatHistory("", output); err != nil {
 				console.RenderError(err, "Failed to write chat history")
 			}
 			return c, c.quit
@@ -257,11 +302,13 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		c.glamViewport.Height = c.height
 		return c, nil
 
+	case interruptMsg:
+		return c.interrupt()
+
 	case tea.KeyMsg:
 		switch msg.Type {
 		case tea.KeyCtrlC:
-			c.state = doneState
-			return c, c.quit
+			return c.interrupt()
 		}
 	}
 
@@ -282,6 +329,31 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return c, tea.Batch(cmds...)
 }
 
+// interrupt cancels the running completion and waits for its partial answer,
+// a second interruption or one without a running completion quits at once.
+func (c *Chat) interrupt() (tea.Model, tea.Cmd) {
+	running := c.state == requestState || c.state == responseState
+	if running && !c.interrupted {
+		c.interrupted = true
+		if c.stream != nil {
+			c.stream.Close()
+		}
+		return c, nil
+	}
+
+	if c.stream != nil {
+		c.stream.Close()
+	}
+	c.state = doneState
+	return c, c.quit
+}
+
+// Interrupted reports whether the completion was interrupted by the user,
+// the output is then the partial answer.
+func (c *Chat) Interrupted() bool {
+	return c.interrupted
+}
+
 // viewportNeeded checks if a viewport is required based on content height
 // Returns true if the content exceeds the terminal height
 func (c *Chat) viewportNeeded() bool {
@@ -365,22 +437,40 @@ func (c *Chat) quit() tea.Msg {
 
 // startCompletionCmd creates a command to start an AI completion request
 // messages: The chat messages to send to the AI
-// Returns a command that will initiate the completion request
+// Returns a command that will initiate the completion request and yield its stream
 func (c *Chat) startCompletionCmd(messages []llms.ChatMessage) tea.Cmd {
 	return func() tea.Msg {
-		output, err := c.engine.CreateStreamCompletion(context.Background(), messages)
+		stream, err := c.engine.CreateStreamCompletion(c.opts.ctx, messages, ai.WithFormat(c.opts.format))
 		if err != nil {
 			return err
 		}
-		return output
+		return stream
 	}
 }
 
-// awaitChatCompletedCmd creates a command to wait for chat completion
-// Returns a command that will wait for the AI response
+// awaitChatCompletedCmd creates a command to wait for the next output of the stream
+// Returns a command that will wait for the AI response, or the error that ended it.
+// An interrupted completion ends with a last output, its partial answer was
+// already delivered.
 func (c *Chat) awaitChatCompletedCmd() tea.Cmd {
+	stream := c.stream
 	return func() tea.Msg {
-		return <-c.engine.GetChannel()
+		if out, ok := <-stream.Outputs(); ok {
+			return out
+		}
+		result, err := stream.Wait()
+		if result != nil && result.IsInterrupt() {
+			return ai.StreamCompletionOutput{
+				Last:      true,
+				Interrupt: true,
+				Model:     result.GetModel(),
+				Warnings:  result.GetWarnings(),
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return ai.StreamCompletionOutput{Last: true}
 	}
 }
 
@@ -478,7 +568,11 @@ func (c *Chat) saveConversation() error {
 		), err)
 	}
 
-	if err := convoStore.SaveConversation(ctx, writeToID, writeToTitle, c.config.Model); err != nil {
+	model := c.config.Model
+	if c.Model != "" {
+		model = c.Model
+	}
+	if err := convoStore.SaveConversation(ctx, writeToID, writeToTitle, model, c.engine.Role()); err != nil {
 		return errbook.Wrap(fmt.Sprintf(
 			"There was a problem writing %s to the cache. Use %s / %s to disable it.",
 			c.config.CacheWriteToID,
@@ -538,6 +632,48 @@ func (c *Chat) getPromptPrefix() string {
 	return promptPrefix
 }
 
+// confirmTools asks the confirmations of the tools on the terminal of the
+// program, which is handed over while the user is asked; the program would
+// read the answer of the user otherwise.
+func confirmTools(p *tea.Program) tools.ConfirmFunc {
+	return func(format string, args ...any) bool {
+		if err := p.ReleaseTerminal(); err != nil {
+			return false
+		}
+		defer p.RestoreTerminal() //nolint:errcheck
+		return tools.ConsoleConfirm(format, args...)
+	}
+}
+
+// notifySignals sends the SIGINT the process gets to the program, which
+// interrupts the completion instead of ending the program, and quits it on
+// SIGTERM. The returned function stops the notifications.
+func notifySignals(p *tea.Program) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case s := <-sig:
+				if s == os.Interrupt {
+					p.Send(interruptMsg{})
+				} else {
+					p.Quit()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
+
 // firstLine extracts the first line from a multi-line string
 // s: The input string to process
 // Returns the first line of the string
@@ -92,6 +92,12 @@ func (h *HistoryWriter) RenderError(err error, reason string, args ...interface{
 	_ = h.WriteToHistory(errorMsg)
 }
 
+// RenderWarn writes a warning to console and chat history
+func (h *HistoryWriter) RenderWarn(format string, args ...interface{}) {
+	console.Warnf(format, args...)
+	_ = h.WriteToHistory("> WARNING: " + fmt.Sprintf(format, args...))
+}
+
 // RenderComment writes comment to console and chat history
 func (h *HistoryWriter) RenderComment(format string, args ...interface{}) {
 	console.RenderComment(format, args...)
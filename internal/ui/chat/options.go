@@ -19,6 +19,7 @@ type Options struct {
 	renderer        *lipgloss.Renderer
 	wordWrap        int
 	copyToClipboard bool
+	format          string
 
 	engine *ai.Engine
 
@@ -82,8 +83,16 @@ func WithCopyToClipboard(copy bool) Option {
 	}
 }
 
+// WithFormat asks for the response in the given format, see ai.WithFormat.
+func WithFormat(format string) Option {
+	return func(o *Options) {
+		o.format = format
+	}
+}
+
 func NewOptions(opts ...Option) *Options {
 	o := &Options{
+		ctx:        context.Background(),
 		runMode:    ui.CliMode,
 		promptMode: ui.ChatPromptMode,
 		renderer:   console.StderrRenderer(),
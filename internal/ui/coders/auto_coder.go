@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
 	"github.com/coding-hui/ai-terminal/internal/ai"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
@@ -34,6 +36,7 @@ type AutoCoder struct {
 	loadedContexts       []*convo.LoadContext
 	engine               *ai.Engine
 	store                convo.Store
+	images               []llms.BinaryContent
 
 	versionInfo version.Info
 	cfg         *options.Config
@@ -142,7 +145,9 @@ func (a *AutoCoder) loadExistingContexts() error {
 		return errbook.Wrap("Failed to load conversation contexts", err)
 	}
 
-	// Convert loaded contexts to pointers and store them in the AutoCoder instance
+	// Convert loaded contexts to pointers and store them in the AutoCoder instance,
+	// replacing the ones of the previous command
+	a.loadedContexts = a.loadedContexts[:0]
 	for _, ctx := range contexts {
 		a.loadedContexts = append(a.loadedContexts, &ctx)
 	}
@@ -1,6 +1,8 @@
 package coders
 
 import (
+	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
+
 	"github.com/coding-hui/ai-terminal/internal/ai"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/git"
@@ -48,6 +50,13 @@ func WithLoadedContexts(contexts []*convo.LoadContext) AutoCoderOption {
 	}
 }
 
+// WithImages attaches the images to the questions, see ai.WithImages.
+func WithImages(images []llms.BinaryContent) AutoCoderOption {
+	return func(a *AutoCoder) {
+		a.images = images
+	}
+}
+
 func WithPrompt(prompt string) AutoCoderOption {
 	return func(a *AutoCoder) {
 		a.prompt = prompt
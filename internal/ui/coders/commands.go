@@ -11,14 +11,17 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/coding-hui/common/util/fileutil"
 	"github.com/coding-hui/wecoding-sdk-go/services/ai/llms"
 
+	"github.com/coding-hui/ai-terminal/internal/ai"
 	"github.com/coding-hui/ai-terminal/internal/cli/commit"
 	"github.com/coding-hui/ai-terminal/internal/convo"
 	"github.com/coding-hui/ai-terminal/internal/errbook"
 	"github.com/coding-hui/ai-terminal/internal/prompt"
+	"github.com/coding-hui/ai-terminal/internal/retrieval"
 	"github.com/coding-hui/ai-terminal/internal/runner"
 	"github.com/coding-hui/ai-terminal/internal/ui"
 	"github.com/coding-hui/ai-terminal/internal/ui/chat"
@@ -74,6 +77,7 @@ func (c *CommandExecutor) registryCmds() {
 	supportCommands["/chat-model"] = c.switchNewChatModel
 	supportCommands["/help"] = c.help
 	supportCommands["/clear"] = c.clear
+	supportCommands["/compact"] = c.compact
 }
 
 // isCommand detects if input is a command (prefixed with ! or /)
@@ -147,6 +151,7 @@ func (c *CommandExecutor) ask(ctx context.Context, input string) error {
 		chat.WithEngine(c.coder.engine),
 		chat.WithPromptMode(c.coder.promptMode),
 		chat.WithCopyToClipboard(true),
+		chat.WithFormat(c.coder.cfg.FormatAs),
 	)
 
 	return chatModel.Run()
@@ -157,7 +162,7 @@ func (c *CommandExecutor) loadFileContent(path string) (string, error) {
 	// Handle remote URLs
 	if rest.IsValidURL(path) {
 		c.historyWriter.Render("Loading remote content [%s]", path)
-		return rest.FetchURLContent(path)
+		return rest.FetchURLContent(path, c.coder.cfg.HTTP)
 	}
 
 	// Handle local files
@@ -270,6 +275,12 @@ func (c *CommandExecutor) add(_ context.Context, input string) (err error) {
 			lc.Type = convo.ContentTypeURL
 		} else {
 			lc.FilePath = absPath
+			if ai.IsImageFile(absPath) {
+				lc.Type = convo.ContentTypeImage
+				if !c.coder.cfg.CurrentModel.Vision {
+					c.historyWriter.RenderWarn("The model %s can't take images, %s will not be sent to it.", c.coder.cfg.CurrentModel.Name, absPath)
+				}
+			}
 		}
 
 		c.coder.loadedContexts = append(c.coder.loadedContexts, lc)
@@ -393,6 +404,9 @@ func (c *CommandExecutor) coding(ctx context.Context, input string) error {
 	if err != nil {
 		return err
 	}
+	if messages, err = c.withAddedImages(messages); err != nil {
+		return err
+	}
 
 	if c.flags[FlagVerbose] {
 		return console.RenderChatMessages(messages)
@@ -610,6 +624,10 @@ func (c *CommandExecutor) exec(_ context.Context, input string) error {
 	if err := ch.Run(); err != nil {
 		return err
 	}
+	if ch.Interrupted() {
+		c.historyWriter.Render("No command was run, the answer was interrupted")
+		return nil
+	}
 
 	cmd := strings.TrimSpace(ch.GetOutput())
 	cmd = strings.Trim(cmd, "`")
@@ -640,7 +658,7 @@ func (c *CommandExecutor) help(_ context.Context, _ string) error {
 
 	// Group commands by functionality
 	fileCommands := []ui.Command{
-		{Name: "/add <file/folder patterns/URLs>", Desc: "Add local files or URLs to chat context"},
+		{Name: "/add <file/folder patterns/URLs>", Desc: "Add local files, images or URLs to chat context"},
 		{Name: "/list", Desc: "List files currently in chat context"},
 		{Name: "/remove <patterns>", Desc: "Remove files from context"},
 		{Name: "/drop", Desc: "Clear all files from context"},
@@ -663,6 +681,7 @@ func (c *CommandExecutor) help(_ context.Context, _ string) error {
 		{Name: "/exec <instruction>", Desc: "Infer and execute a shell command"},
 		{Name: "/chat-model <model> <api>", Desc: "Switch to a different chat model and API"},
 		{Name: "/clear", Desc: "Clear current conversation"},
+		{Name: "/compact", Desc: "Summarize older turns of the current conversation"},
 		{Name: "/exit", Desc: "Exit the terminal"},
 		{Name: "/help", Desc: "Show this help message"},
 	}
@@ -685,6 +704,7 @@ func (c *CommandExecutor) help(_ context.Context, _ string) error {
 	c.historyWriter.Render("  • In default mode, input is treated as /coding command")
 	c.historyWriter.Render("  • Use /ask mode for questions, /exec for shell commands")
 	c.historyWriter.Render("  • Add --verbose flag to see raw AI messages")
+	c.historyWriter.Render("  • Add --all-context flag to send large loaded files whole, not only the relevant chunks")
 	c.historyWriter.Render("  • Add -y flag to skip confirmations")
 
 	return nil
@@ -708,6 +728,25 @@ func (c *CommandExecutor) clear(ctx context.Context, _ string) error {
 	return nil
 }
 
+// compact summarizes the older turns of the current conversation
+func (c *CommandExecutor) compact(ctx context.Context, _ string) error {
+	result, err := c.coder.engine.Compact(ctx, c.coder.cfg.CacheWriteToID)
+	if err != nil {
+		return err
+	}
+
+	if result.Summarized == 0 {
+		c.historyWriter.RenderComment("Nothing to compact, the conversation has %d messages", result.Kept)
+		return nil
+	}
+
+	c.historyWriter.Render(
+		"Compacted %d messages into a summary and kept the last %d (original archived as %s)",
+		result.Summarized, result.Kept, result.ArchiveID[:convo.Sha1short],
+	)
+	return nil
+}
+
 func (c *CommandExecutor) exit(_ context.Context, _ string) error {
 	fmt.Println("Bye!")
 	os.Exit(0)
@@ -716,7 +755,7 @@ func (c *CommandExecutor) exit(_ context.Context, _ string) error {
 }
 
 func (c *CommandExecutor) prepareDesignCompletionMessages(userInput string) ([]llms.ChatMessage, error) {
-	addedFileMessages, err := c.getAddedFileContent()
+	addedFileMessages, err := c.getRelevantFileContent(userInput)
 	if err != nil {
 		return nil, err
 	}
@@ -729,11 +768,11 @@ func (c *CommandExecutor) prepareDesignCompletionMessages(userInput string) ([]l
 		return nil, err
 	}
 
-	return messages, nil
+	return c.withAddedImages(messages)
 }
 
 func (c *CommandExecutor) prepareAskCompletionMessages(userInput string) ([]llms.ChatMessage, error) {
-	addedFileMessages, err := c.getAddedFileContent()
+	addedFileMessages, err := c.getRelevantFileContent(userInput)
 	if err != nil {
 		return nil, err
 	}
@@ -747,7 +786,7 @@ func (c *CommandExecutor) prepareAskCompletionMessages(userInput string) ([]llms
 		if err != nil {
 			return nil, err
 		}
-		return messages, nil
+		return c.withAddedImages(messages)
 	} else {
 		// No files added - use general assistant prompt
 		messages, err := promptAskGeneral.FormatMessages(map[string]any{
@@ -756,33 +795,143 @@ func (c *CommandExecutor) prepareAskCompletionMessages(userInput string) ([]llms
 		if err != nil {
 			return nil, err
 		}
-		return messages, nil
+		return c.withAddedImages(messages)
 	}
 }
 
+// getAddedFileContent returns the loaded contexts whole in the order they
+// were added, skipping the ones that no longer fit into the input limit.
 func (c *CommandExecutor) getAddedFileContent() (string, error) {
+	docs, err := c.loadedDocuments()
+	if err != nil {
+		return "", err
+	}
+	return c.formatChunks(retrieval.Whole(docs))
+}
+
+// getRelevantFileContent returns the chunks of the loaded contexts relevant
+// to the question. Contexts fitting into the retrieval limit, or all of them
+// with --all-context, are returned whole.
+func (c *CommandExecutor) getRelevantFileContent(question string) (string, error) {
+	docs, err := c.loadedDocuments()
+	if err != nil {
+		return "", err
+	}
+
+	if c.coder.cfg.AllContext || c.flags[FlagAllContext] {
+		return c.formatChunks(retrieval.Whole(docs))
+	}
+
+	selection := retrieval.Select(docs, question, c.coder.cfg.Retrieval.ChunkSize, c.retrievalLimit())
+	if !selection.All {
+		sources := make([]string, 0, len(selection.Chunks))
+		for _, chunk := range selection.Chunks {
+			sources = append(sources, fmt.Sprintf("%s:%d-%d", c.displayPath(chunk.Source), chunk.StartLine, chunk.EndLine))
+		}
+		c.historyWriter.RenderComment(
+			"Picked %d of %d chunks of the loaded contexts (%s), use --all-context to send them whole.",
+			len(selection.Chunks), selection.Total, strings.Join(sources, ", "),
+		)
+	}
+	return c.formatChunks(selection)
+}
+
+// retrievalLimit is the size of the loaded contexts sent with a question,
+// at most the input limit of the model.
+func (c *CommandExecutor) retrievalLimit() int {
+	limit := c.coder.cfg.Retrieval.MaxChars
+	if limit <= 0 {
+		limit = retrieval.DefaultMaxChars
+	}
+	if budget := c.coder.engine.InputBudget(); budget > 0 {
+		limit = min(limit, budget)
+	}
+	return limit
+}
+
+// loadedDocuments reads the loaded contexts in the order they were added,
+// images aside.
+func (c *CommandExecutor) loadedDocuments() ([]retrieval.Document, error) {
+	docs := make([]retrieval.Document, 0, len(c.coder.loadedContexts))
+	for _, lc := range c.coder.loadedContexts {
+		if lc.Type == convo.ContentTypeImage {
+			// sent as images, see getAddedImages
+			continue
+		}
+		filePath := lc.FilePath
+		if lc.Type == convo.ContentTypeURL {
+			filePath = lc.URL
+		}
+		content, err := c.loadFileContent(filePath)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, retrieval.Document{Source: filePath, Content: content})
+	}
+	return docs, nil
+}
+
+// formatChunks fences the selected chunks, skipping the ones that no longer
+// fit into the input limit.
+func (c *CommandExecutor) formatChunks(selection retrieval.Selection) (string, error) {
 	addedFiles := ""
-	if len(c.coder.loadedContexts) > 0 {
-		for _, lc := range c.coder.loadedContexts {
-			filePath := lc.FilePath
-			if lc.Type == convo.ContentTypeURL {
-				filePath = lc.URL
-			}
-			content, err := c.formatFileContent(filePath)
-			if err != nil {
-				return "", err
-			}
-			addedFiles += content
+	budget := c.coder.engine.InputBudget()
+	for _, chunk := range selection.Chunks {
+		content, err := c.formatFileContent(chunk, !selection.All)
+		if err != nil {
+			return "", err
 		}
+		if budget > 0 && utf8.RuneCountInString(addedFiles+content) > budget {
+			c.historyWriter.RenderWarn(
+				"Skipped %s, it does not fit into the input limit of %d characters. Use /drop to clear the loaded files.",
+				chunk.Source, budget,
+			)
+			continue
+		}
+		addedFiles += content
 	}
 
 	return addedFiles, nil
 }
 
-func (c *CommandExecutor) formatFileContent(filePath string) (string, error) {
-	content, err := c.loadFileContent(filePath)
+// getAddedImages returns the images given on the command line and the image
+// files added to the chat, to attach them to the question. Nothing is sent to
+// models that can't take images.
+func (c *CommandExecutor) getAddedImages() ([]llms.BinaryContent, error) {
+	images := c.coder.images
+	for _, lc := range c.coder.loadedContexts {
+		if lc.Type != convo.ContentTypeImage {
+			continue
+		}
+		if !c.coder.cfg.CurrentModel.Vision {
+			c.historyWriter.RenderWarn("Skipped %s, the model %s can't take images.", lc.FilePath, c.coder.cfg.CurrentModel.Name)
+			continue
+		}
+		image, err := ai.LoadImage(lc.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// withAddedImages attaches the added images to the question.
+func (c *CommandExecutor) withAddedImages(messages []llms.ChatMessage) ([]llms.ChatMessage, error) {
+	images, err := c.getAddedImages()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return ai.WithImages(messages, images), nil
+}
+
+// formatFileContent fences a chunk of a loaded context, naming the lines
+// it spans when it is a part of the context.
+func (c *CommandExecutor) formatFileContent(chunk retrieval.Chunk, partial bool) (string, error) {
+	filePath, content := chunk.Source, chunk.Content
+	label := c.displayPath(filePath)
+	if partial {
+		label += fmt.Sprintf(" (lines %d-%d)", chunk.StartLine, chunk.EndLine)
 	}
 
 	// For remote URLs, use the full URL as the identifier
@@ -792,15 +941,22 @@ func (c *CommandExecutor) formatFileContent(filePath string) (string, error) {
 		if len(name) > 40 {
 			name = name[:20] + "⋯" + name[len(name)-20:]
 		}
-		return fmt.Sprintf("\n%s%s", filePath, html.UnescapeString(wrapFenceWithType(content, name, c.coder.cfg.AutoCoder.GetDefaultFences()))), nil
+		return fmt.Sprintf("\n%s%s", label, html.UnescapeString(wrapFenceWithType(content, name, c.coder.cfg.AutoCoder.GetDefaultFences()))), nil
 	}
 
-	// For local files, use relative path
-	relPath, err := filepath.Rel(c.coder.codeBasePath, filePath)
-	if err != nil {
-		return "", err
+	return fmt.Sprintf("\n%s%s", label, html.UnescapeString(wrapFenceWithType(content, filePath, c.coder.cfg.AutoCoder.GetDefaultFences()))), nil
+}
+
+// displayPath returns the path of a local file relative to the code base,
+// URLs as they are.
+func (c *CommandExecutor) displayPath(filePath string) string {
+	if rest.IsValidURL(filePath) {
+		return filePath
+	}
+	if relPath, err := filepath.Rel(c.coder.codeBasePath, filePath); err == nil {
+		return relPath
 	}
-	return fmt.Sprintf("\n%s%s", relPath, html.UnescapeString(wrapFenceWithType(content, filePath, c.coder.cfg.AutoCoder.GetDefaultFences()))), nil
+	return filePath
 }
 
 func (c *CommandExecutor) switchNewChatModel(_ context.Context, input string) error {
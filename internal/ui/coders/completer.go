@@ -50,6 +50,7 @@ func (c CommandCompleter) Complete(d prompt.Document) (suggestions []prompt.Sugg
 	if strings.HasPrefix(w, "--") {
 		completions := []prompt.Suggest{
 			{Text: "--verbose"},
+			{Text: "--all-context"},
 			{Text: "--help"},
 		}
 		return prompt.FilterContains(completions, w, true), startIndex, endIndex
@@ -227,6 +227,12 @@ func (e *EditBlockCoder) Execute(ctx context.Context, messages []llms.ChatMessag
 		return err
 	}
 
+	// the edits of a partial answer are incomplete
+	if chatModel.Interrupted() {
+		console.Render("The design was interrupted, no edits were applied.")
+		return nil
+	}
+
 	e.partialResponseContent = chatModel.GetOutput()
 
 	if ok := console.WaitForUserConfirm(console.Yes, "Are you sure you want to apply these codes? (Y/n)"); !ok {
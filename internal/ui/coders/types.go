@@ -3,4 +3,6 @@ package coders
 const (
 	FlagVerbose = "verbose"
 	FlagYes     = "yes"
+	// FlagAllContext sends the loaded contexts whole, see getRelevantFileContent
+	FlagAllContext = "all-context"
 )
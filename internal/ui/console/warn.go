@@ -2,6 +2,7 @@ package console
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -20,3 +21,10 @@ func Warn(text string) {
 func Warnf(format string, args ...interface{}) {
 	fmt.Println(warnStyle.Render(fmt.Sprintf(format, args...)))
 }
+
+// WarnStderr prints the given text with a warning style to stderr, keeping
+// it out of output that is piped to other commands.
+func WarnStderr(text string) {
+	style := StderrRenderer().NewStyle().Bold(true).Foreground(lipgloss.Color("3"))
+	_, _ = fmt.Fprintln(os.Stderr, style.Render(text))
+}
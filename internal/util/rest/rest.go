@@ -11,6 +11,7 @@ import (
 	"github.com/PuerkitoBio/goquery"
 
 	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
 )
 
 const (
@@ -20,9 +21,16 @@ const (
 	maxContentSizeInMB = 10
 )
 
-func FetchURLContent(url string) (string, error) {
+// FetchURLContent fetches the page at url as text, going through the proxy
+// and with the TLS settings given.
+func FetchURLContent(url string, settings options.HTTP) (string, error) {
+	transport, err := NewTransport(settings)
+	if err != nil {
+		return "", err
+	}
 	client := &http.Client{
-		Timeout: httpTimeout,
+		Transport: transport,
+		Timeout:   httpTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= maxRedirections {
 				return errbook.New("stopped after too many redirects")
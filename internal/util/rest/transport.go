@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/coding-hui/ai-terminal/internal/errbook"
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// NewTransport returns an HTTP transport using the proxy and the TLS
+// settings. Without a proxy the standard proxy environment is used.
+func NewTransport(settings options.HTTP) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.Proxy != "" {
+		proxy, err := url.Parse(settings.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, errbook.New("Invalid http proxy %q, expected a URL like http://proxy.example.com:3128.", settings.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if settings.CACert == "" && settings.ClientCert == "" && settings.ClientKey == "" {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if settings.CACert != "" {
+		pem, err := os.ReadFile(settings.CACert)
+		if err != nil {
+			return nil, errbook.Wrap("Could not read the CA bundle "+settings.CACert, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errbook.New("The CA bundle %s has no PEM certificates.", settings.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if settings.ClientKey != "" && settings.ClientCert == "" {
+		return nil, errbook.New("The client key %s is set without a client certificate.", settings.ClientKey)
+	}
+	if settings.ClientCert != "" {
+		key := settings.ClientKey
+		if key == "" {
+			key = settings.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(settings.ClientCert, key)
+		if err != nil {
+			return nil, errbook.Wrap("Could not load the client certificate "+settings.ClientCert, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coding-hui/ai-terminal/internal/options"
+)
+
+// writeServerPEM writes the certificate of the test server, and its key if
+// asked, to a PEM file.
+func writeServerPEM(t *testing.T, srv *httptest.Server, withKey bool) string {
+	t.Helper()
+	cert := srv.TLS.Certificates[0]
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	if withKey {
+		key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+		require.NoError(t, err)
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})...)
+	}
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func get(t *testing.T, settings options.HTTP, url string) (*http.Response, error) {
+	t.Helper()
+	transport, err := NewTransport(settings)
+	require.NoError(t, err)
+	return (&http.Client{Transport: transport}).Get(url)
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		var host string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host = r.URL.Host
+		}))
+		defer proxy.Close()
+
+		rsp, err := get(t, options.HTTP{Proxy: proxy.URL}, "http://example.invalid/docs")
+		require.NoError(t, err)
+		_ = rsp.Body.Close()
+		assert.Equal(t, "example.invalid", host)
+	})
+
+	t.Run("custom CA", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		defer srv.Close()
+
+		_, err := get(t, options.HTTP{}, srv.URL)
+		assert.Error(t, err)
+
+		rsp, err := get(t, options.HTTP{CACert: writeServerPEM(t, srv, false)}, srv.URL)
+		require.NoError(t, err)
+		_ = rsp.Body.Close()
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		var peers int
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			peers = len(r.TLS.PeerCertificates)
+		}))
+		srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		srv.StartTLS()
+		defer srv.Close()
+
+		cert := writeServerPEM(t, srv, true)
+		_, err := get(t, options.HTTP{CACert: cert}, srv.URL)
+		assert.Error(t, err)
+
+		rsp, err := get(t, options.HTTP{CACert: cert, ClientCert: cert}, srv.URL)
+		require.NoError(t, err)
+		_ = rsp.Body.Close()
+		assert.Equal(t, 1, peers)
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		notPEM := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+		for name, settings := range map[string]options.HTTP{
+			"proxy":          {Proxy: "::"},
+			"missing CA":     {CACert: filepath.Join(t.TempDir(), "missing.pem")},
+			"CA without PEM": {CACert: notPEM},
+			"key only":       {ClientKey: notPEM},
+			"bad client":     {ClientCert: notPEM},
+		} {
+			_, err := NewTransport(settings)
+			assert.Error(t, err, name)
+		}
+	})
+}